@@ -0,0 +1,132 @@
+package controllers
+
+import (
+	"FASMS/models"
+	"FASMS/utils"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type ApiKeyController struct {
+	DB *gorm.DB
+}
+
+func NewApiKeyController(db *gorm.DB) *ApiKeyController {
+	return &ApiKeyController{DB: db}
+}
+
+func (kc *ApiKeyController) GetApiKeys(c *gin.Context) {
+	var apiKeys []models.ApiKeys
+	if err := kc.DB.Order("created_at desc").Find(&apiKeys).Error; err != nil {
+		log.Printf("Database error fetching api keys: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch api keys"})
+		return
+	}
+
+	ret := []models.ApiKeysResponse{}
+	for _, apiKey := range apiKeys {
+		ret = append(ret, apiKey.ConvertToResponse())
+	}
+	c.JSON(http.StatusOK, gin.H{"api_keys": ret})
+}
+
+func (kc *ApiKeyController) CreateApiKey(c *gin.Context) {
+	var apiKeyRequest models.CreateApiKeyRequest
+
+	// Bind JSON and return 422 Unprocessable Entity on failure
+	if err := c.ShouldBindJSON(&apiKeyRequest); err != nil {
+		log.Printf("Invalid request payload: %v\n", err)
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Invalid request payload"})
+		return
+	}
+
+	apiKey, key, err := apiKeyRequest.ConvertToModel(actorOf(c))
+	if err != nil {
+		log.Printf("generate api key failed: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create api key"})
+		return
+	}
+	tx := kc.DB.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback() // Ensure rollback in case of panic
+		}
+	}()
+	if err := tx.Create(&apiKey).Error; err != nil {
+		tx.Rollback()
+		log.Printf("create api key failed: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create api key"})
+		return
+	}
+	// the response never holds the key nor its hash
+	if err := recordAudit(tx, actorOf(c), models.AuditEntityApiKey, apiKey.ID, models.AuditActionCreate, nil, apiKey.ConvertToResponse()); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create api key"})
+		return
+	}
+	if err := tx.Commit().Error; err != nil {
+		log.Printf("Transaction commit failed: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Transaction commit failed"})
+		return
+	}
+	c.JSON(http.StatusCreated, models.CreateApiKeyResponse{Key: key, ApiKeysResponse: apiKey.ConvertToResponse()})
+}
+
+// a revoked key is kept so that the audit log entries made with it still refer to a known key
+func (kc *ApiKeyController) RevokeApiKey(c *gin.Context) {
+	apiKeyID := c.Param("id")
+
+	var apiKey models.ApiKeys
+	if err := kc.DB.Where("id = ?", apiKeyID).First(&apiKey).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			log.Printf("api key with id: %s did not found, %v\n", apiKeyID, err)
+			c.JSON(http.StatusNotFound, gin.H{"error": "Api key not found"})
+			return
+		}
+		log.Printf("Database error fetching api key: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch api key"})
+		return
+	}
+	if apiKey.IsRevoked() {
+		c.JSON(http.StatusConflict, gin.H{"error": "Api key is already revoked"})
+		return
+	}
+
+	before := apiKey.ConvertToResponse()
+	revokedAt := utils.Now()
+	tx := kc.DB.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback() // Ensure rollback in case of panic
+		}
+	}()
+	// only a key not revoked yet is revoked, so that concurrent revocations are audited once
+	result := tx.Model(&models.ApiKeys{}).Where("id = ?", apiKey.ID).Where("revoked_at IS NULL").Update("revoked_at", revokedAt)
+	if result.Error != nil {
+		tx.Rollback()
+		log.Printf("revoke api key failed: %v\n", result.Error)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke api key"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		tx.Rollback()
+		c.JSON(http.StatusConflict, gin.H{"error": "Api key is already revoked"})
+		return
+	}
+	apiKey.RevokedAt = &revokedAt
+	if err := recordAudit(tx, actorOf(c), models.AuditEntityApiKey, apiKey.ID, "revoke", before, apiKey.ConvertToResponse()); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke api key"})
+		return
+	}
+	if err := tx.Commit().Error; err != nil {
+		log.Printf("Transaction commit failed: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Transaction commit failed"})
+		return
+	}
+	c.JSON(http.StatusOK, apiKey.ConvertToResponse())
+}
@@ -103,6 +103,13 @@ func (ac *ApplicantController) CreateApplicants(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create applicant"})
 		return
 	}
+	for _, applicant := range applicants {
+		if err := recordAudit(tx, actorOf(c), models.AuditEntityApplicant, applicant.ID, models.AuditActionCreate, nil, applicant.ConvertToResponse()); err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create applicant"})
+			return
+		}
+	}
 	if err := tx.Commit().Error; err != nil {
 		log.Printf("Transaction commit failed: %v\n", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Transaction commit failed"})
@@ -158,12 +165,15 @@ func (ac *ApplicantController) UpdateApplicant(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch applicants list"})
 		return
 	}
+	before := applicant.ConvertToResponse()
 	applicant.Name = updatedApplicant.Name
 	applicant.IC = updatedApplicant.IC
 	applicant.EmploymentStatus = updatedApplicant.EmploymentStatus
 	applicant.MaritalStatus = updatedApplicant.MaritalStatus
 	applicant.Sex = updatedApplicant.Sex
 	applicant.DOB = updatedApplicant.DOB.ToTime()
+	applicant.MonthlyIncome = updatedApplicant.MonthlyIncome
+	applicant.Assets = updatedApplicant.Assets
 
 	if err := tx.Save(applicant).Error; err != nil {
 		tx.Rollback()
@@ -184,6 +194,21 @@ func (ac *ApplicantController) UpdateApplicant(c *gin.Context) {
 		return
 	}
 
+	applicant.Households = newHouseholds
+	if err := recordAudit(tx, actorOf(c), models.AuditEntityApplicant, applicantID, models.AuditActionUpdate, before, applicant.ConvertToResponse()); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "update applicant failed"})
+		return
+	}
+
+	// the applications of the applicant are re-assessed against the updated data
+	if err := reassessApplications(tx, actorOf(c), "applicant or household updated", "applicant_id = ?", applicantID); err != nil {
+		tx.Rollback()
+		log.Printf("reassess applications of applicant %s failed: %v\n", applicantID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reassess applications"})
+		return
+	}
+
 	if err := tx.Commit().Error; err != nil {
 		tx.Rollback()
 		log.Printf("Transaction commit failed: %v\n", err)
@@ -191,8 +216,6 @@ func (ac *ApplicantController) UpdateApplicant(c *gin.Context) {
 		return
 	}
 
-	applicant.Households = newHouseholds
-
 	c.JSON(http.StatusOK, applicant.ConvertToResponse())
 }
 
@@ -201,7 +224,7 @@ func (ac *ApplicantController) DeleteApplicant(c *gin.Context) {
 
 	// check if applicant exist
 	var applicant models.Applicants
-	if err := ac.DB.Where("id = ?", applicantID).First(&applicant).Error; err != nil {
+	if err := ac.DB.Preload("Households").Where("id = ?", applicantID).First(&applicant).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			log.Printf("applicant with id: %s did not found, %v\n", applicantID, err)
 			c.JSON(http.StatusNotFound, gin.H{"error": "Applicant not found"})
@@ -241,17 +264,23 @@ func (ac *ApplicantController) DeleteApplicant(c *gin.Context) {
 		return
 	}
 
+	if err := recordAudit(tx, actorOf(c), models.AuditEntityApplicant, applicantID, models.AuditActionDelete, applicant.ConvertToResponse(), nil); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete applicant"})
+		return
+	}
+
 	// update applications involved
-	result := tx.Where("applicant_id = ?", applicantID).Delete(&models.Applications{})
+	rowsAffected, err := flagApplicationsForReview(tx, actorOf(c), "applicant deleted", "applicant_id = ?", applicantID)
 
 	// Check if any rows were affected
-	if result.Error != nil {
+	if err != nil {
 		tx.Rollback()
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "while deleting applicant, Failed to delete applications"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "while deleting applicant, Failed to update applications"})
 		return
 	}
 
-	if result.RowsAffected == 0 {
+	if rowsAffected == 0 {
 		log.Printf("while deleting applicant: %v, No applications found for the applicant\n", applicantID)
 		// c.JSON(http.StatusNotFound, gin.H{"error": "No applications found for the applicant"})
 		// return
@@ -288,6 +317,8 @@ func updateHouseHolds(tx *gorm.DB, updatedHousehold []models.CreateHouseholds, e
 				"sex":               newHousehold.Sex,
 				"dob":               newHousehold.DOB.ToTime(),
 				"relation":          newHousehold.Relation,
+				"monthly_income":    newHousehold.MonthlyIncome,
+				"assets":            newHousehold.Assets,
 			}
 			if err := tx.Model(&existingHousehold).Updates(updateData).Error; err != nil {
 				tx.Rollback()
@@ -300,6 +331,8 @@ func updateHouseHolds(tx *gorm.DB, updatedHousehold []models.CreateHouseholds, e
 			existingHousehold.EmploymentStatus = newHousehold.EmploymentStatus
 			existingHousehold.Sex = newHousehold.Sex
 			existingHousehold.DOB = newHousehold.DOB.ToTime()
+			existingHousehold.MonthlyIncome = newHousehold.MonthlyIncome
+			existingHousehold.Assets = newHousehold.Assets
 			newHouseholds = append(newHouseholds, existingHousehold)
 
 			delete(existingHouseholdsMapping, householdID) // Remove from map to track deletions
@@ -313,6 +346,8 @@ func updateHouseHolds(tx *gorm.DB, updatedHousehold []models.CreateHouseholds, e
 				DOB:              newHousehold.DOB.ToTime(),
 				ApplicantID:      applicantID,
 				Relation:         newHousehold.Relation,
+				MonthlyIncome:    newHousehold.MonthlyIncome,
+				Assets:           newHousehold.Assets,
 			})
 		}
 	}
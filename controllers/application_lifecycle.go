@@ -0,0 +1,234 @@
+package controllers
+
+import (
+	"FASMS/models"
+	"FASMS/utils"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+func (ac *ApplicationController) ApproveApplication(c *gin.Context) {
+	ac.transitionApplication(c, "approve", models.ApplicationStatusApproved, models.ApplicationActionRequest{})
+}
+
+func (ac *ApplicationController) RejectApplication(c *gin.Context) {
+	var actionRequest models.ApplicationActionRequest
+
+	// Bind JSON and return 422 Unprocessable Entity on failure
+	if err := c.ShouldBindJSON(&actionRequest); err != nil {
+		log.Printf("Invalid request payload: %v\n", err)
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Invalid request payload"})
+		return
+	}
+	if err := models.ValidateRejection(actionRequest.RejectionReason, actionRequest.RejectionNote); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	}
+	ac.transitionApplication(c, "reject", models.ApplicationStatusRejected, actionRequest)
+}
+
+func (ac *ApplicationController) WithdrawApplication(c *gin.Context) {
+	ac.transitionApplication(c, "withdraw", models.ApplicationStatusWithdrawn, models.ApplicationActionRequest{})
+}
+
+// reopens a rejected or withdrawn application as submitted
+func (ac *ApplicationController) ReopenApplication(c *gin.Context) {
+	ac.transitionApplication(c, "reopen", models.ApplicationStatusSubmitted, models.ApplicationActionRequest{})
+}
+
+// moves the application to the status if the transition table allows it, otherwise responds with 409.
+// the action is recorded in the audit log
+func (ac *ApplicationController) transitionApplication(c *gin.Context, action string, to uint, actionRequest models.ApplicationActionRequest) {
+	applicationID := c.Param("id")
+
+	// Fetch applicants and return 500 Internal Server Error on failure
+	var application models.Applications
+	if err := ac.DB.Preload("Scheme").
+		Preload("Scheme.CriteriaGroups.Criterias").
+		Preload("Scheme.CriteriaNodes").
+		Preload("Scheme.Relations").
+		Preload("Scheme.Benefits").
+		Preload("Applicant").
+		Preload("Applicant.Households").
+		Preload("Applicant.Applications").
+		Where("id = ?", applicationID).First(&application).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			log.Printf("application with id: %s did not found, %v\n", applicationID, err)
+			c.JSON(http.StatusNotFound, gin.H{"error": "Application not found"})
+			return
+		}
+		log.Printf("Database error fetching Application: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch Application"})
+		return
+	}
+
+	from := application.ApplicationStatus
+	if !models.CanTransitionApplication(from, to) {
+		log.Printf("application %s can not move from %s to %s\n", applicationID, models.ApplicationStatusName(from), models.ApplicationStatusName(to))
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("Application can not move from %s to %s", models.ApplicationStatusName(from), models.ApplicationStatusName(to))})
+		return
+	}
+	// an application flagged as no longer eligible must be rejected or re-assessed, not approved
+	if to == models.ApplicationStatusApproved && !application.IsEligible {
+		c.JSON(http.StatusConflict, gin.H{"error": "Application is no longer eligible for the scheme"})
+		return
+	}
+	// the applicant's other applications may have changed since the application was assessed,
+	// so the relations to other schemes are checked again as of now before it is approved or reopened
+	if to == models.ApplicationStatusApproved || to == models.ApplicationStatusSubmitted {
+		if satisfied, relations := models.CheckSchemeRelations(application.Applicant, application.Scheme, utils.Now()); !satisfied {
+			log.Printf("application %s does not satisfy the relations of scheme %s\n", applicationID, application.SchemeID)
+			c.JSON(http.StatusConflict, gin.H{"error": "Application does not satisfy the scheme's relations to other schemes", "relations": relations})
+			return
+		}
+	}
+
+	before := application
+	updateData := map[string]interface{}{
+		"application_status": to,
+	}
+	// the review is settled once the application leaves need review
+	if to != models.ApplicationStatusNeedReview {
+		updateData["review_reason"] = ""
+		application.ReviewReason = ""
+	}
+	// a promoted application stays ahead of new applications until it is decided
+	if to != models.ApplicationStatusUnderReview && to != models.ApplicationStatusNeedReview {
+		updateData["promoted_at"] = nil
+		application.PromotedAt = nil
+	}
+	// the eligibility an approval or a rejection is decided on, kept as evidence of the decision
+	var eligibility models.EligibilityTrace
+	switch to {
+	case models.ApplicationStatusApproved:
+		// an approval records the scheme version the application is approved under
+		approvedVersion := application.Scheme.CurrentVersion
+		updateData["approved_version"] = approvedVersion
+		application.ApprovedVersion = &approvedVersion
+		// the amounts are computed from the eligibility as of the application date
+		ctx := models.NewApplicationEligibilityContext(application.CreatedAt)
+		eligibility = models.ExplainEligibility(application.Applicant, application.Scheme, ctx)
+		entitlement, err := models.ComputeEntitlement(application.Applicant, application.Scheme, eligibility, ctx)
+		if err == nil {
+			err = application.SetEntitlement(entitlement)
+		}
+		if err != nil {
+			log.Printf("compute entitlement of application %s failed: %v\n", applicationID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to compute entitlement"})
+			return
+		}
+		updateData["entitlement"] = application.Entitlement
+		updateData["entitlement_detail"] = application.EntitlementDetail
+	case models.ApplicationStatusRejected:
+		updateData["rejection_reason"] = actionRequest.RejectionReason
+		updateData["rejection_note"] = actionRequest.RejectionNote
+		application.RejectionReason = actionRequest.RejectionReason
+		application.RejectionNote = actionRequest.RejectionNote
+		eligibility = models.ExplainEligibility(application.Applicant, application.Scheme, models.NewApplicationEligibilityContext(application.CreatedAt))
+		// an application that is no longer approved gives its beneficiary back, its next approval takes one again
+		updateData["approved_version"] = nil
+		application.ApprovedVersion = nil
+	case models.ApplicationStatusWithdrawn:
+		updateData["approved_version"] = nil
+		application.ApprovedVersion = nil
+	case models.ApplicationStatusSubmitted:
+		updateData["rejection_reason"] = nil
+		updateData["rejection_note"] = ""
+		updateData["entitlement"] = nil
+		updateData["entitlement_detail"] = nil
+		application.RejectionReason = nil
+		application.RejectionNote = ""
+		application.Entitlement = nil
+		application.EntitlementDetail = nil
+	}
+
+	tx := ac.DB.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback() // Ensure rollback in case of panic
+		}
+	}()
+	// the status is only changed if nobody else changed it in between
+	result := tx.Model(&models.Applications{}).
+		Where("id = ?", applicationID).
+		Where("application_status = ?", from).
+		Updates(updateData)
+
+	// Check if any rows were affected
+	if result.Error != nil {
+		tx.Rollback()
+		log.Printf("Error updating application with id: %s, %v\n", applicationID, result.Error)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update application"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		tx.Rollback()
+		c.JSON(http.StatusConflict, gin.H{"error": "Application was changed by another request, please retry"})
+		return
+	}
+
+	application.ApplicationStatus = to
+	switch to {
+	case models.ApplicationStatusApproved:
+		// the first approval takes a beneficiary of the scheme, a later one after a review already holds it
+		if err := createBenefitSchedules(tx, actorOf(c), application, before.ApprovedVersion == nil); err != nil {
+			tx.Rollback()
+			if errors.Is(err, errCapacityReached) {
+				if err := waitlistApplication(ac.DB, actorOf(c), before); err != nil {
+					c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to waitlist application"})
+					return
+				}
+				c.JSON(http.StatusConflict, gin.H{"error": "Scheme has reached its budget or its maximum number of beneficiaries, the application is waitlisted"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to schedule the benefits"})
+			return
+		}
+	case models.ApplicationStatusRejected, models.ApplicationStatusWithdrawn, models.ApplicationStatusClosed:
+		// an application leaving the scheme releases what it has not been paid, and its beneficiary unless it is closed
+		if before.ApprovedVersion != nil {
+			releaseBeneficiary := to != models.ApplicationStatusClosed
+			if err := releaseApplication(tx, actorOf(c), application, fmt.Sprintf("the application is %s", models.ApplicationStatusName(to)), releaseBeneficiary); err != nil {
+				tx.Rollback()
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to release the scheme's capacity"})
+				return
+			}
+		}
+	}
+	if to == models.ApplicationStatusApproved || to == models.ApplicationStatusRejected {
+		evidence, err := models.NewApplicationEvidence(application.ID, application.Applicant, application.Scheme, eligibility, utils.Now())
+		if err == nil {
+			err = tx.Create(&evidence).Error
+		}
+		if err != nil {
+			tx.Rollback()
+			log.Printf("record evidence of application %s failed: %v\n", applicationID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update application"})
+			return
+		}
+	}
+	if err := recordAudit(tx, actorOf(c), models.AuditEntityApplication, applicationID, action, before, application); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update application"})
+		return
+	}
+	// the relations of the applicant's other applications may depend on this one, e.g. a top-up on its base scheme
+	reason := fmt.Sprintf("application %s of the applicant is %s", applicationID, models.ApplicationStatusName(to))
+	if err := reassessApplications(tx, actorOf(c), reason, "applicant_id = ? AND id <> ?", application.ApplicantID, applicationID); err != nil {
+		tx.Rollback()
+		log.Printf("reassess applications of applicant %s failed: %v\n", application.ApplicantID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update application"})
+		return
+	}
+	if err := tx.Commit().Error; err != nil {
+		log.Printf("Transaction commit failed: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Transaction commit failed"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"application": application.ConvertToResponse()})
+}
@@ -2,7 +2,9 @@ package controllers
 
 import (
 	"FASMS/models"
+	"FASMS/utils"
 	"errors"
+	"fmt"
 	"log"
 	"net/http"
 
@@ -39,6 +41,7 @@ func (ac *ApplicationController) GetApplicationList(c *gin.Context) {
 	var query = ac.DB.Offset(applicationsRequest.Page * applicationsRequest.PageSize).Limit(applicationsRequest.PageSize).Order("id")
 	if err := query.Preload("Scheme").
 		Preload("Scheme.CriteriaGroups.Criterias").
+		Preload("Scheme.CriteriaNodes").
 		Preload("Scheme.Benefits").
 		Preload("Applicant").
 		Preload("Applicant.Households").
@@ -101,7 +104,7 @@ func (ac *ApplicationController) CreateApplication(c *gin.Context) {
 	}
 
 	// Fetch applicants and return 500 Internal Server Error on failure
-	if err := ac.DB.Preload("Households").Where("id = ?", applicationsRequest.ApplicantID).First(&applicant).Error; err != nil {
+	if err := ac.DB.Preload("Households").Preload("Applications").Where("id = ?", applicationsRequest.ApplicantID).First(&applicant).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			log.Printf("applicant with id: %s did not found, %v\n", applicationsRequest.ApplicantID, err)
 			c.JSON(http.StatusNotFound, gin.H{"error": "Applicant not found"})
@@ -112,7 +115,7 @@ func (ac *ApplicationController) CreateApplication(c *gin.Context) {
 		return
 	}
 	// Fetch scheme and return 500 Internal Server Error on failure
-	if err := ac.DB.Preload("CriteriaGroups.Criterias").Preload("Benefits").Where("id = ?", applicationsRequest.SchemeID).First(&scheme).Error; err != nil {
+	if err := ac.DB.Preload("CriteriaGroups.Criterias").Preload("CriteriaNodes").Preload("Benefits").Preload("Relations").Where("id = ?", applicationsRequest.SchemeID).First(&scheme).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			log.Printf("scheme with id: %s did not found, %v\n", applicationsRequest.SchemeID, err)
 			c.JSON(http.StatusNotFound, gin.H{"error": "Scheme not found"})
@@ -122,40 +125,86 @@ func (ac *ApplicationController) CreateApplication(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch scheme list"})
 		return
 	}
-	if models.CheckEligiblity(applicant, scheme) {
+	if !scheme.IsPublished() {
+		log.Printf("scheme with id: %s is not published\n", scheme.ID)
+		c.JSON(http.StatusForbidden, gin.H{"error": "Scheme is not published"})
+		return
+	}
+	applicationDate := utils.Now()
+	if !scheme.IsOpenForApplication(applicationDate) {
+		log.Printf("scheme with id: %s is not open for applications on %s\n", scheme.ID, applicationDate.Format(utils.DateFormat))
+		c.JSON(http.StatusForbidden, gin.H{"error": fmt.Sprintf("Scheme is not open for applications, the application period is %s", scheme.ApplicationPeriod())})
+		return
+	}
+	eligibilityContext := models.NewApplicationEligibilityContext(applicationDate)
+	eligibility := models.ExplainEligibility(applicant, scheme, eligibilityContext)
+	if eligibility.Eligible {
 		newApplication := applicationsRequest.ConvertToModel()
-		if err := ac.DB.Create(&newApplication).Error; err != nil {
+		newApplication.SchemeVersion = scheme.CurrentVersion
+		// an application the scheme can not take any more waits for capacity to be released
+		commitment, err := models.EstimateCommitment(applicant, scheme, eligibilityContext, applicationDate)
+		if err != nil {
+			log.Printf("estimate commitment of applicant %s failed: %v\n", applicant.ID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create applicant"})
+			return
+		}
+		waitlisted, err := shouldWaitlist(ac.DB, scheme, commitment)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create applicant"})
+			return
+		}
+		if waitlisted {
+			newApplication.ApplicationStatus = models.ApplicationStatusWaitlisted
+		}
+		evidence, err := models.NewApplicationEvidence(newApplication.ID, applicant, scheme, eligibility, applicationDate)
+		if err != nil {
+			log.Printf("snapshot application evidence failed: %v\n", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create applicant"})
+			return
+		}
+
+		tx := ac.DB.Begin()
+		defer func() {
+			if r := recover(); r != nil {
+				tx.Rollback() // Ensure rollback in case of panic
+			}
+		}()
+		if err := tx.Create(&newApplication).Error; err != nil {
+			tx.Rollback()
 			log.Printf("create applicants failed: %v\n", err)
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create applicant"})
 			return
 		}
+		// the evidence is stored with the application so that the decision can be explained later
+		if err := tx.Create(&evidence).Error; err != nil {
+			tx.Rollback()
+			log.Printf("create application evidence failed: %v\n", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create applicant"})
+			return
+		}
+		if err := recordAudit(tx, actorOf(c), models.AuditEntityApplication, newApplication.ID, models.AuditActionCreate, nil, newApplication); err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create applicant"})
+			return
+		}
+		if err := tx.Commit().Error; err != nil {
+			log.Printf("Transaction commit failed: %v\n", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Transaction commit failed"})
+			return
+		}
 		newApplication.Applicant = applicant
 		newApplication.Scheme = scheme
 		c.JSON(http.StatusCreated, newApplication.ConvertToResponse())
 	} else {
-		c.JSON(http.StatusForbidden, gin.H{"error": "Applicant is not eligible for the selected scheme"})
+		c.JSON(http.StatusForbidden, gin.H{"error": "Applicant is not eligible for the selected scheme", "eligibility": eligibility})
 	}
 }
 
-func (ac *ApplicationController) UpdateApplication(c *gin.Context) {
+func (ac *ApplicationController) GetApplicationEvidence(c *gin.Context) {
 	applicationID := c.Param("id")
-	var applicationsRequest models.UpdateApplicationRequest
-
-	// Bind JSON and return 422 Unprocessable Entity on failure
-	if err := c.ShouldBindJSON(&applicationsRequest); err != nil {
-		log.Printf("Invalid request payload: %v\n", err)
-		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Invalid request payload"})
-		return
-	}
 
-	// Fetch applicants and return 500 Internal Server Error on failure
-	var application models.Applications
-	if err := ac.DB.Preload("Scheme").
-		Preload("Scheme.CriteriaGroups.Criterias").
-		Preload("Scheme.Benefits").
-		Preload("Applicant").
-		Preload("Applicant.Households").
-		Where("id = ?", applicationID).First(&application).Error; err != nil {
+	// the evidence of a deleted application is kept, so the application is looked up including deleted ones
+	if err := ac.DB.Unscoped().Where("id = ?", applicationID).First(&models.Applications{}).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			log.Printf("application with id: %s did not found, %v\n", applicationID, err)
 			c.JSON(http.StatusNotFound, gin.H{"error": "Application not found"})
@@ -166,27 +215,48 @@ func (ac *ApplicationController) UpdateApplication(c *gin.Context) {
 		return
 	}
 
-	// update applications involved
-	result := ac.DB.Model(&models.Applications{}).
-		Where("id = ?", applicationID).
-		Update("application_status", applicationsRequest.ApplicationStatus)
+	var evidences []models.ApplicationEvidences
+	if err := ac.DB.Where("application_id = ?", applicationID).Order("evaluated_at asc").Find(&evidences).Error; err != nil {
+		log.Printf("Database error fetching application evidence: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch application evidence"})
+		return
+	}
+
+	evidencesResponse := []models.ApplicationEvidencesResponse{}
+	for _, evidence := range evidences {
+		evidencesResponse = append(evidencesResponse, evidence.ConvertToResponse())
+	}
+	c.JSON(http.StatusOK, gin.H{"evidence": evidencesResponse})
+}
+
+func (ac *ApplicationController) UpdateApplication(c *gin.Context) {
+	var applicationsRequest models.UpdateApplicationRequest
 
-	// Check if any rows were affected
-	if result.Error != nil {
-		log.Printf("Error updating application with id: %s, %v\n", applicationID, result.Error)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update application"})
+	// Bind JSON and return 422 Unprocessable Entity on failure
+	if err := c.ShouldBindJSON(&applicationsRequest); err != nil {
+		log.Printf("Invalid request payload: %v\n", err)
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Invalid request payload"})
 		return
 	}
+	if applicationsRequest.ApplicationStatus == models.ApplicationStatusRejected {
+		if err := models.ValidateRejection(applicationsRequest.RejectionReason, applicationsRequest.RejectionNote); err != nil {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+			return
+		}
+	}
 
-	application.ApplicationStatus = applicationsRequest.ApplicationStatus
-	c.JSON(http.StatusOK, gin.H{"application": application.ConvertToResponse()})
+	ac.transitionApplication(c, models.AuditActionUpdate, applicationsRequest.ApplicationStatus, models.ApplicationActionRequest{
+		RejectionReason: applicationsRequest.RejectionReason,
+		RejectionNote:   applicationsRequest.RejectionNote,
+	})
 }
 
 func (ac *ApplicationController) DeleteApplication(c *gin.Context) {
 	applicationID := c.Param("id")
 
 	// Fetch applicants and return 500 Internal Server Error on failure
-	if err := ac.DB.Where("id = ?", applicationID).First(&models.Applications{}).Error; err != nil {
+	var application models.Applications
+	if err := ac.DB.Where("id = ?", applicationID).First(&application).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			log.Printf("application with id: %s did not found, %v\n", applicationID, err)
 			c.JSON(http.StatusNotFound, gin.H{"error": "Application not found"})
@@ -196,8 +266,15 @@ func (ac *ApplicationController) DeleteApplication(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch Application"})
 		return
 	}
+	tx := ac.DB.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback() // Ensure rollback in case of panic
+		}
+	}()
 	// delete application
-	if err := ac.DB.Where("id = ?", applicationID).Delete(&models.Applications{}).Error; err != nil {
+	if err := tx.Where("id = ?", applicationID).Delete(&models.Applications{}).Error; err != nil {
+		tx.Rollback()
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			log.Printf("Database error deleting households belonging to applicant id: %v, %v\n", applicationID, err)
 			c.JSON(http.StatusNotFound, gin.H{"error": "Failed to delete applicant"})
@@ -207,5 +284,23 @@ func (ac *ApplicationController) DeleteApplication(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch applicants list"})
 		return
 	}
+	// a deleted application that was approved gives its capacity back to the scheme
+	if application.ApprovedVersion != nil {
+		if err := releaseApplication(tx, actorOf(c), application, "the application is deleted", true); err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete application"})
+			return
+		}
+	}
+	if err := recordAudit(tx, actorOf(c), models.AuditEntityApplication, applicationID, models.AuditActionDelete, application, nil); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete application"})
+		return
+	}
+	if err := tx.Commit().Error; err != nil {
+		log.Printf("Transaction commit failed: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Transaction commit failed"})
+		return
+	}
 	c.JSON(http.StatusOK, gin.H{"message": "OK"})
 }
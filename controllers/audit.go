@@ -0,0 +1,95 @@
+package controllers
+
+import (
+	"FASMS/models"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// the gin context key holding who makes the request
+const ActorKey = "actor"
+
+type AuditController struct {
+	DB *gorm.DB
+}
+
+func NewAuditController(db *gorm.DB) *AuditController {
+	return &AuditController{DB: db}
+}
+
+func (ac *AuditController) GetAuditLogs(c *gin.Context) {
+	var auditLogs []models.AuditLogs
+	var auditRequest models.GetAuditLogsRequest
+
+	// Bind JSON and return 422 Unprocessable Entity on failure
+	if err := c.ShouldBindQuery(&auditRequest); err != nil {
+		log.Printf("Invalid request payload: %v\n", err)
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Invalid request payload"})
+		return
+	}
+	// assign default value to pageSize
+	if auditRequest.PageSize == 0 {
+		auditRequest.PageSize = 10
+	}
+
+	var filter = ac.DB.Model(&models.AuditLogs{})
+	if auditRequest.EntityType != "" {
+		filter = filter.Where("entity_type = ?", auditRequest.EntityType)
+	}
+	if auditRequest.EntityID != "" {
+		filter = filter.Where("entity_id = ?", auditRequest.EntityID)
+	}
+	if auditRequest.Actor != "" {
+		filter = filter.Where("actor = ?", auditRequest.Actor)
+	}
+	if auditRequest.From != nil {
+		filter = filter.Where("created_at >= ?", *auditRequest.From)
+	}
+	if auditRequest.To != nil {
+		filter = filter.Where("created_at <= ?", *auditRequest.To)
+	}
+
+	var query = filter.Session(&gorm.Session{}).Offset(auditRequest.Page * auditRequest.PageSize).Limit(auditRequest.PageSize).Order("created_at desc, id")
+	if err := query.Find(&auditLogs).Error; err != nil {
+		log.Printf("Database error fetching audit logs: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch audit logs"})
+		return
+	}
+
+	var total int64
+	if err := filter.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		log.Printf("Database error counting total audit logs: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch total audit logs"})
+		return
+	}
+
+	ret := []models.AuditLogsResponse{}
+	for _, auditLog := range auditLogs {
+		ret = append(ret, auditLog.ConvertToResponse())
+	}
+	c.JSON(http.StatusOK, gin.H{"audit_logs": ret, "total": total})
+}
+
+// who makes the request, set by the authentication
+func actorOf(c *gin.Context) string {
+	if actor := c.GetString(ActorKey); actor != "" {
+		return actor
+	}
+	return "anonymous"
+}
+
+// appends an audit log in the caller's transaction, so that the change and its record are committed together
+func recordAudit(tx *gorm.DB, actor string, entityType string, entityID string, action string, before interface{}, after interface{}) error {
+	auditLog, err := models.NewAuditLog(actor, entityType, entityID, action, before, after)
+	if err != nil {
+		return err
+	}
+	if err := tx.Create(&auditLog).Error; err != nil {
+		log.Printf("record audit log of %s %s failed: %v\n", entityType, entityID, err)
+		return err
+	}
+	return nil
+}
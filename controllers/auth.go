@@ -0,0 +1,233 @@
+package controllers
+
+import (
+	"FASMS/models"
+	"FASMS/utils"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// the gin context keys holding the authenticated user or api key
+const (
+	UserIDKey = "user_id"
+	RoleKey   = "role"
+	ScopesKey = "scopes"
+)
+
+type AuthController struct {
+	DB     *gorm.DB
+	Secret []byte
+	TTL    time.Duration
+}
+
+func NewAuthController(db *gorm.DB, secret []byte, ttl time.Duration) *AuthController {
+	return &AuthController{DB: db, Secret: secret, TTL: ttl}
+}
+
+func (ac *AuthController) Login(c *gin.Context) {
+	var loginRequest models.LoginRequest
+
+	// Bind JSON and return 422 Unprocessable Entity on failure
+	if err := c.ShouldBindJSON(&loginRequest); err != nil {
+		log.Printf("Invalid request payload: %v\n", err)
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Invalid request payload"})
+		return
+	}
+
+	// the same error is returned for an unknown user and a wrong password, after the same bcrypt comparison
+	var user models.Users
+	if err := ac.DB.Where("username = ?", loginRequest.Username).First(&user).Error; err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			log.Printf("Database error fetching user: %v\n", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch user"})
+			return
+		}
+	}
+	if !user.CheckPassword(loginRequest.Password) {
+		log.Printf("failed login for user: %s\n", loginRequest.Username)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid username or password"})
+		return
+	}
+
+	claims := utils.NewClaims(user.ID, user.Username, user.Role, ac.TTL)
+	token, err := utils.SignJWT(claims, ac.Secret)
+	if err != nil {
+		log.Printf("sign token failed: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to issue token"})
+		return
+	}
+	c.JSON(http.StatusOK, models.LoginResponse{Token: token, ExpiresAt: claims.ExpiresAt, User: user.ConvertToResponse()})
+}
+
+func (ac *AuthController) GetCurrentUser(c *gin.Context) {
+	var user models.Users
+	if err := ac.DB.Where("id = ?", c.GetString(UserIDKey)).First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return
+		}
+		log.Printf("Database error fetching user: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch user"})
+		return
+	}
+	c.JSON(http.StatusOK, user.ConvertToResponse())
+}
+
+func (ac *AuthController) CreateUser(c *gin.Context) {
+	var userRequest models.CreateUserRequest
+
+	// Bind JSON and return 422 Unprocessable Entity on failure
+	if err := c.ShouldBindJSON(&userRequest); err != nil {
+		log.Printf("Invalid request payload: %v\n", err)
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Invalid request payload"})
+		return
+	}
+
+	// check if the user with same username exists
+	var existingUser models.Users
+	if err := ac.DB.Where("username = ?", userRequest.Username).First(&existingUser).Error; err == nil {
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("user with the same username: %v already exists", existingUser.Username)})
+		return
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	user, err := models.NewUser(userRequest.Username, userRequest.Password, userRequest.Role)
+	if err != nil {
+		log.Printf("hash password failed: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create user"})
+		return
+	}
+	tx := ac.DB.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback() // Ensure rollback in case of panic
+		}
+	}()
+	if err := tx.Create(&user).Error; err != nil {
+		tx.Rollback()
+		log.Printf("create user failed: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create user"})
+		return
+	}
+	// the response never holds the password hash
+	if err := recordAudit(tx, actorOf(c), models.AuditEntityUser, user.ID, models.AuditActionCreate, nil, user.ConvertToResponse()); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create user"})
+		return
+	}
+	if err := tx.Commit().Error; err != nil {
+		log.Printf("Transaction commit failed: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Transaction commit failed"})
+		return
+	}
+	c.JSON(http.StatusCreated, user.ConvertToResponse())
+}
+
+// validates the bearer token, or the api key of an integration, and keeps who calls in the gin context,
+// responds with 401 otherwise. the user of a token is loaded again on every request, so that a deleted user
+// is locked out and a changed role applies straight away instead of when the token expires
+func (ac *AuthController) Authenticate() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if key := c.GetHeader("X-API-Key"); key != "" {
+			ac.authenticateApiKey(c, key)
+			return
+		}
+
+		header := c.GetHeader("Authorization")
+		token, found := strings.CutPrefix(header, "Bearer ")
+		if !found || token == "" {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Missing bearer token"})
+			return
+		}
+		claims, err := utils.ParseJWT(token, ac.Secret)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": fmt.Sprintf("Invalid bearer token, %v", err)})
+			return
+		}
+		var user models.Users
+		if err := ac.DB.Where("id = ?", claims.Subject).First(&user).Error; err != nil {
+			if !errors.Is(err, gorm.ErrRecordNotFound) {
+				log.Printf("Database error fetching user: %v\n", err)
+				c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch user"})
+				return
+			}
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid bearer token, the user no longer exists"})
+			return
+		}
+		c.Set(UserIDKey, user.ID)
+		c.Set(ActorKey, user.Username)
+		c.Set(RoleKey, user.Role)
+		c.Next()
+	}
+}
+
+func (ac *AuthController) authenticateApiKey(c *gin.Context, key string) {
+	var apiKey models.ApiKeys
+	if err := ac.DB.Where("key_hash = ?", models.HashApiKey(key)).First(&apiKey).Error; err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			log.Printf("Database error fetching api key: %v\n", err)
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch api key"})
+			return
+		}
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid api key"})
+		return
+	}
+	if apiKey.IsRevoked() {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Api key is revoked"})
+		return
+	}
+
+	// the timestamp is only informative, a failure to record it does not fail the request
+	if err := ac.DB.Model(&apiKey).UpdateColumn("last_used_at", utils.Now()).Error; err != nil {
+		log.Printf("update last used of api key %s failed: %v\n", apiKey.ID, err)
+	}
+	c.Set(ActorKey, "api-key:"+apiKey.Name)
+	c.Set(RoleKey, apiKey.Role)
+	c.Set(ScopesKey, apiKey.GetScopes())
+	c.Next()
+}
+
+// lets the request through only for api keys scoped to the route group, users are not scoped
+func RequireScope(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		scopes, isApiKey := c.Get(ScopesKey)
+		if !isApiKey {
+			c.Next()
+			return
+		}
+		for _, allowed := range scopes.([]string) {
+			if allowed == scope {
+				c.Next()
+				return
+			}
+		}
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Api key is not scoped to " + scope})
+	}
+}
+
+// lets the request through only for the given roles, admins are always allowed
+func RequireRoles(roles ...uint) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		role := c.GetUint(RoleKey)
+		if role == models.RoleAdmin {
+			c.Next()
+			return
+		}
+		for _, allowed := range roles {
+			if role == allowed {
+				c.Next()
+				return
+			}
+		}
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Your role is not allowed to perform this action"})
+	}
+}
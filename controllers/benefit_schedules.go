@@ -0,0 +1,321 @@
+package controllers
+
+import (
+	"FASMS/models"
+	"FASMS/utils"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// the actor of the changes made by the scheduler
+const schedulerActor = "system:scheduler"
+
+type BenefitScheduleController struct {
+	DB        *gorm.DB
+	Scheduler *BenefitScheduler
+}
+
+func NewBenefitScheduleController(db *gorm.DB, scheduler *BenefitScheduler) *BenefitScheduleController {
+	return &BenefitScheduleController{DB: db, Scheduler: scheduler}
+}
+
+func (sc *BenefitScheduleController) GetBenefitSchedules(c *gin.Context) {
+	var schedules []models.BenefitSchedules
+	var schedulesRequest models.GetBenefitSchedulesRequest
+
+	// Bind JSON and return 422 Unprocessable Entity on failure
+	if err := c.ShouldBindQuery(&schedulesRequest); err != nil {
+		log.Printf("Invalid request payload: %v\n", err)
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Invalid request payload"})
+		return
+	}
+	// assign default value to pageSize
+	if schedulesRequest.PageSize == 0 {
+		schedulesRequest.PageSize = 10
+	}
+
+	var filter = sc.DB.Model(&models.BenefitSchedules{})
+	if schedulesRequest.ApplicationID != "" {
+		filter = filter.Where("application_id = ?", schedulesRequest.ApplicationID)
+	}
+	if schedulesRequest.Status != 0 {
+		filter = filter.Where("status = ?", schedulesRequest.Status)
+	}
+
+	var query = filter.Session(&gorm.Session{}).Offset(schedulesRequest.Page * schedulesRequest.PageSize).Limit(schedulesRequest.PageSize).Order("created_at desc, id")
+	if err := query.Find(&schedules).Error; err != nil {
+		log.Printf("Database error fetching benefit schedules: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch benefit schedules"})
+		return
+	}
+
+	var total int64
+	if err := filter.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		log.Printf("Database error counting total benefit schedules: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch total benefit schedules"})
+		return
+	}
+
+	ret := []models.BenefitSchedulesResponse{}
+	for _, schedule := range schedules {
+		ret = append(ret, schedule.ConvertToResponse())
+	}
+	c.JSON(http.StatusOK, gin.H{"schedules": ret, "total": total})
+}
+
+// materialises the due instalments now instead of waiting for the next tick of the scheduler
+func (sc *BenefitScheduleController) RunScheduler(c *gin.Context) {
+	created, err := sc.Scheduler.Run(utils.Now())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to run the scheduler"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"disbursements_created": created})
+}
+
+// BenefitScheduler creates the disbursements of the instalments that are due
+type BenefitScheduler struct {
+	DB *gorm.DB
+}
+
+func NewBenefitScheduler(db *gorm.DB) *BenefitScheduler {
+	return &BenefitScheduler{DB: db}
+}
+
+// runs the scheduler every interval in the background
+func (s *BenefitScheduler) Start(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			if created, err := s.Run(utils.Now()); err != nil {
+				log.Printf("benefit scheduler run failed: %v\n", err)
+			} else if created > 0 {
+				log.Printf("benefit scheduler created %d disbursements\n", created)
+			}
+			<-ticker.C
+		}
+	}()
+}
+
+// materialises every instalment due at now, each schedule in its own transaction so that a failing schedule
+// does not hold back the others. returns the number of disbursements created
+func (s *BenefitScheduler) Run(now time.Time) (int, error) {
+	var scheduleIDs []string
+	if err := s.DB.Model(&models.BenefitSchedules{}).
+		Where("status = ?", models.BenefitScheduleStatusActive).
+		Where("next_due_date <= ?", now).
+		Order("next_due_date, id").
+		Pluck("id", &scheduleIDs).Error; err != nil {
+		log.Printf("Database error fetching due benefit schedules: %v\n", err)
+		return 0, err
+	}
+
+	created := 0
+	var lastErr error
+	for _, scheduleID := range scheduleIDs {
+		count, err := s.runSchedule(scheduleID, now)
+		if err != nil {
+			log.Printf("benefit schedule %s failed: %v\n", scheduleID, err)
+			lastErr = err
+			continue
+		}
+		created += count
+	}
+	return created, lastErr
+}
+
+func (s *BenefitScheduler) runSchedule(scheduleID string, now time.Time) (int, error) {
+	tx := s.DB.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback() // Ensure rollback in case of panic
+		}
+	}()
+
+	// a schedule locked by another run of the scheduler is skipped, the other run takes care of it
+	var schedule models.BenefitSchedules
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+		Where("id = ?", scheduleID).
+		Where("status = ?", models.BenefitScheduleStatusActive).
+		First(&schedule).Error; err != nil {
+		tx.Rollback()
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return 0, nil
+		}
+		return 0, err
+	}
+
+	var application models.Applications
+	if err := tx.Preload("Scheme").
+		Preload("Scheme.CriteriaGroups.Criterias").
+		Preload("Scheme.CriteriaNodes").
+		Preload("Scheme.Relations").
+		Preload("Applicant").
+		Preload("Applicant.Households").
+		Preload("Applicant.Applications").
+		Where("id = ?", schedule.ApplicationID).First(&application).Error; err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		tx.Rollback()
+		return 0, err
+	}
+
+	created, err := materialiseDueInstalments(tx, schedulerActor, &schedule, application, now)
+	if err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+	if err := tx.Commit().Error; err != nil {
+		log.Printf("Transaction commit failed: %v\n", err)
+		return 0, err
+	}
+	return created, nil
+}
+
+// creates the schedules of an approved application from its entitlement and materialises the instalments already due.
+// the planned instalments are committed from the scheme's budget, with a beneficiary if newBeneficiary,
+// errCapacityReached is returned when the scheme can not take them.
+// an application approved again after a review keeps the schedules it has, only stopped schedules are replaced,
+// without the instalments they already materialised
+func createBenefitSchedules(tx *gorm.DB, actor string, application models.Applications, newBeneficiary bool) error {
+	var count int64
+	if err := tx.Model(&models.BenefitSchedules{}).
+		Where("application_id = ?", application.ID).
+		Where("status <> ?", models.BenefitScheduleStatusStopped).
+		Count(&count).Error; err != nil {
+		log.Printf("Database error counting schedules of application %s: %v\n", application.ID, err)
+		return err
+	}
+
+	now := utils.Now()
+	schedules := []models.BenefitSchedules{}
+	if entitlement := application.GetEntitlement(); count == 0 && entitlement != nil {
+		materialised, err := materialisedInstalments(tx, application.ID)
+		if err != nil {
+			return err
+		}
+		schedules = models.ResumeBenefitSchedules(models.NewBenefitSchedules(application, application.Scheme, *entitlement, now), application.Scheme, materialised)
+	}
+	if newBeneficiary || len(schedules) > 0 {
+		if err := reserveCapacity(tx, application.SchemeID, models.ScheduleCommitment(schedules), newBeneficiary); err != nil {
+			return err
+		}
+	}
+
+	for _, schedule := range schedules {
+		if err := tx.Create(&schedule).Error; err != nil {
+			log.Printf("create benefit schedule of application %s failed: %v\n", application.ID, err)
+			return err
+		}
+		if err := recordAudit(tx, actor, models.AuditEntitySchedule, schedule.ID, models.AuditActionCreate, nil, schedule); err != nil {
+			return err
+		}
+		if _, err := materialiseDueInstalments(tx, actor, &schedule, application, now); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// the number of instalments of each benefit the application's schedules materialised, the cancelled ones are not
+// counted as they will not be paid
+func materialisedInstalments(tx *gorm.DB, applicationID string) (map[string]uint, error) {
+	var counts []struct {
+		BenefitID string
+		Count     uint
+	}
+	if err := tx.Model(&models.Disbursements{}).
+		Select("benefit_id, count(*) AS count").
+		Where("application_id = ?", applicationID).
+		Where("schedule_id IS NOT NULL").
+		Where("status <> ?", models.DisbursementStatusCancelled).
+		Group("benefit_id").
+		Scan(&counts).Error; err != nil {
+		log.Printf("Database error counting disbursements of application %s: %v\n", applicationID, err)
+		return nil, err
+	}
+	materialised := make(map[string]uint)
+	for _, count := range counts {
+		materialised[count.BenefitID] = count.Count
+	}
+	return materialised, nil
+}
+
+// creates a pending disbursement, booked in the ledger, for each instalment of the schedule due at now.
+// the instalments of an application under review are held, the schedule stops once the application or the scheme
+// is no longer active or the stop condition is met before an instalment
+func materialiseDueInstalments(tx *gorm.DB, actor string, schedule *models.BenefitSchedules, application models.Applications, now time.Time) (int, error) {
+	before := *schedule
+	created := 0
+	for dueDate := schedule.DueInstalment(now); dueDate != nil; dueDate = schedule.DueInstalment(now) {
+		if application.ID == "" {
+			schedule.Stop("the application is deleted")
+			break
+		}
+		status := application.ApplicationStatus
+		if status == models.ApplicationStatusNeedReview || status == models.ApplicationStatusUnderReview {
+			break
+		}
+		if status != models.ApplicationStatusApproved && status != models.ApplicationStatusDisbursed {
+			schedule.Stop(fmt.Sprintf("the application is %s", models.ApplicationStatusName(status)))
+			break
+		}
+		if !application.Scheme.IsPublished() {
+			schedule.Stop("the scheme is no longer published")
+			break
+		}
+		if stop, reason := schedule.CheckStop(application.Applicant, application.Scheme, *dueDate); stop {
+			schedule.Stop(reason)
+			break
+		}
+
+		disbursement := schedule.Materialise(application)
+		if err := tx.Create(&disbursement).Error; err != nil {
+			log.Printf("create disbursement of schedule %s failed: %v\n", schedule.ID, err)
+			return 0, err
+		}
+		if err := postLedger(tx, disbursement, models.LedgerEventAccrued); err != nil {
+			return 0, err
+		}
+		if err := recordAudit(tx, actor, models.AuditEntityDisbursement, disbursement.ID, models.AuditActionCreate, nil, disbursement); err != nil {
+			return 0, err
+		}
+		created++
+	}
+
+	if schedule.Status == before.Status && schedule.NextInstalment == before.NextInstalment {
+		return created, nil
+	}
+	updateData := map[string]interface{}{
+		"next_instalment": schedule.NextInstalment,
+		"next_due_date":   schedule.NextDueDate,
+		"status":          schedule.Status,
+		"stop_reason":     schedule.StopReason,
+	}
+	if err := tx.Model(&models.BenefitSchedules{}).Where("id = ?", schedule.ID).Updates(updateData).Error; err != nil {
+		log.Printf("Error updating benefit schedule with id: %s, %v\n", schedule.ID, err)
+		return 0, err
+	}
+	if err := recordAudit(tx, actor, models.AuditEntitySchedule, schedule.ID, models.AuditActionUpdate, before, schedule); err != nil {
+		return 0, err
+	}
+	// the instalments a stopped schedule will not pay go back to the scheme's budget
+	if schedule.Status == models.BenefitScheduleStatusStopped {
+		if err := releaseCapacity(tx, actor, application.SchemeID, schedule.RemainingCommitment(), false); err != nil {
+			return 0, err
+		}
+	}
+	// a schedule that ended may leave nothing to pay, e.g. when it stops before its first instalment
+	if schedule.Status != models.BenefitScheduleStatusActive && application.ID != "" {
+		if err := markApplicationDisbursed(tx, actor, application.ID); err != nil {
+			return 0, err
+		}
+	}
+	return created, nil
+}
@@ -0,0 +1,599 @@
+package controllers
+
+import (
+	"FASMS/models"
+	"FASMS/utils"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type DisbursementController struct {
+	DB *gorm.DB
+}
+
+func NewDisbursementController(db *gorm.DB) *DisbursementController {
+	return &DisbursementController{DB: db}
+}
+
+func (dc *DisbursementController) GetDisbursements(c *gin.Context) {
+	var disbursements []models.Disbursements
+	var disbursementsRequest models.GetDisbursementsRequest
+
+	// Bind JSON and return 422 Unprocessable Entity on failure
+	if err := c.ShouldBindQuery(&disbursementsRequest); err != nil {
+		log.Printf("Invalid request payload: %v\n", err)
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Invalid request payload"})
+		return
+	}
+	// assign default value to pageSize
+	if disbursementsRequest.PageSize == 0 {
+		disbursementsRequest.PageSize = 10
+	}
+
+	var filter = dc.DB.Model(&models.Disbursements{})
+	if disbursementsRequest.ApplicantID != "" {
+		filter = filter.Where("applicant_id = ?", disbursementsRequest.ApplicantID)
+	}
+	if disbursementsRequest.SchemeID != "" {
+		filter = filter.Where("scheme_id = ?", disbursementsRequest.SchemeID)
+	}
+	if disbursementsRequest.ApplicationID != "" {
+		filter = filter.Where("application_id = ?", disbursementsRequest.ApplicationID)
+	}
+	if disbursementsRequest.PaymentRunID != "" {
+		filter = filter.Where("payment_run_id = ?", disbursementsRequest.PaymentRunID)
+	}
+	if disbursementsRequest.Status != 0 {
+		filter = filter.Where("status = ?", disbursementsRequest.Status)
+	}
+
+	var query = filter.Session(&gorm.Session{}).Offset(disbursementsRequest.Page * disbursementsRequest.PageSize).Limit(disbursementsRequest.PageSize).Order("created_at desc, id")
+	if err := query.Find(&disbursements).Error; err != nil {
+		log.Printf("Database error fetching disbursements: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch disbursements"})
+		return
+	}
+
+	var total int64
+	if err := filter.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		log.Printf("Database error counting total disbursements: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch total disbursements"})
+		return
+	}
+
+	ret := []models.DisbursementsResponse{}
+	for _, disbursement := range disbursements {
+		ret = append(ret, disbursement.ConvertToResponse())
+	}
+	c.JSON(http.StatusOK, gin.H{"disbursements": ret, "total": total})
+}
+
+func (dc *DisbursementController) GetDisbursementLedger(c *gin.Context) {
+	disbursementID := c.Param("id")
+
+	var disbursement models.Disbursements
+	if err := dc.DB.Where("id = ?", disbursementID).First(&disbursement).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			log.Printf("disbursement with id: %s did not found, %v\n", disbursementID, err)
+			c.JSON(http.StatusNotFound, gin.H{"error": "Disbursement not found"})
+			return
+		}
+		log.Printf("Database error fetching disbursement: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch disbursement"})
+		return
+	}
+
+	var entries []models.LedgerEntries
+	if err := dc.DB.Where("disbursement_id = ?", disbursementID).Order("created_at, journal_id, debit desc").Find(&entries).Error; err != nil {
+		log.Printf("Database error fetching ledger entries: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch ledger entries"})
+		return
+	}
+
+	ret := []models.LedgerEntriesResponse{}
+	for _, entry := range entries {
+		ret = append(ret, entry.ConvertToResponse())
+	}
+	c.JSON(http.StatusOK, gin.H{"disbursement": disbursement.ConvertToResponse(), "ledger_entries": ret})
+}
+
+func (dc *DisbursementController) GetPaymentRuns(c *gin.Context) {
+	var paymentRuns []models.PaymentRuns
+	var paymentRunsRequest models.GetPaymentRunsRequest
+
+	// Bind JSON and return 422 Unprocessable Entity on failure
+	if err := c.ShouldBindQuery(&paymentRunsRequest); err != nil {
+		log.Printf("Invalid request payload: %v\n", err)
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Invalid request payload"})
+		return
+	}
+	// assign default value to pageSize
+	if paymentRunsRequest.PageSize == 0 {
+		paymentRunsRequest.PageSize = 10
+	}
+
+	var filter = dc.DB.Model(&models.PaymentRuns{})
+	if paymentRunsRequest.Status != 0 {
+		filter = filter.Where("status = ?", paymentRunsRequest.Status)
+	}
+
+	var query = filter.Session(&gorm.Session{}).Offset(paymentRunsRequest.Page * paymentRunsRequest.PageSize).Limit(paymentRunsRequest.PageSize).Order("created_at desc, id")
+	if err := query.Find(&paymentRuns).Error; err != nil {
+		log.Printf("Database error fetching payment runs: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch payment runs"})
+		return
+	}
+
+	var total int64
+	if err := filter.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		log.Printf("Database error counting total payment runs: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch total payment runs"})
+		return
+	}
+
+	ret := []models.PaymentRunsResponse{}
+	for _, paymentRun := range paymentRuns {
+		ret = append(ret, paymentRun.ConvertToResponse())
+	}
+	c.JSON(http.StatusOK, gin.H{"payment_runs": ret, "total": total})
+}
+
+func (dc *DisbursementController) GetPaymentRun(c *gin.Context) {
+	paymentRunID := c.Param("id")
+
+	var paymentRun models.PaymentRuns
+	if err := dc.DB.Preload("Disbursements", func(db *gorm.DB) *gorm.DB {
+		return db.Order("created_at, id")
+	}).Where("id = ?", paymentRunID).First(&paymentRun).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			log.Printf("payment run with id: %s did not found, %v\n", paymentRunID, err)
+			c.JSON(http.StatusNotFound, gin.H{"error": "Payment run not found"})
+			return
+		}
+		log.Printf("Database error fetching payment run: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch payment run"})
+		return
+	}
+	c.JSON(http.StatusOK, paymentRun.ConvertToResponse())
+}
+
+// batches the pending disbursements of approved applications that are in no run yet. the disbursements are locked,
+// so two runs created at the same time can not batch the same disbursement
+func (dc *DisbursementController) CreatePaymentRun(c *gin.Context) {
+	var paymentRunRequest models.CreatePaymentRunRequest
+
+	// Bind JSON and return 422 Unprocessable Entity on failure
+	if err := c.ShouldBindJSON(&paymentRunRequest); err != nil {
+		log.Printf("Invalid request payload: %v\n", err)
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Invalid request payload"})
+		return
+	}
+	paymentRun := paymentRunRequest.ConvertToModel(actorOf(c))
+
+	tx := dc.DB.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback() // Ensure rollback in case of panic
+		}
+	}()
+
+	// disbursements of applications waiting for a review are held back
+	query := tx.Clauses(clause.Locking{Strength: "UPDATE", Table: clause.Table{Name: "disbursements"}}).
+		Joins("JOIN applications ON applications.id = disbursements.application_id AND applications.deleted_at IS NULL").
+		Where("disbursements.status = ?", models.DisbursementStatusPending).
+		Where("disbursements.payment_run_id IS NULL").
+		Where("disbursements.currency = ?", paymentRun.Currency).
+		Where("applications.application_status = ?", models.ApplicationStatusApproved)
+	if paymentRun.SchemeID != nil {
+		query = query.Where("disbursements.scheme_id = ?", *paymentRun.SchemeID)
+	}
+	var disbursements []models.Disbursements
+	if err := query.Order("disbursements.created_at, disbursements.id").Find(&disbursements).Error; err != nil {
+		tx.Rollback()
+		log.Printf("Database error fetching pending disbursements: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch pending disbursements"})
+		return
+	}
+	if len(disbursements) == 0 {
+		tx.Rollback()
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "No pending disbursements to pay"})
+		return
+	}
+
+	disbursementIDs := make([]string, 0, len(disbursements))
+	for i := range disbursements {
+		disbursementIDs = append(disbursementIDs, disbursements[i].ID)
+		disbursements[i].PaymentRunID = &paymentRun.ID
+		paymentRun.Total += disbursements[i].Amount
+	}
+	paymentRun.Count = len(disbursements)
+
+	if err := tx.Create(&paymentRun).Error; err != nil {
+		tx.Rollback()
+		log.Printf("create payment run failed: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create payment run"})
+		return
+	}
+	if err := tx.Model(&models.Disbursements{}).Where("id IN ?", disbursementIDs).Update("payment_run_id", paymentRun.ID).Error; err != nil {
+		tx.Rollback()
+		log.Printf("add disbursements to payment run failed: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create payment run"})
+		return
+	}
+	if err := recordAudit(tx, actorOf(c), models.AuditEntityPaymentRun, paymentRun.ID, models.AuditActionCreate, nil, paymentRun); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create payment run"})
+		return
+	}
+	if err := tx.Commit().Error; err != nil {
+		log.Printf("Transaction commit failed: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Transaction commit failed"})
+		return
+	}
+	paymentRun.Disbursements = disbursements
+	c.JSON(http.StatusCreated, paymentRun.ConvertToResponse())
+}
+
+// records the outcome of an open run, the failed disbursements can be retried in a later run.
+// the applications whose disbursements are all paid become disbursed
+func (dc *DisbursementController) SettlePaymentRun(c *gin.Context) {
+	paymentRunID := c.Param("id")
+	var settleRequest models.SettlePaymentRunRequest
+
+	// Bind JSON and return 422 Unprocessable Entity on failure
+	if err := c.ShouldBindJSON(&settleRequest); err != nil {
+		log.Printf("Invalid request payload: %v\n", err)
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Invalid request payload"})
+		return
+	}
+
+	tx := dc.DB.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback() // Ensure rollback in case of panic
+		}
+	}()
+
+	var paymentRun models.PaymentRuns
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", paymentRunID).First(&paymentRun).Error; err != nil {
+		tx.Rollback()
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			log.Printf("payment run with id: %s did not found, %v\n", paymentRunID, err)
+			c.JSON(http.StatusNotFound, gin.H{"error": "Payment run not found"})
+			return
+		}
+		log.Printf("Database error fetching payment run: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch payment run"})
+		return
+	}
+	if paymentRun.Status != models.PaymentRunStatusOpen {
+		tx.Rollback()
+		c.JSON(http.StatusConflict, gin.H{"error": "Payment run is already settled"})
+		return
+	}
+
+	var disbursements []models.Disbursements
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("payment_run_id = ?", paymentRunID).Order("created_at, id").Find(&disbursements).Error; err != nil {
+		tx.Rollback()
+		log.Printf("Database error fetching disbursements of payment run: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch disbursements"})
+		return
+	}
+	inRun := make(map[string]bool)
+	for _, disbursement := range disbursements {
+		inRun[disbursement.ID] = true
+	}
+	failureReasons := make(map[string]string)
+	for _, failed := range settleRequest.Failed {
+		if !inRun[failed.DisbursementID] {
+			tx.Rollback()
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": fmt.Sprintf("Disbursement %s is not in the payment run", failed.DisbursementID)})
+			return
+		}
+		failureReasons[failed.DisbursementID] = failed.Reason
+	}
+
+	settledAt := utils.Now()
+	paidApplications := make(map[string]bool)
+	// the scheme of the applications with a failed disbursement
+	failedApplications := make(map[string]string)
+	for _, disbursement := range disbursements {
+		if disbursement.Status != models.DisbursementStatusPending {
+			continue
+		}
+		before := disbursement
+		updateData := map[string]interface{}{}
+		action := models.LedgerEventPaid
+		if reason, failed := failureReasons[disbursement.ID]; failed {
+			action = "failed"
+			updateData["status"] = models.DisbursementStatusFailed
+			updateData["failure_reason"] = reason
+			disbursement.Status = models.DisbursementStatusFailed
+			disbursement.FailureReason = reason
+			failedApplications[disbursement.ApplicationID] = disbursement.SchemeID
+		} else {
+			updateData["status"] = models.DisbursementStatusPaid
+			updateData["paid_at"] = settledAt
+			disbursement.Status = models.DisbursementStatusPaid
+			disbursement.PaidAt = &settledAt
+			paidApplications[disbursement.ApplicationID] = true
+		}
+		if err := tx.Model(&models.Disbursements{}).Where("id = ?", disbursement.ID).Updates(updateData).Error; err != nil {
+			tx.Rollback()
+			log.Printf("Error updating disbursement with id: %s, %v\n", disbursement.ID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to settle payment run"})
+			return
+		}
+		if disbursement.Status == models.DisbursementStatusPaid {
+			if err := postLedger(tx, disbursement, models.LedgerEventPaid); err != nil {
+				tx.Rollback()
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to settle payment run"})
+				return
+			}
+		}
+		if err := recordAudit(tx, actorOf(c), models.AuditEntityDisbursement, disbursement.ID, action, before, disbursement); err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to settle payment run"})
+			return
+		}
+	}
+
+	before := paymentRun
+	paymentRun.Status = models.PaymentRunStatusSettled
+	paymentRun.SettledAt = &settledAt
+	if err := tx.Model(&paymentRun).Updates(map[string]interface{}{"status": paymentRun.Status, "settled_at": settledAt}).Error; err != nil {
+		tx.Rollback()
+		log.Printf("Error updating payment run with id: %s, %v\n", paymentRunID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to settle payment run"})
+		return
+	}
+	if err := recordAudit(tx, actorOf(c), models.AuditEntityPaymentRun, paymentRunID, "settle", before, paymentRun); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to settle payment run"})
+		return
+	}
+	// the failed disbursements of an application that left the scheme while the run was open are not retried
+	for applicationID, schemeID := range failedApplications {
+		if err := cancelLeftApplicationDisbursements(tx, actorOf(c), applicationID, schemeID); err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to settle payment run"})
+			return
+		}
+	}
+	for applicationID := range paidApplications {
+		if err := markApplicationDisbursed(tx, actorOf(c), applicationID); err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to settle payment run"})
+			return
+		}
+	}
+	if err := tx.Commit().Error; err != nil {
+		log.Printf("Transaction commit failed: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Transaction commit failed"})
+		return
+	}
+	paymentRun.Disbursements = disbursements
+	c.JSON(http.StatusOK, paymentRun.ConvertToResponse())
+}
+
+// a paid disbursement whose money came back, the ledger is reversed
+func (dc *DisbursementController) ReverseDisbursement(c *gin.Context) {
+	var reverseRequest models.ReverseDisbursementRequest
+
+	// Bind JSON and return 422 Unprocessable Entity on failure
+	if err := c.ShouldBindJSON(&reverseRequest); err != nil {
+		log.Printf("Invalid request payload: %v\n", err)
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Invalid request payload"})
+		return
+	}
+	dc.transitionDisbursement(c, "reverse", models.DisbursementStatusPaid, models.DisbursementStatusReversed, func(disbursement *models.Disbursements, updateData map[string]interface{}) {
+		updateData["reversal_note"] = reverseRequest.Note
+		disbursement.ReversalNote = reverseRequest.Note
+	})
+}
+
+// puts a failed disbursement back to pending, out of its run, so that the next run pays it
+func (dc *DisbursementController) RetryDisbursement(c *gin.Context) {
+	dc.transitionDisbursement(c, "retry", models.DisbursementStatusFailed, models.DisbursementStatusPending, func(disbursement *models.Disbursements, updateData map[string]interface{}) {
+		updateData["payment_run_id"] = nil
+		updateData["failure_reason"] = ""
+		disbursement.PaymentRunID = nil
+		disbursement.FailureReason = ""
+	})
+}
+
+// moves the disbursement from one status to the other, responds with 409 if it is not in the from status
+func (dc *DisbursementController) transitionDisbursement(c *gin.Context, action string, from uint, to uint, apply func(*models.Disbursements, map[string]interface{})) {
+	disbursementID := c.Param("id")
+
+	tx := dc.DB.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback() // Ensure rollback in case of panic
+		}
+	}()
+
+	var disbursement models.Disbursements
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", disbursementID).First(&disbursement).Error; err != nil {
+		tx.Rollback()
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			log.Printf("disbursement with id: %s did not found, %v\n", disbursementID, err)
+			c.JSON(http.StatusNotFound, gin.H{"error": "Disbursement not found"})
+			return
+		}
+		log.Printf("Database error fetching disbursement: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch disbursement"})
+		return
+	}
+	if disbursement.Status != from {
+		tx.Rollback()
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("Disbursement is %s, only a %s disbursement can %s", models.DisbursementStatusName(disbursement.Status), models.DisbursementStatusName(from), action)})
+		return
+	}
+
+	before := disbursement
+	updateData := map[string]interface{}{"status": to}
+	disbursement.Status = to
+	apply(&disbursement, updateData)
+	if err := tx.Model(&models.Disbursements{}).Where("id = ?", disbursementID).Updates(updateData).Error; err != nil {
+		tx.Rollback()
+		log.Printf("Error updating disbursement with id: %s, %v\n", disbursementID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update disbursement"})
+		return
+	}
+	if to == models.DisbursementStatusReversed {
+		if err := postLedger(tx, disbursement, models.LedgerEventReversed); err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update disbursement"})
+			return
+		}
+		// the money that came back goes back to the scheme's budget
+		if err := releaseCapacity(tx, actorOf(c), disbursement.SchemeID, disbursement.Amount, false); err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update disbursement"})
+			return
+		}
+	}
+	if err := recordAudit(tx, actorOf(c), models.AuditEntityDisbursement, disbursementID, action, before, disbursement); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update disbursement"})
+		return
+	}
+	if err := tx.Commit().Error; err != nil {
+		log.Printf("Transaction commit failed: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Transaction commit failed"})
+		return
+	}
+	c.JSON(http.StatusOK, disbursement.ConvertToResponse())
+}
+
+func postLedger(tx *gorm.DB, disbursement models.Disbursements, event string) error {
+	entries := models.LedgerJournalsFor(disbursement, event)
+	if err := tx.Create(&entries).Error; err != nil {
+		log.Printf("post %s ledger entries of disbursement %s failed: %v\n", event, disbursement.ID, err)
+		return err
+	}
+	return nil
+}
+
+// cancels the disbursements of an application leaving the scheme that are not going to be paid: the pending ones not
+// in a payment run yet and the failed ones. a pending disbursement in an open run is settled by the run.
+// the accrual is reversed in the ledger, the cancelled amount is returned for the caller to release
+func cancelUnpaidDisbursements(tx *gorm.DB, actor string, applicationID string, reason string) (utils.Money, error) {
+	var disbursements []models.Disbursements
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("application_id = ?", applicationID).
+		Where("(status = ? AND payment_run_id IS NULL) OR status = ?", models.DisbursementStatusPending, models.DisbursementStatusFailed).
+		Order("created_at, id").
+		Find(&disbursements).Error; err != nil {
+		log.Printf("Database error fetching unpaid disbursements of application %s: %v\n", applicationID, err)
+		return 0, err
+	}
+
+	var cancelled utils.Money
+	for _, disbursement := range disbursements {
+		before := disbursement
+		disbursement.Status = models.DisbursementStatusCancelled
+		disbursement.CancelReason = reason
+		if err := tx.Model(&models.Disbursements{}).Where("id = ?", disbursement.ID).Updates(map[string]interface{}{
+			"status":        disbursement.Status,
+			"cancel_reason": disbursement.CancelReason,
+		}).Error; err != nil {
+			log.Printf("Error updating disbursement with id: %s, %v\n", disbursement.ID, err)
+			return 0, err
+		}
+		if err := postLedger(tx, disbursement, models.LedgerEventCancelled); err != nil {
+			return 0, err
+		}
+		if err := recordAudit(tx, actor, models.AuditEntityDisbursement, disbursement.ID, "cancel", before, disbursement); err != nil {
+			return 0, err
+		}
+		cancelled += disbursement.Amount
+	}
+	return cancelled, nil
+}
+
+// cancels the unpaid disbursements of the application if it was rejected, withdrawn, closed or deleted,
+// and releases their amount
+func cancelLeftApplicationDisbursements(tx *gorm.DB, actor string, applicationID string, schemeID string) error {
+	var application models.Applications
+	if err := tx.Unscoped().Where("id = ?", applicationID).First(&application).Error; err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		log.Printf("Database error fetching application %s: %v\n", applicationID, err)
+		return err
+	}
+	reason := fmt.Sprintf("the application is %s", models.ApplicationStatusName(application.ApplicationStatus))
+	switch {
+	case application.ID == "" || application.DeletedAt.Valid:
+		reason = "the application is deleted"
+	case application.ApplicationStatus == models.ApplicationStatusRejected,
+		application.ApplicationStatus == models.ApplicationStatusWithdrawn,
+		application.ApplicationStatus == models.ApplicationStatusClosed:
+	default:
+		return nil
+	}
+	cancelled, err := cancelUnpaidDisbursements(tx, actor, applicationID, reason)
+	if err != nil {
+		return err
+	}
+	return releaseCapacity(tx, actor, schemeID, cancelled, false)
+}
+
+// an approved application is disbursed once none of its disbursements is left to pay and none of its schedules is active
+func markApplicationDisbursed(tx *gorm.DB, actor string, applicationID string) error {
+	var activeSchedules int64
+	if err := tx.Model(&models.BenefitSchedules{}).
+		Where("application_id = ?", applicationID).
+		Where("status = ?", models.BenefitScheduleStatusActive).
+		Count(&activeSchedules).Error; err != nil {
+		log.Printf("Database error counting active schedules of application %s: %v\n", applicationID, err)
+		return err
+	}
+	if activeSchedules > 0 {
+		return nil
+	}
+
+	var unpaid int64
+	if err := tx.Model(&models.Disbursements{}).
+		Where("application_id = ?", applicationID).
+		Where("status IN ?", []uint{models.DisbursementStatusPending, models.DisbursementStatusFailed}).
+		Count(&unpaid).Error; err != nil {
+		log.Printf("Database error counting unpaid disbursements of application %s: %v\n", applicationID, err)
+		return err
+	}
+	if unpaid > 0 {
+		return nil
+	}
+
+	var application models.Applications
+	if err := tx.Where("id = ?", applicationID).First(&application).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		log.Printf("Database error fetching application %s: %v\n", applicationID, err)
+		return err
+	}
+	if !models.CanTransitionApplication(application.ApplicationStatus, models.ApplicationStatusDisbursed) {
+		return nil
+	}
+
+	before := application
+	result := tx.Model(&models.Applications{}).
+		Where("id = ?", applicationID).
+		Where("application_status = ?", application.ApplicationStatus).
+		Update("application_status", models.ApplicationStatusDisbursed)
+	if result.Error != nil {
+		log.Printf("Error updating application with id: %s, %v\n", applicationID, result.Error)
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil
+	}
+	application.ApplicationStatus = models.ApplicationStatusDisbursed
+	return recordAudit(tx, actor, models.AuditEntityApplication, applicationID, "disburse", before, application)
+}
@@ -0,0 +1,50 @@
+package controllers
+
+import (
+	"FASMS/models"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// screens an applicant who is not registered against the schemes open today, the applicant only lives in memory
+// and nothing is written. the ids of the applicant and the households are generated for the check, the returned
+// applicant maps the household ids of the explanations back to the payload. the relations of the schemes are checked
+// against the applications the payload says the applicant holds
+func (sc *SchemeController) CheckEligibility(c *gin.Context) {
+	var schemes []models.Schemes
+	var applicantRequest models.CheckEligibilityRequest
+
+	// Bind JSON and return 422 Unprocessable Entity on failure
+	if err := c.ShouldBindJSON(&applicantRequest); err != nil {
+		log.Printf("Invalid request payload: %v\n", err)
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Invalid request payload"})
+		return
+	}
+	applicant := applicantRequest.ConvertToModel()
+
+	// Fetch schemes and return 500 Internal Server Error on failure
+	if err := sc.DB.Preload("CriteriaGroups.Criterias").Preload("CriteriaNodes").Preload("Benefits").Preload("Relations").Order("id").Find(&schemes).Error; err != nil {
+		log.Printf("Database error fetching scheem list: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch scheem list"})
+		return
+	}
+
+	ret := []models.SchemesResponse{}
+	explanations := []models.EligibilityTrace{}
+	eligibilityContext := models.NewEligibilityContext()
+	for _, scheme := range schemes {
+		// schemes not published or not taking applications today can not be applied for
+		if !scheme.IsPublished() || !scheme.IsOpenForApplication(eligibilityContext.Now) {
+			continue
+		}
+		trace := models.ExplainEligibility(applicant, scheme, eligibilityContext)
+		if trace.Eligible {
+			ret = append(ret, scheme.ConvertToResponse())
+		}
+		explanations = append(explanations, trace)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"applicant": applicant.ConvertToResponse(), "schemes": ret, "eligibility": explanations})
+}
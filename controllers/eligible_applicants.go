@@ -0,0 +1,157 @@
+package controllers
+
+import (
+	"FASMS/models"
+	"errors"
+	"log"
+	"net/http"
+	"runtime"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// the applicants are loaded eligibilityBatchSize at a time and evaluated by eligibilityWorkers goroutines,
+// so that only a batch of applicants is held in memory however many are registered
+const eligibilityBatchSize = 500
+
+var eligibilityWorkers = runtime.NumCPU()
+
+// lists the registered applicants eligible for the scheme today who have not applied for it
+func (sc *SchemeController) GetEligibleApplicants(c *gin.Context) {
+	schemeID := c.Param("id")
+	var scheme models.Schemes
+	var applicantsRequest models.GetEligibleApplicantsRequest
+
+	// Bind JSON and return 422 Unprocessable Entity on failure
+	if err := c.ShouldBindQuery(&applicantsRequest); err != nil {
+		log.Printf("Invalid request payload: %v\n", err)
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Invalid request payload"})
+		return
+	}
+	// assign default value to pageSize
+	if applicantsRequest.PageSize == 0 {
+		applicantsRequest.PageSize = 10
+	}
+
+	// Fetch scheme and return 500 Internal Server Error on failure
+	if err := sc.DB.Preload("CriteriaGroups.Criterias").Preload("CriteriaNodes").Preload("Benefits").Preload("Relations").Where("id = ?", schemeID).First(&scheme).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			log.Printf("scheme with id: %s did not found, %v\n", schemeID, err)
+			c.JSON(http.StatusNotFound, gin.H{"error": "Scheme not found"})
+			return
+		}
+		log.Printf("Database error fetching scheme: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch scheme"})
+		return
+	}
+	if !scheme.IsPublished() {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Scheme is not published"})
+		return
+	}
+
+	applicants, next, err := findEligibleApplicants(sc.DB, scheme, models.NewEligibilityContext(), applicantsRequest.After, applicantsRequest.PageSize)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to evaluate applicants"})
+		return
+	}
+
+	ret := []models.ApplicantsResponse{}
+	for _, applicant := range applicants {
+		ret = append(ret, applicant.ConvertToResponse())
+	}
+	c.JSON(http.StatusOK, gin.H{"applicants": ret, "next": next})
+}
+
+// the first limit applicants after the after id, in id order, eligible for the scheme without an application for it,
+// with the cursor of the next page, nil on the last page. the applicants are evaluated a batch at a time until the
+// page is full, so a page only evaluates the applicants up to its last one and the first one of the next page
+func findEligibleApplicants(db *gorm.DB, scheme models.Schemes, ctx models.EligibilityContext, after string, limit int) ([]models.Applicants, *string, error) {
+	eligible := []models.Applicants{}
+	for {
+		var batch []models.Applicants
+		if err := db.Preload("Households").
+			Preload("Applications").
+			Where("id > ?", after).
+			Where("id NOT IN (?)", db.Model(&models.Applications{}).Select("applicant_id").Where("scheme_id = ?", scheme.ID)).
+			Order("id").
+			Limit(eligibilityBatchSize).
+			Find(&batch).Error; err != nil {
+			log.Printf("Database error fetching applicants of scheme %s: %v\n", scheme.ID, err)
+			return nil, nil, err
+		}
+
+		isEligible := make([]bool, len(batch))
+		evaluateConcurrently(len(batch), func(i int) {
+			isEligible[i] = models.CheckEligiblity(batch[i], scheme, ctx)
+		})
+		for i, applicant := range batch {
+			if !isEligible[i] {
+				continue
+			}
+			// one more eligible applicant, there is a next page
+			if len(eligible) == limit {
+				next := eligible[len(eligible)-1].ID
+				return eligible, &next, nil
+			}
+			eligible = append(eligible, applicant)
+		}
+		if len(batch) < eligibilityBatchSize {
+			return eligible, nil, nil
+		}
+		after = batch[len(batch)-1].ID
+	}
+}
+
+// calls evaluate for 0 to n-1 on eligibilityWorkers goroutines, and returns once they are all done
+func evaluateConcurrently(n int, evaluate func(int)) {
+	jobs := make(chan int, n)
+	for i := 0; i < n; i++ {
+		jobs <- i
+	}
+	close(jobs)
+
+	var workers sync.WaitGroup
+	for i := 0; i < eligibilityWorkers; i++ {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			for job := range jobs {
+				evaluate(job)
+			}
+		}()
+	}
+	workers.Wait()
+}
+
+// runs evaluate on every applicant of the scope, with their households and applications. the applicants are loaded
+// batch by batch and fed to a pool of workers while the next batch is loaded, evaluate is called concurrently
+func forEachApplicant(db *gorm.DB, scope func(*gorm.DB) *gorm.DB, evaluate func(models.Applicants)) error {
+	jobs := make(chan models.Applicants, eligibilityBatchSize)
+
+	var workers sync.WaitGroup
+	for i := 0; i < eligibilityWorkers; i++ {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			for applicant := range jobs {
+				evaluate(applicant)
+			}
+		}()
+	}
+
+	var batch []models.Applicants
+	err := db.Preload("Households").
+		Preload("Applications").
+		Scopes(scope).
+		FindInBatches(&batch, eligibilityBatchSize, func(tx *gorm.DB, batchNumber int) error {
+			for _, applicant := range batch {
+				jobs <- applicant
+			}
+			return nil
+		}).Error
+	close(jobs)
+	workers.Wait()
+	return err
+}
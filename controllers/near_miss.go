@@ -0,0 +1,57 @@
+package controllers
+
+import (
+	"FASMS/models"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// the smallest changes that would make an applicant eligible for a scheme they fail
+func (sc *SchemeController) GetSchemeNearMiss(c *gin.Context) {
+	schemeID := c.Param("id")
+	var scheme models.Schemes
+	var applicant models.Applicants
+	var eligibilityRequest models.GetSchemeEligibilityRequest
+
+	// Bind JSON and return 422 Unprocessable Entity on failure
+	if err := c.ShouldBindQuery(&eligibilityRequest); err != nil {
+		log.Printf("Invalid request payload: %v\n", err)
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Invalid request payload"})
+		return
+	}
+
+	// Fetch applicants and return 500 Internal Server Error on failure
+	if err := sc.DB.Preload("Households").Preload("Applications").Where("id = ?", eligibilityRequest.ApplicantID).First(&applicant).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			log.Printf("applicant with id: %s did not found, %v\n", eligibilityRequest.ApplicantID, err)
+			c.JSON(http.StatusNotFound, gin.H{"error": "Applicant not found"})
+			return
+		}
+		log.Printf("Database error fetching applicants: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch applicants list"})
+		return
+	}
+
+	// Fetch scheme and return 500 Internal Server Error on failure
+	if err := sc.DB.Preload("CriteriaGroups.Criterias").Preload("CriteriaNodes").Preload("Benefits").Preload("Relations").Where("id = ?", schemeID).First(&scheme).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			log.Printf("scheme with id: %s did not found, %v\n", schemeID, err)
+			c.JSON(http.StatusNotFound, gin.H{"error": "Scheme not found"})
+			return
+		}
+		log.Printf("Database error fetching scheme: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch scheme"})
+		return
+	}
+
+	if !scheme.IsPublished() {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Scheme is not published"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"near_miss": models.AnalyseNearMiss(applicant, scheme, models.NewEligibilityContext())})
+}
@@ -0,0 +1,90 @@
+package controllers
+
+import (
+	"FASMS/models"
+	"FASMS/utils"
+	"log"
+
+	"gorm.io/gorm"
+)
+
+// re-runs the eligibility check of the applications matching the query inside the caller's transaction,
+// the applications whose outcome changed are flagged as need review with the reason.
+// applications that can not move to need review, e.g. rejected or closed ones, are settled and are not re-assessed
+func reassessApplications(tx *gorm.DB, actor string, reason string, query interface{}, args ...interface{}) error {
+	var applications []models.Applications
+	if err := tx.Preload("Scheme").
+		Preload("Scheme.CriteriaGroups.Criterias").
+		Preload("Scheme.CriteriaNodes").
+		Preload("Scheme.Relations").
+		Preload("Scheme.Benefits").
+		Preload("Applicant").
+		Preload("Applicant.Households").
+		Preload("Applicant.Applications").
+		Where(query, args...).
+		Where("application_status in (?)", reviewableStatuses()).
+		Find(&applications).Error; err != nil {
+		return err
+	}
+
+	evaluatedAt := utils.Now()
+	for _, application := range applications {
+		eligibility := models.ExplainEligibility(application.Applicant, application.Scheme, models.NewApplicationEligibilityContext(application.CreatedAt))
+		if eligibility.Eligible == application.IsEligible {
+			continue
+		}
+
+		log.Printf("application %s eligibility changed to %v: %s\n", application.ID, eligibility.Eligible, reason)
+		before := application
+		if err := tx.Model(&application).Updates(map[string]interface{}{
+			"application_status": models.ApplicationStatusNeedReview,
+			"is_eligible":        eligibility.Eligible,
+			"review_reason":      reason,
+		}).Error; err != nil {
+			return err
+		}
+		if err := recordAudit(tx, actor, models.AuditEntityApplication, application.ID, "need_review", before, application); err != nil {
+			return err
+		}
+		// the new outcome is kept as evidence next to the one the application was decided on
+		evidence, err := models.NewApplicationEvidence(application.ID, application.Applicant, application.Scheme, eligibility, evaluatedAt)
+		if err != nil {
+			return err
+		}
+		if err := tx.Create(&evidence).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// flags the applications matching the query as need review when the applicant or the scheme they refer to is deleted,
+// the applications are kept so that the decisions made on them stay on record
+func flagApplicationsForReview(tx *gorm.DB, actor string, reason string, query interface{}, args ...interface{}) (int64, error) {
+	var applications []models.Applications
+	if err := tx.Where(query, args...).
+		Where("application_status in (?)", reviewableStatuses()).
+		Find(&applications).Error; err != nil {
+		return 0, err
+	}
+
+	for _, application := range applications {
+		before := application
+		if err := tx.Model(&application).Updates(map[string]interface{}{
+			"application_status": models.ApplicationStatusNeedReview,
+			"is_eligible":        false,
+			"review_reason":      reason,
+		}).Error; err != nil {
+			return 0, err
+		}
+		if err := recordAudit(tx, actor, models.AuditEntityApplication, application.ID, "need_review", before, application); err != nil {
+			return 0, err
+		}
+	}
+	return int64(len(applications)), nil
+}
+
+// the statuses of the applications that can be flagged as need review, including the ones already flagged
+func reviewableStatuses() []uint {
+	return append(models.ApplicationStatusesTransitioningTo(models.ApplicationStatusNeedReview), models.ApplicationStatusNeedReview)
+}
@@ -0,0 +1,267 @@
+package controllers
+
+import (
+	"FASMS/models"
+	"FASMS/utils"
+	"errors"
+	"log"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// returned when the scheme's budget or maximum number of beneficiaries can not take an approval
+var errCapacityReached = errors.New("the scheme has reached its budget or its maximum number of beneficiaries")
+
+// locks the scheme row until the end of the transaction, the budget and the beneficiaries are only changed under this lock
+// so that concurrent approvals can not overspend
+func lockScheme(tx *gorm.DB, schemeID string) (models.Schemes, error) {
+	var scheme models.Schemes
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", schemeID).First(&scheme).Error; err != nil {
+		log.Printf("Database error locking scheme %s: %v\n", schemeID, err)
+		return scheme, err
+	}
+	return scheme, nil
+}
+
+// commits the amount from the scheme's budget, and takes a beneficiary if newBeneficiary.
+// returns errCapacityReached when the scheme can not take it
+func reserveCapacity(tx *gorm.DB, schemeID string, commitment utils.Money, newBeneficiary bool) error {
+	scheme, err := lockScheme(tx, schemeID)
+	if err != nil {
+		return err
+	}
+	if !scheme.HasCapacity(commitment, newBeneficiary) {
+		return errCapacityReached
+	}
+	updateData := map[string]interface{}{
+		"budget_committed": gorm.Expr("budget_committed + ?", commitment),
+	}
+	if newBeneficiary {
+		updateData["beneficiaries"] = gorm.Expr("beneficiaries + 1")
+	}
+	if err := tx.Model(&models.Schemes{}).Where("id = ?", schemeID).Updates(updateData).Error; err != nil {
+		log.Printf("reserve capacity of scheme %s failed: %v\n", schemeID, err)
+		return err
+	}
+	return nil
+}
+
+// gives the amount back to the scheme's budget, and a beneficiary if releaseBeneficiary, then promotes the waitlist
+func releaseCapacity(tx *gorm.DB, actor string, schemeID string, commitment utils.Money, releaseBeneficiary bool) error {
+	if commitment <= 0 && !releaseBeneficiary {
+		return nil
+	}
+	if _, err := lockScheme(tx, schemeID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	}
+	// the counters of the applications approved before the scheme had a budget are not known, so they stop at zero
+	updateData := map[string]interface{}{
+		"budget_committed": gorm.Expr("GREATEST(budget_committed - ?, 0)", commitment),
+	}
+	if releaseBeneficiary {
+		updateData["beneficiaries"] = gorm.Expr("GREATEST(beneficiaries - 1, 0)")
+	}
+	if err := tx.Model(&models.Schemes{}).Where("id = ?", schemeID).Updates(updateData).Error; err != nil {
+		log.Printf("release capacity of scheme %s failed: %v\n", schemeID, err)
+		return err
+	}
+	return promoteWaitlist(tx, actor, schemeID)
+}
+
+// stops the active schedules of an application leaving the scheme, cancels its unpaid disbursements and releases
+// what it has not been paid yet. the beneficiary is released too if releaseBeneficiary, a closed application keeps it
+func releaseApplication(tx *gorm.DB, actor string, application models.Applications, reason string, releaseBeneficiary bool) error {
+	var schedules []models.BenefitSchedules
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("application_id = ?", application.ID).
+		Where("status = ?", models.BenefitScheduleStatusActive).
+		Find(&schedules).Error; err != nil {
+		log.Printf("Database error fetching schedules of application %s: %v\n", application.ID, err)
+		return err
+	}
+
+	var released utils.Money
+	for _, schedule := range schedules {
+		before := schedule
+		released += schedule.RemainingCommitment()
+		schedule.Stop(reason)
+		if err := tx.Model(&models.BenefitSchedules{}).Where("id = ?", schedule.ID).Updates(map[string]interface{}{
+			"next_due_date": schedule.NextDueDate,
+			"status":        schedule.Status,
+			"stop_reason":   schedule.StopReason,
+		}).Error; err != nil {
+			log.Printf("Error updating benefit schedule with id: %s, %v\n", schedule.ID, err)
+			return err
+		}
+		if err := recordAudit(tx, actor, models.AuditEntitySchedule, schedule.ID, models.AuditActionUpdate, before, schedule); err != nil {
+			return err
+		}
+	}
+	cancelled, err := cancelUnpaidDisbursements(tx, actor, application.ID, reason)
+	if err != nil {
+		return err
+	}
+	return releaseCapacity(tx, actor, application.SchemeID, released+cancelled, releaseBeneficiary)
+}
+
+// moves the waitlisted applications of the scheme back to submitted, first come first served, as long as the scheme
+// can take them next to the applications promoted before and not decided yet. the promoted applications do not
+// reserve anything, they stay ahead of new applications until they are decided and their approval checks the capacity
+func promoteWaitlist(tx *gorm.DB, actor string, schemeID string) error {
+	scheme, err := lockScheme(tx, schemeID)
+	if err != nil {
+		return err
+	}
+	if !scheme.IsPublished() {
+		return nil
+	}
+	if err := tx.Preload("CriteriaGroups.Criterias").Preload("CriteriaNodes").Preload("Benefits").Preload("Relations").Where("id = ?", schemeID).First(&scheme).Error; err != nil {
+		log.Printf("Database error fetching scheme %s: %v\n", schemeID, err)
+		return err
+	}
+	scheme, err = withPromotedApplications(tx, scheme)
+	if err != nil {
+		return err
+	}
+
+	var applications []models.Applications
+	if err := tx.Preload("Applicant").
+		Preload("Applicant.Households").
+		Where("scheme_id = ?", schemeID).
+		Where("application_status = ?", models.ApplicationStatusWaitlisted).
+		Order("created_at, id").
+		Find(&applications).Error; err != nil {
+		log.Printf("Database error fetching waitlist of scheme %s: %v\n", schemeID, err)
+		return err
+	}
+
+	now := utils.Now()
+	for _, application := range applications {
+		// an application that can not be estimated stays on the waitlist, it does not fail the release that triggered the promotion
+		commitment, err := models.EstimateCommitment(application.Applicant, scheme, models.NewApplicationEligibilityContext(application.CreatedAt), now)
+		if err != nil {
+			log.Printf("estimate commitment of application %s failed, it stays waitlisted: %v\n", application.ID, err)
+			continue
+		}
+		if !scheme.HasCapacity(commitment, true) {
+			break
+		}
+
+		before := application
+		result := tx.Model(&models.Applications{}).
+			Where("id = ?", application.ID).
+			Where("application_status = ?", models.ApplicationStatusWaitlisted).
+			Updates(map[string]interface{}{"application_status": models.ApplicationStatusSubmitted, "promoted_at": now})
+		if result.Error != nil {
+			log.Printf("Error updating application with id: %s, %v\n", application.ID, result.Error)
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			continue
+		}
+		// the scheme is a copy, the promoted applications are counted as if they were approved
+		scheme.BudgetCommitted += commitment
+		scheme.Beneficiaries++
+		application.ApplicationStatus = models.ApplicationStatusSubmitted
+		application.PromotedAt = &now
+		if err := recordAudit(tx, actor, models.AuditEntityApplication, application.ID, "promote", before, application); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// the scheme, with its criterias and benefits loaded, with the applications promoted from its waitlist and not decided
+// yet counted in a copy of its counters as if they were approved, so that they stay ahead of new applications.
+// an application that can not be estimated is logged and not counted
+func withPromotedApplications(tx *gorm.DB, scheme models.Schemes) (models.Schemes, error) {
+	var applications []models.Applications
+	if err := tx.Preload("Applicant").
+		Preload("Applicant.Households").
+		Where("scheme_id = ?", scheme.ID).
+		Where("promoted_at IS NOT NULL").
+		Where("application_status in (?)", models.PromotedPendingStatuses()).
+		Find(&applications).Error; err != nil {
+		log.Printf("Database error fetching promoted applications of scheme %s: %v\n", scheme.ID, err)
+		return scheme, err
+	}
+
+	now := utils.Now()
+	for _, application := range applications {
+		commitment, err := models.EstimateCommitment(application.Applicant, scheme, models.NewApplicationEligibilityContext(application.CreatedAt), now)
+		if err != nil {
+			log.Printf("estimate commitment of promoted application %s failed: %v\n", application.ID, err)
+			continue
+		}
+		scheme.BudgetCommitted += commitment
+		scheme.Beneficiaries++
+	}
+	return scheme, nil
+}
+
+// whether a new application committing the amount goes to the waitlist, either because the scheme can not take it
+// next to the promoted applications not decided yet, or because earlier applications are already waiting
+func shouldWaitlist(tx *gorm.DB, scheme models.Schemes, commitment utils.Money) (bool, error) {
+	var waiting int64
+	if err := tx.Model(&models.Applications{}).
+		Where("scheme_id = ?", scheme.ID).
+		Where("application_status = ?", models.ApplicationStatusWaitlisted).
+		Count(&waiting).Error; err != nil {
+		log.Printf("Database error counting waitlist of scheme %s: %v\n", scheme.ID, err)
+		return false, err
+	}
+	if waiting > 0 {
+		return true, nil
+	}
+	scheme, err := withPromotedApplications(tx, scheme)
+	if err != nil {
+		return false, err
+	}
+	return !scheme.HasCapacity(commitment, true), nil
+}
+
+// moves an application whose approval did not fit in the scheme to the waitlist, in its own transaction
+// since the one of the approval is rolled back
+func waitlistApplication(db *gorm.DB, actor string, application models.Applications) error {
+	from := application.ApplicationStatus
+	if !models.CanTransitionApplication(from, models.ApplicationStatusWaitlisted) {
+		return nil
+	}
+
+	tx := db.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback() // Ensure rollback in case of panic
+		}
+	}()
+	before := application
+	result := tx.Model(&models.Applications{}).
+		Where("id = ?", application.ID).
+		Where("application_status = ?", from).
+		Updates(map[string]interface{}{"application_status": models.ApplicationStatusWaitlisted, "review_reason": "", "promoted_at": nil})
+	if result.Error != nil {
+		tx.Rollback()
+		log.Printf("Error updating application with id: %s, %v\n", application.ID, result.Error)
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		tx.Rollback()
+		return nil
+	}
+	application.ApplicationStatus = models.ApplicationStatusWaitlisted
+	application.ReviewReason = ""
+	application.PromotedAt = nil
+	if err := recordAudit(tx, actor, models.AuditEntityApplication, application.ID, "waitlist", before, application); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Commit().Error; err != nil {
+		log.Printf("Transaction commit failed: %v\n", err)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,277 @@
+package controllers
+
+import (
+	"FASMS/models"
+	"FASMS/utils"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// a scheme moves draft -> pending approval -> published -> retired,
+// a published scheme stays published while a new version of it goes through draft and pending approval again
+
+// submits the draft version of the scheme for approval
+func (sc *SchemeController) SubmitScheme(c *gin.Context) {
+	tx, scheme, latestVersion, ok := sc.beginSchemeTransition(c)
+	if !ok {
+		return
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback() // Ensure rollback in case of panic
+		}
+	}()
+	before := schemeTransitionSnapshot(scheme, latestVersion)
+	if scheme.Status == models.SchemeStatusRetired || latestVersion.Status != models.SchemeVersionDraft {
+		tx.Rollback()
+		c.JSON(http.StatusConflict, gin.H{"error": "Scheme has no draft version to submit"})
+		return
+	}
+
+	latestVersion.Status = models.SchemeVersionPendingApproval
+	if scheme.Status == models.SchemeStatusDraft {
+		scheme.Status = models.SchemeStatusPendingApproval
+	}
+	if err := saveSchemeTransition(tx, &scheme, &latestVersion); err != nil {
+		tx.Rollback()
+		log.Printf("submit scheme %s failed: %v\n", scheme.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to submit scheme"})
+		return
+	}
+	sc.commitSchemeTransition(c, tx, "submit", before, scheme, latestVersion)
+}
+
+// sends the version pending approval back to draft
+func (sc *SchemeController) RejectScheme(c *gin.Context) {
+	tx, scheme, latestVersion, ok := sc.beginSchemeTransition(c)
+	if !ok {
+		return
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback() // Ensure rollback in case of panic
+		}
+	}()
+	before := schemeTransitionSnapshot(scheme, latestVersion)
+	if scheme.Status == models.SchemeStatusRetired || latestVersion.Status != models.SchemeVersionPendingApproval {
+		tx.Rollback()
+		c.JSON(http.StatusConflict, gin.H{"error": "Scheme has no version pending approval"})
+		return
+	}
+
+	latestVersion.Status = models.SchemeVersionDraft
+	if scheme.Status == models.SchemeStatusPendingApproval {
+		scheme.Status = models.SchemeStatusDraft
+	}
+	if err := saveSchemeTransition(tx, &scheme, &latestVersion); err != nil {
+		tx.Rollback()
+		log.Printf("reject scheme %s failed: %v\n", scheme.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reject scheme"})
+		return
+	}
+	sc.commitSchemeTransition(c, tx, "reject", before, scheme, latestVersion)
+}
+
+// validates the version pending approval and applies it to the scheme,
+// the previously published version becomes superseded
+func (sc *SchemeController) PublishScheme(c *gin.Context) {
+	tx, scheme, latestVersion, ok := sc.beginSchemeTransition(c)
+	if !ok {
+		return
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback() // Ensure rollback in case of panic
+		}
+	}()
+	before := schemeTransitionSnapshot(scheme, latestVersion)
+	if scheme.Status == models.SchemeStatusRetired || latestVersion.Status != models.SchemeVersionPendingApproval {
+		tx.Rollback()
+		c.JSON(http.StatusConflict, gin.H{"error": "Scheme has no version pending approval"})
+		return
+	}
+
+	definition, err := latestVersion.GetDefinition()
+	if err != nil {
+		tx.Rollback()
+		log.Printf("decode scheme %s version %d failed: %v\n", scheme.ID, latestVersion.Version, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read scheme version"})
+		return
+	}
+	// the rules may have changed since the version was saved, so it is validated again
+	if isvalidScheem, err := definition.IsValidScheme(); !isvalidScheem {
+		tx.Rollback()
+		log.Printf("scheme %s version %d is not valid: %v\n", scheme.ID, latestVersion.Version, err)
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": fmt.Sprintf("Scheme version is not valid, %v", err.Error())})
+		return
+	}
+	if err := definition.CompileExpressions(); err != nil {
+		tx.Rollback()
+		log.Printf("scheme %s version %d has invalid expression: %v\n", scheme.ID, latestVersion.Version, err)
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	}
+	// a related scheme may have been deleted since the version was saved
+	if err := checkRelatedSchemes(tx, scheme.ID, definition.Relations); err != nil {
+		tx.Rollback()
+		if errors.Is(err, errInvalidRelation) {
+			log.Printf("scheme %s version %d has invalid relation: %v\n", scheme.ID, latestVersion.Version, err)
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check related schemes"})
+		return
+	}
+
+	if err := applySchemeDefinition(tx, &scheme, definition); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to publish scheme"})
+		return
+	}
+	if err := tx.Model(&models.SchemeVersions{}).
+		Where("scheme_id = ?", scheme.ID).
+		Where("status = ?", models.SchemeVersionPublished).
+		Update("status", models.SchemeVersionSuperseded).Error; err != nil {
+		tx.Rollback()
+		log.Printf("supersede published versions of scheme %s failed: %v\n", scheme.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to publish scheme"})
+		return
+	}
+
+	publishedAt := utils.Now()
+	latestVersion.Status = models.SchemeVersionPublished
+	latestVersion.PublishedAt = &publishedAt
+	scheme.Status = models.SchemeStatusPublished
+	scheme.CurrentVersion = latestVersion.Version
+	if err := saveSchemeTransition(tx, &scheme, &latestVersion); err != nil {
+		tx.Rollback()
+		log.Printf("publish scheme %s version %d failed: %v\n", scheme.ID, latestVersion.Version, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to publish scheme"})
+		return
+	}
+	// the applications of the scheme are re-assessed against the published rules
+	if err := reassessApplications(tx, actorOf(c), fmt.Sprintf("scheme version %d published", latestVersion.Version), "scheme_id = ?", scheme.ID); err != nil {
+		tx.Rollback()
+		log.Printf("reassess applications of scheme %s failed: %v\n", scheme.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reassess applications"})
+		return
+	}
+	// the published version may have raised the budget or the maximum number of beneficiaries
+	if err := promoteWaitlist(tx, actorOf(c), scheme.ID); err != nil {
+		tx.Rollback()
+		log.Printf("promote waitlist of scheme %s failed: %v\n", scheme.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to promote waitlisted applications"})
+		return
+	}
+	sc.commitSchemeTransition(c, tx, "publish", before, scheme, latestVersion)
+}
+
+// withdraws a published scheme, it is no longer considered for eligibility and new applications
+func (sc *SchemeController) RetireScheme(c *gin.Context) {
+	tx, scheme, latestVersion, ok := sc.beginSchemeTransition(c)
+	if !ok {
+		return
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback() // Ensure rollback in case of panic
+		}
+	}()
+	before := schemeTransitionSnapshot(scheme, latestVersion)
+	if scheme.Status != models.SchemeStatusPublished {
+		tx.Rollback()
+		c.JSON(http.StatusConflict, gin.H{"error": "Only a published scheme can be retired"})
+		return
+	}
+	// publishing the pending version would make the retired scheme live again
+	if latestVersion.Status == models.SchemeVersionPendingApproval {
+		tx.Rollback()
+		c.JSON(http.StatusConflict, gin.H{"error": "Scheme has a version pending approval, reject it before retiring the scheme"})
+		return
+	}
+
+	scheme.Status = models.SchemeStatusRetired
+	if err := tx.Model(&scheme).Update("status", scheme.Status).Error; err != nil {
+		tx.Rollback()
+		log.Printf("retire scheme %s failed: %v\n", scheme.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retire scheme"})
+		return
+	}
+	sc.commitSchemeTransition(c, tx, "retire", before, scheme, latestVersion)
+}
+
+// locks the scheme and loads it with its latest version in a transaction, on failure the response is written and ok is false.
+// the lock makes two transitions of the same scheme, or a transition and a capacity change, run one after the other
+func (sc *SchemeController) beginSchemeTransition(c *gin.Context) (tx *gorm.DB, scheme models.Schemes, latestVersion models.SchemeVersions, ok bool) {
+	schemeID := c.Param("id")
+
+	tx = sc.DB.Begin()
+	if _, err := lockScheme(tx, schemeID); err != nil {
+		tx.Rollback()
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			log.Printf("scheme with id: %s did not found, %v\n", schemeID, err)
+			c.JSON(http.StatusNotFound, gin.H{"error": "Scheme not found"})
+			return tx, scheme, latestVersion, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch scheme"})
+		return tx, scheme, latestVersion, false
+	}
+	if err := tx.Preload("CriteriaGroups.Criterias").Preload("CriteriaNodes").Preload("Benefits").Preload("Relations").Where("id = ?", schemeID).First(&scheme).Error; err != nil {
+		tx.Rollback()
+		log.Printf("Database error fetching scheme: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch scheme"})
+		return tx, scheme, latestVersion, false
+	}
+
+	if err := ensureInitialVersion(tx, &scheme); err != nil {
+		tx.Rollback()
+		log.Printf("create initial version of scheme %s failed: %v\n", schemeID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch scheme version"})
+		return tx, scheme, latestVersion, false
+	}
+	if err := tx.Where("scheme_id = ?", schemeID).Order("version desc").First(&latestVersion).Error; err != nil {
+		tx.Rollback()
+		log.Printf("Database error fetching scheme version: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch scheme version"})
+		return tx, scheme, latestVersion, false
+	}
+	return tx, scheme, latestVersion, true
+}
+
+func saveSchemeTransition(tx *gorm.DB, scheme *models.Schemes, version *models.SchemeVersions) error {
+	if err := tx.Model(version).Updates(map[string]interface{}{
+		"status":       version.Status,
+		"published_at": version.PublishedAt,
+	}).Error; err != nil {
+		return err
+	}
+	return tx.Model(scheme).Updates(map[string]interface{}{
+		"status":          scheme.Status,
+		"current_version": scheme.CurrentVersion,
+	}).Error
+}
+
+// the scheme and its latest version as recorded in the audit log of a transition
+func schemeTransitionSnapshot(scheme models.Schemes, version models.SchemeVersions) gin.H {
+	return gin.H{"scheme": scheme.ConvertToResponse(), "version": version.ConvertToResponse()}
+}
+
+func (sc *SchemeController) commitSchemeTransition(c *gin.Context, tx *gorm.DB, action string, before gin.H, scheme models.Schemes, version models.SchemeVersions) {
+	if err := recordAudit(tx, actorOf(c), models.AuditEntityScheme, scheme.ID, action, before, schemeTransitionSnapshot(scheme, version)); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record scheme transition"})
+		return
+	}
+	if err := tx.Commit().Error; err != nil {
+		tx.Rollback()
+		log.Printf("Transaction commit failed: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Transaction commit failed"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"scheme": scheme.ConvertToResponse(), "version": version.ConvertToResponse()})
+}
@@ -0,0 +1,100 @@
+package controllers
+
+import (
+	"FASMS/models"
+	"FASMS/utils"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// evaluates a scheme definition against every applicant and the scheme's applications as if it replaced the current one,
+// nothing is written
+func (sc *SchemeController) SimulateScheme(c *gin.Context) {
+	schemeID := c.Param("id")
+	var scheme models.Schemes
+	var proposedScheme models.CreateSchemesRequest
+
+	// Bind JSON and return 422 Unprocessable Entity on failure
+	if err := c.ShouldBindJSON(&proposedScheme); err != nil {
+		log.Printf("Invalid request payload: %v\n", err)
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Invalid request payload"})
+		return
+	}
+	if isvalidScheem, err := proposedScheme.IsValidScheme(); !isvalidScheem {
+		log.Printf("Proposed scheme is not valid: %v\n", err)
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": fmt.Sprintf("Proposed scheme is not valid, %v", err.Error())})
+		return
+	}
+	if err := proposedScheme.CompileExpressions(); err != nil {
+		log.Printf("Proposed scheme has invalid expression: %v\n", err)
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	}
+	if err := checkRelatedSchemes(sc.DB, schemeID, proposedScheme.Relations); err != nil {
+		if errors.Is(err, errInvalidRelation) {
+			log.Printf("Proposed scheme has invalid relation: %v\n", err)
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check related schemes"})
+		return
+	}
+
+	// Fetch scheme and return 500 Internal Server Error on failure
+	if err := sc.DB.Preload("CriteriaGroups.Criterias").Preload("CriteriaNodes").Preload("Benefits").Preload("Relations").Where("id = ?", schemeID).First(&scheme).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			log.Printf("scheme with id: %s did not found, %v\n", schemeID, err)
+			c.JSON(http.StatusNotFound, gin.H{"error": "Scheme not found"})
+			return
+		}
+		log.Printf("Database error fetching scheme: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch scheme"})
+		return
+	}
+
+	proposed := proposedScheme.ConvertToSimulation(scheme)
+	simulation := models.NewSchemeSimulation(scheme, proposed, utils.Now())
+
+	// every registered applicant, evaluated today
+	var mutex sync.Mutex
+	ctx := models.EligibilityContext{Now: simulation.EvaluatedAt}
+	allApplicants := func(query *gorm.DB) *gorm.DB { return query }
+	if err := forEachApplicant(sc.DB, allApplicants, func(applicant models.Applicants) {
+		before := models.CheckEligiblity(applicant, scheme, ctx)
+		after := models.CheckEligiblity(applicant, proposed, ctx)
+		mutex.Lock()
+		simulation.Applicants.Add(before, after)
+		mutex.Unlock()
+	}); err != nil {
+		log.Printf("Database error fetching applicants: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to evaluate applicants"})
+		return
+	}
+
+	// the scheme's applications, evaluated as of their application date
+	var applications []models.Applications
+	if err := sc.DB.Preload("Applicant").
+		Preload("Applicant.Households").
+		Preload("Applicant.Applications").
+		Where("scheme_id = ?", schemeID).
+		FindInBatches(&applications, eligibilityBatchSize, func(tx *gorm.DB, batchNumber int) error {
+			for _, application := range applications {
+				if err := simulation.AddApplication(application, scheme, proposed); err != nil {
+					return err
+				}
+			}
+			return nil
+		}).Error; err != nil {
+		log.Printf("Failed to evaluate applications of scheme %s: %v\n", schemeID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to evaluate applications"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"simulation": simulation})
+}
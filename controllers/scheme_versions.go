@@ -0,0 +1,164 @@
+package controllers
+
+import (
+	"FASMS/models"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+func (sc *SchemeController) GetSchemeVersions(c *gin.Context) {
+	schemeID := c.Param("id")
+
+	var scheme models.Schemes
+	if err := sc.DB.Preload("CriteriaGroups.Criterias").Preload("CriteriaNodes").Preload("Benefits").Preload("Relations").Where("id = ?", schemeID).First(&scheme).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			log.Printf("scheme with id: %s did not found, %v\n", schemeID, err)
+			c.JSON(http.StatusNotFound, gin.H{"error": "Scheme not found"})
+			return
+		}
+		log.Printf("Database error fetching scheme: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch scheme"})
+		return
+	}
+
+	var versions []models.SchemeVersions
+	if err := sc.DB.Where("scheme_id = ?", schemeID).Order("version asc").Find(&versions).Error; err != nil {
+		log.Printf("Database error fetching scheme versions: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch scheme versions"})
+		return
+	}
+	// schemes created before versioning only have their tables, which are their first published version
+	if len(versions) == 0 {
+		definition, err := scheme.ToDefinition()
+		var initialVersion models.SchemeVersions
+		if err == nil {
+			initialVersion, err = models.NewSchemeVersion(scheme.ID, 1, models.SchemeVersionPublished, definition)
+		}
+		if err != nil {
+			log.Printf("convert scheme %s to a version failed: %v\n", schemeID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch scheme versions"})
+			return
+		}
+		initialVersion.PublishedAt = &scheme.CreatedAt
+		versions = append(versions, initialVersion)
+	}
+
+	versionsResponse := []models.SchemeVersionsResponse{}
+	for _, version := range versions {
+		versionsResponse = append(versionsResponse, version.ConvertToResponse())
+	}
+	c.JSON(http.StatusOK, gin.H{"current_version": scheme.CurrentVersion, "versions": versionsResponse})
+}
+
+func (sc *SchemeController) GetSchemeVersionsDiff(c *gin.Context) {
+	schemeID := c.Param("id")
+	var diffRequest models.GetSchemeVersionsDiffRequest
+
+	if err := c.ShouldBindQuery(&diffRequest); err != nil {
+		log.Printf("Invalid request payload: %v\n", err)
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Invalid request payload"})
+		return
+	}
+
+	var versions []models.SchemeVersions
+	if err := sc.DB.Where("scheme_id = ?", schemeID).Where("version in (?)", []uint{diffRequest.From, diffRequest.To}).Find(&versions).Error; err != nil {
+		log.Printf("Database error fetching scheme versions: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch scheme versions"})
+		return
+	}
+	var from, to *models.SchemeVersions
+	for i := range versions {
+		if versions[i].Version == diffRequest.From {
+			from = &versions[i]
+		}
+		if versions[i].Version == diffRequest.To {
+			to = &versions[i]
+		}
+	}
+	if from == nil || to == nil {
+		log.Printf("scheme %s has no version %d or %d\n", schemeID, diffRequest.From, diffRequest.To)
+		c.JSON(http.StatusNotFound, gin.H{"error": "Scheme version not found"})
+		return
+	}
+
+	diff, err := models.DiffSchemeVersions(*from, *to)
+	if err != nil {
+		log.Printf("diff scheme %s versions %d and %d failed: %v\n", schemeID, diffRequest.From, diffRequest.To, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to compare scheme versions"})
+		return
+	}
+	c.JSON(http.StatusOK, diff)
+}
+
+var errVersionPendingApproval = errors.New("the latest version of the scheme is pending approval")
+
+// stores the definition as the scheme's draft version, the existing draft is replaced,
+// otherwise a new draft is numbered after the latest version
+func saveDraftVersion(tx *gorm.DB, actor string, scheme *models.Schemes, definition models.CreateSchemesRequest) (models.SchemeVersions, error) {
+	if err := ensureInitialVersion(tx, scheme); err != nil {
+		return models.SchemeVersions{}, err
+	}
+
+	var latestVersion models.SchemeVersions
+	if err := tx.Where("scheme_id = ?", scheme.ID).Order("version desc").First(&latestVersion).Error; err != nil {
+		return models.SchemeVersions{}, err
+	}
+	if latestVersion.Status == models.SchemeVersionDraft {
+		before := latestVersion.ConvertToResponse()
+		if err := latestVersion.SetDefinition(definition); err != nil {
+			return models.SchemeVersions{}, err
+		}
+		if err := tx.Model(&latestVersion).Update("definition", latestVersion.Definition).Error; err != nil {
+			return models.SchemeVersions{}, err
+		}
+		err := recordAudit(tx, actor, models.AuditEntitySchemeVersion, latestVersion.ID, models.AuditActionUpdate, before, latestVersion.ConvertToResponse())
+		return latestVersion, err
+	}
+	// the version under review must be rejected back to draft before it is edited
+	if latestVersion.Status == models.SchemeVersionPendingApproval {
+		return models.SchemeVersions{}, errVersionPendingApproval
+	}
+
+	draftVersion, err := models.NewSchemeVersion(scheme.ID, latestVersion.Version+1, models.SchemeVersionDraft, definition)
+	if err != nil {
+		return models.SchemeVersions{}, err
+	}
+	if err := tx.Create(&draftVersion).Error; err != nil {
+		return models.SchemeVersions{}, err
+	}
+	err = recordAudit(tx, actor, models.AuditEntitySchemeVersion, draftVersion.ID, models.AuditActionCreate, nil, draftVersion.ConvertToResponse())
+	return draftVersion, err
+}
+
+// schemes created before versioning have no versions, their tables are recorded as the published version 1
+func ensureInitialVersion(tx *gorm.DB, scheme *models.Schemes) error {
+	var count int64
+	if err := tx.Model(&models.SchemeVersions{}).Where("scheme_id = ?", scheme.ID).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+	definition, err := scheme.ToDefinition()
+	if err != nil {
+		return err
+	}
+	initialVersion, err := models.NewSchemeVersion(scheme.ID, 1, models.SchemeVersionPublished, definition)
+	if err != nil {
+		return err
+	}
+	initialVersion.PublishedAt = &scheme.CreatedAt
+	if err := tx.Create(&initialVersion).Error; err != nil {
+		return err
+	}
+	// the backfill is not made by the requester, so it is recorded as made by the system
+	if err := recordAudit(tx, "system", models.AuditEntitySchemeVersion, initialVersion.ID, models.AuditActionCreate, nil, initialVersion.ConvertToResponse()); err != nil {
+		return err
+	}
+	scheme.CurrentVersion = 1
+	return tx.Model(scheme).Update("current_version", 1).Error
+}
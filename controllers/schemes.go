@@ -38,9 +38,20 @@ func (sc *SchemeController) GetSchemesList(c *gin.Context) {
 		schemesRequest.PageSize = 10
 	}
 
+	// only keep the schemes effective on the given day
+	var filter = sc.DB.Model(&models.Schemes{})
+	if schemesRequest.ActiveOn != nil {
+		activeOn := schemesRequest.ActiveOn.ToTime()
+		filter = filter.Where("effective_from IS NULL OR effective_from <= ?", activeOn).
+			Where("effective_to IS NULL OR effective_to >= ?", activeOn)
+	}
+	if schemesRequest.Status != 0 {
+		filter = filter.Where("status = ?", schemesRequest.Status)
+	}
+
 	// Fetch applicants and return 500 Internal Server Error on failure
-	var query = sc.DB.Offset(schemesRequest.Page * schemesRequest.PageSize).Limit(schemesRequest.PageSize).Order("id")
-	if err := query.Preload("CriteriaGroups.Criterias").Preload("Benefits").Find(&schemes).Error; err != nil {
+	var query = filter.Session(&gorm.Session{}).Offset(schemesRequest.Page * schemesRequest.PageSize).Limit(schemesRequest.PageSize).Order("id")
+	if err := query.Preload("CriteriaGroups.Criterias").Preload("CriteriaNodes").Preload("Benefits").Preload("Relations").Find(&schemes).Error; err != nil {
 		log.Printf("Database error fetching scheme list: %v\n", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch scheme list"})
 		return
@@ -54,7 +65,7 @@ func (sc *SchemeController) GetSchemesList(c *gin.Context) {
 	}
 	// find total number of applications for pagenation
 	var total int64
-	if err := sc.DB.Model(&models.Schemes{}).Count(&total).Error; err != nil {
+	if err := filter.Session(&gorm.Session{}).Count(&total).Error; err != nil {
 		log.Printf("Database error counting total scheme: %v\n", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch total total"})
 		return
@@ -85,7 +96,7 @@ func (sc *SchemeController) GetEligibleSchemesList(c *gin.Context) {
 	}
 
 	// Fetch applicants and return 500 Internal Server Error on failure
-	if err := sc.DB.Preload("Households").Where("id = ?", schemesRequest.ApplicantID).First(&applicant).Error; err != nil {
+	if err := sc.DB.Preload("Households").Preload("Applications").Where("id = ?", schemesRequest.ApplicantID).First(&applicant).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			log.Printf("applicant with id: %s did not found, %v\n", schemesRequest.ApplicantID, err)
 			c.JSON(http.StatusNotFound, gin.H{"error": "Applicant not found"})
@@ -97,7 +108,7 @@ func (sc *SchemeController) GetEligibleSchemesList(c *gin.Context) {
 	}
 
 	// Fetch schemes and return 500 Internal Server Error on failure
-	if err := sc.DB.Preload("CriteriaGroups.Criterias").Preload("Benefits").Order("id").Find(&schemes).Error; err != nil {
+	if err := sc.DB.Preload("CriteriaGroups.Criterias").Preload("CriteriaNodes").Preload("Benefits").Preload("Relations").Order("id").Find(&schemes).Error; err != nil {
 		log.Printf("Database error fetching scheem list: %v\n", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch scheem list"})
 		return
@@ -110,8 +121,13 @@ func (sc *SchemeController) GetEligibleSchemesList(c *gin.Context) {
 	}
 
 	var ret []models.SchemesResponse
+	eligibilityContext := models.NewEligibilityContext()
 	for _, scheme := range schemes {
-		if models.CheckEligiblity(applicant, scheme) {
+		// schemes not published or not taking applications today can not be applied for
+		if !scheme.IsPublished() || !scheme.IsOpenForApplication(eligibilityContext.Now) {
+			continue
+		}
+		if models.CheckEligiblity(applicant, scheme, eligibilityContext) {
 			ret = append(ret, scheme.ConvertToResponse())
 		}
 	}
@@ -129,6 +145,51 @@ func (sc *SchemeController) GetEligibleSchemesList(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"schemes": ret[start:end], "total": len(ret)})
 }
 
+func (sc *SchemeController) GetSchemeEligibility(c *gin.Context) {
+	schemeID := c.Param("id")
+	var scheme models.Schemes
+	var applicant models.Applicants
+	var eligibilityRequest models.GetSchemeEligibilityRequest
+
+	// Bind JSON and return 422 Unprocessable Entity on failure
+	if err := c.ShouldBindQuery(&eligibilityRequest); err != nil {
+		log.Printf("Invalid request payload: %v\n", err)
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Invalid request payload"})
+		return
+	}
+
+	// Fetch applicants and return 500 Internal Server Error on failure
+	if err := sc.DB.Preload("Households").Preload("Applications").Where("id = ?", eligibilityRequest.ApplicantID).First(&applicant).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			log.Printf("applicant with id: %s did not found, %v\n", eligibilityRequest.ApplicantID, err)
+			c.JSON(http.StatusNotFound, gin.H{"error": "Applicant not found"})
+			return
+		}
+		log.Printf("Database error fetching applicants: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch applicants list"})
+		return
+	}
+
+	// Fetch scheme and return 500 Internal Server Error on failure
+	if err := sc.DB.Preload("CriteriaGroups.Criterias").Preload("CriteriaNodes").Preload("Benefits").Preload("Relations").Where("id = ?", schemeID).First(&scheme).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			log.Printf("scheme with id: %s did not found, %v\n", schemeID, err)
+			c.JSON(http.StatusNotFound, gin.H{"error": "Scheme not found"})
+			return
+		}
+		log.Printf("Database error fetching scheme: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch scheme"})
+		return
+	}
+
+	if !scheme.IsPublished() {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Scheme is not published"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"eligibility": models.ExplainEligibility(applicant, scheme, models.NewEligibilityContext())})
+}
+
 func (sc *SchemeController) AddSchemes(c *gin.Context) {
 	var addSchemesRequest models.CreateSchemesListRequest
 
@@ -148,6 +209,20 @@ func (sc *SchemeController) AddSchemes(c *gin.Context) {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("New scheme is not valid, %v", err.Error())})
 			return
 		}
+		if err := newscheme.CompileExpressions(); err != nil {
+			log.Printf("New scheme has invalid expression: %v\n", err)
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+			return
+		}
+		if err := checkRelatedSchemes(sc.DB, "", newscheme.Relations); err != nil {
+			if errors.Is(err, errInvalidRelation) {
+				log.Printf("New scheme has invalid relation: %v\n", err)
+				c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check related schemes"})
+			return
+		}
 		newSchemeName = append(newSchemeName, newscheme.Name)
 	}
 
@@ -179,6 +254,29 @@ func (sc *SchemeController) AddSchemes(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create schemes"})
 		return
 	}
+	// a new scheme starts as a draft of its first version
+	for _, scheme := range schemes {
+		var initialVersion models.SchemeVersions
+		definition, err := scheme.ToDefinition()
+		if err == nil {
+			initialVersion, err = models.NewSchemeVersion(scheme.ID, 1, models.SchemeVersionDraft, definition)
+		}
+		if err == nil {
+			err = tx.Create(&initialVersion).Error
+		}
+		if err == nil {
+			err = recordAudit(tx, actorOf(c), models.AuditEntityScheme, scheme.ID, models.AuditActionCreate, nil, scheme.ConvertToResponse())
+		}
+		if err == nil {
+			err = recordAudit(tx, actorOf(c), models.AuditEntitySchemeVersion, initialVersion.ID, models.AuditActionCreate, nil, initialVersion.ConvertToResponse())
+		}
+		if err != nil {
+			tx.Rollback()
+			log.Printf("create scheme version failed: %v\n", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create schemes"})
+			return
+		}
+	}
 	if err := tx.Commit().Error; err != nil {
 		log.Printf("Transaction commit failed: %v\n", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Transaction commit failed"})
@@ -197,7 +295,7 @@ func (sc *SchemeController) DeleteScheme(c *gin.Context) {
 
 	// check if applicant exist
 	var scheme models.Schemes
-	if err := sc.DB.Where("id = ?", schemeID).First(&scheme).Error; err != nil {
+	if err := sc.DB.Preload("CriteriaGroups.Criterias").Preload("CriteriaNodes").Preload("Benefits").Preload("Relations").Where("id = ?", schemeID).First(&scheme).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			log.Printf("scheme with id: %s did not found, %v\n", schemeID, err)
 			c.JSON(http.StatusNotFound, gin.H{"error": "Scheme not found"})
@@ -238,6 +336,30 @@ func (sc *SchemeController) DeleteScheme(c *gin.Context) {
 		return
 	}
 
+	// delete scheme versions
+	if err := tx.Where("scheme_id = ?", schemeID).Delete(&models.SchemeVersions{}).Error; err != nil {
+		tx.Rollback()
+		log.Printf("Database error deleting versions belonging to scheme: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete scheme versions"})
+		return
+	}
+
+	// delete criteria nodes
+	if err := tx.Where("scheme_id = ?", schemeID).Delete(&models.CriteriaNode{}).Error; err != nil {
+		tx.Rollback()
+		log.Printf("Database error deleting criteria nodes belonging to scheme: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete criteria nodes"})
+		return
+	}
+
+	// delete scheme relations
+	if err := tx.Where("scheme_id = ?", schemeID).Delete(&models.SchemeRelations{}).Error; err != nil {
+		tx.Rollback()
+		log.Printf("Database error deleting relations belonging to scheme: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete scheme relations"})
+		return
+	}
+
 	// delete benefits
 	if err := tx.Where("scheme_id = ?", schemeID).Delete(&models.Benefits{}).Error; err != nil {
 		tx.Rollback()
@@ -260,17 +382,23 @@ func (sc *SchemeController) DeleteScheme(c *gin.Context) {
 		return
 	}
 
+	if err := recordAudit(tx, actorOf(c), models.AuditEntityScheme, schemeID, models.AuditActionDelete, scheme.ConvertToResponse(), nil); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete scheme"})
+		return
+	}
+
 	// update applications involved
-	result := tx.Where("scheme_id = ?", schemeID).Delete(&models.Applications{})
+	rowsAffected, err := flagApplicationsForReview(tx, actorOf(c), "scheme deleted", "scheme_id = ?", schemeID)
 
 	// Check if any rows were affected
-	if result.Error != nil {
+	if err != nil {
 		tx.Rollback()
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "while deleting scheme, Failed to delete applications"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "while deleting scheme, Failed to update applications"})
 		return
 	}
 
-	if result.RowsAffected == 0 {
+	if rowsAffected == 0 {
 		log.Printf("while deleting sceme: %v, No applications found for the scheme\n", schemeID)
 		// c.JSON(http.StatusNotFound, gin.H{"error": "No applications found for the applicant"})
 		// return
@@ -302,6 +430,21 @@ func (sc *SchemeController) UpdateScheme(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("New scheme is not valid, %v", err.Error())})
 		return
 	}
+	if err := updatedScheme.CompileExpressions(); err != nil {
+		log.Printf("New scheme has invalid expression: %v\n", err)
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	}
+	if err := checkRelatedSchemes(sc.DB, schemeID, updatedScheme.Relations); err != nil {
+		if errors.Is(err, errInvalidRelation) {
+			log.Printf("New scheme has invalid relation: %v\n", err)
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check related schemes"})
+		return
+	}
+	updatedScheme.AssignIDs()
 
 	tx := sc.DB.Begin()
 	defer func() {
@@ -309,8 +452,7 @@ func (sc *SchemeController) UpdateScheme(c *gin.Context) {
 			tx.Rollback() // Ensure rollback in case of panic
 		}
 	}()
-	// update scheme
-	if err := tx.Preload("CriteriaGroups.Criterias").Preload("Benefits").Where("id = ?", schemeID).First(&existingScheme).Error; err != nil {
+	if err := tx.Preload("CriteriaGroups.Criterias").Preload("CriteriaNodes").Preload("Benefits").Preload("Relations").Where("id = ?", schemeID).First(&existingScheme).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			tx.Rollback()
 			log.Printf("scheme with id: %s did not found, %v\n", schemeID, err)
@@ -323,13 +465,53 @@ func (sc *SchemeController) UpdateScheme(c *gin.Context) {
 		return
 	}
 
-	existingScheme.Name = updatedScheme.Name
-	if err := tx.Save(existingScheme).Error; err != nil {
+	if existingScheme.Status == models.SchemeStatusRetired {
 		tx.Rollback()
-		log.Printf("update scheme error: %v\n", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update scheme"})
+		c.JSON(http.StatusConflict, gin.H{"error": "Scheme is retired and can not be updated"})
+		return
+	}
+
+	// edits never change the published scheme, they go to the draft version until it is published
+	draftVersion, err := saveDraftVersion(tx, actorOf(c), &existingScheme, updatedScheme)
+	if errors.Is(err, errVersionPendingApproval) {
+		tx.Rollback()
+		c.JSON(http.StatusConflict, gin.H{"error": "Scheme has a version pending approval, reject it before updating the scheme"})
+		return
+	}
+	if err != nil {
+		tx.Rollback()
+		log.Printf("save draft version of scheme %s failed: %v\n", schemeID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save draft version"})
+		return
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		tx.Rollback()
+		log.Printf("Transaction commit failed: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Transaction commit failed"})
 		return
 	}
+	c.JSON(http.StatusOK, gin.H{"version": draftVersion.ConvertToResponse()})
+}
+
+// applies a scheme definition to the scheme's tables, criteria groups, criterias and benefits are matched by id,
+// the ones not in the definition are deleted and the ones not in the tables are created
+func applySchemeDefinition(tx *gorm.DB, existingScheme *models.Schemes, updatedScheme models.CreateSchemesRequest) error {
+	schemeID := existingScheme.ID
+	existingScheme.Name = updatedScheme.Name
+	existingScheme.AgeReferenceType = updatedScheme.GetAgeReferenceType()
+	existingScheme.AgeReferenceDate = updatedScheme.GetAgeReferenceDate()
+	existingScheme.ApplicationOpen = updatedScheme.ApplicationOpen.ToTimePtr()
+	existingScheme.ApplicationClose = updatedScheme.ApplicationClose.ToTimePtr()
+	existingScheme.EffectiveFrom = updatedScheme.EffectiveFrom.ToTimePtr()
+	existingScheme.EffectiveTo = updatedScheme.EffectiveTo.ToTimePtr()
+	existingScheme.Budget = updatedScheme.Budget
+	existingScheme.MaxBeneficiaries = updatedScheme.MaxBeneficiaries
+	// the counters are only changed under the scheme's lock, see reserveCapacity
+	if err := tx.Omit("budget_committed", "beneficiaries").Save(existingScheme).Error; err != nil {
+		log.Printf("update scheme error: %v\n", err)
+		return err
+	}
 
 	existingGroups := make(map[string]models.CriteriaGroup)
 	for _, group := range existingScheme.CriteriaGroups {
@@ -338,6 +520,7 @@ func (sc *SchemeController) UpdateScheme(c *gin.Context) {
 
 	var newGroups []models.CriteriaGroup
 	var createGroups []models.CriteriaGroup
+	var groupIDs []string
 
 	for _, newGroup := range updatedScheme.CriteriaGroups {
 		groupID := newGroup.ID
@@ -346,6 +529,7 @@ func (sc *SchemeController) UpdateScheme(c *gin.Context) {
 		if groupID == "" {
 			groupID = utils.GenerateUUID()
 		}
+		groupIDs = append(groupIDs, groupID)
 
 		// Check if group exists in DB
 		existingGroup, exists := existingGroups[groupID]
@@ -354,8 +538,7 @@ func (sc *SchemeController) UpdateScheme(c *gin.Context) {
 			updatedCriterias, err := UpdateCriterias(tx, existingGroup.Criterias, newGroup.Criterias, groupID)
 			existingGroup.Criterias = updatedCriterias
 			if err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Error updating criterias"})
-				return
+				return err
 			}
 			newGroups = append(newGroups, existingGroup)
 			delete(existingGroups, groupID) // Remove from map to track deletions
@@ -369,10 +552,8 @@ func (sc *SchemeController) UpdateScheme(c *gin.Context) {
 		}
 		if len(createGroups) > 0 {
 			if err := tx.Save(&createGroups).Error; err != nil {
-				tx.Rollback()
 				log.Printf("Save new benefits failed: %v\n", err)
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Save new benefits failed"})
-				return
+				return err
 			}
 		}
 	}
@@ -380,6 +561,19 @@ func (sc *SchemeController) UpdateScheme(c *gin.Context) {
 		tx.Delete(&group)
 	}
 
+	// the criteria tree is replaced as a whole, its nodes are not referenced from anywhere else
+	if err := tx.Where("scheme_id = ?", schemeID).Delete(&models.CriteriaNode{}).Error; err != nil {
+		log.Printf("Delete criteria nodes failed: %v\n", err)
+		return err
+	}
+	newNodes := updatedScheme.ConvertCriteriaNodes(schemeID, groupIDs)
+	if len(newNodes) > 0 {
+		if err := tx.Create(&newNodes).Error; err != nil {
+			log.Printf("Save new criteria nodes failed: %v\n", err)
+			return err
+		}
+	}
+
 	existingBenefits := make(map[string]models.Benefits)
 	for _, benefit := range existingScheme.Benefits {
 		existingBenefits[benefit.ID] = benefit
@@ -395,41 +589,28 @@ func (sc *SchemeController) UpdateScheme(c *gin.Context) {
 
 		// Check if benefit exists
 		existingBenefit, exists := existingBenefits[benefitID]
+		benefit := newBenefit.ConvertToModel(benefitID, schemeID)
 
 		if exists {
 			// Update benefit
-			updateData := map[string]interface{}{
-				"name":   newBenefit.Name,
-				"amount": newBenefit.Amount,
-			}
-			if err := tx.Model(&existingBenefit).Updates(updateData).Error; err != nil {
-				tx.Rollback()
+			if err := tx.Model(&existingBenefit).Updates(benefit.UpdateColumns()).Error; err != nil {
 				log.Println("Error updating benefit:", err)
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Error updating benefit"})
-				return
+				return err
 			}
 
 			// keep track of the updated benefit for later return in response
-			existingBenefit.Name = newBenefit.Name
-			existingBenefit.Amount = newBenefit.Amount
-			newBenefits = append(newBenefits, existingBenefit)
+			benefit.CommonTime = existingBenefit.CommonTime
+			newBenefits = append(newBenefits, benefit)
 
 			delete(existingBenefits, benefitID) // Remove from map to track deletions
 		} else {
 			// New benefit
-			createBenefits = append(createBenefits, models.Benefits{
-				ID:       benefitID,
-				Name:     newBenefit.Name,
-				Amount:   newBenefit.Amount,
-				SchemeID: schemeID,
-			})
+			createBenefits = append(createBenefits, benefit)
 		}
 		if len(createBenefits) > 0 {
 			if err := tx.Save(&createBenefits).Error; err != nil {
-				tx.Rollback()
 				log.Printf("Save new benefits failed: %v\n", err)
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Save new benefits failed"})
-				return
+				return err
 			}
 		}
 	}
@@ -438,16 +619,50 @@ func (sc *SchemeController) UpdateScheme(c *gin.Context) {
 	for _, benefit := range existingBenefits {
 		tx.Delete(&benefit)
 	}
+
+	// the relations are replaced as a whole like the criteria tree
+	if err := tx.Where("scheme_id = ?", schemeID).Delete(&models.SchemeRelations{}).Error; err != nil {
+		log.Printf("Delete scheme relations failed: %v\n", err)
+		return err
+	}
+	newRelations := make([]models.SchemeRelations, 0, len(updatedScheme.Relations))
+	for _, relation := range updatedScheme.Relations {
+		newRelations = append(newRelations, relation.ConvertToModel(schemeID))
+	}
+	if len(newRelations) > 0 {
+		if err := tx.Create(&newRelations).Error; err != nil {
+			log.Printf("Save new scheme relations failed: %v\n", err)
+			return err
+		}
+	}
+
 	existingScheme.CriteriaGroups = append(newGroups, createGroups...)
+	existingScheme.CriteriaNodes = newNodes
 	existingScheme.Benefits = append(newBenefits, createBenefits...)
+	existingScheme.Relations = newRelations
 
-	if err := tx.Commit().Error; err != nil {
-		tx.Rollback()
-		log.Printf("Transaction commit failed: %v\n", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Transaction commit failed"})
-		return
+	return nil
+}
+
+// returned, wrapped, when a relation of the scheme is not valid. any other error of checkRelatedSchemes is a database error
+var errInvalidRelation = errors.New("invalid relation")
+
+// the related schemes of the relations must exist and can not be the scheme itself
+func checkRelatedSchemes(db *gorm.DB, schemeID string, relations []models.CreateSchemeRelationRequest) error {
+	for _, relation := range relations {
+		if relation.RelatedSchemeID == schemeID {
+			return fmt.Errorf("%w, a scheme can not have a relation to itself", errInvalidRelation)
+		}
+		var count int64
+		if err := db.Model(&models.Schemes{}).Where("id = ?", relation.RelatedSchemeID).Count(&count).Error; err != nil {
+			log.Printf("Database error checking related scheme %s: %v\n", relation.RelatedSchemeID, err)
+			return err
+		}
+		if count == 0 {
+			return fmt.Errorf("%w, related scheme %s not found", errInvalidRelation, relation.RelatedSchemeID)
+		}
 	}
-	c.JSON(http.StatusOK, existingScheme.ConvertToResponse())
+	return nil
 }
 
 func UpdateCriterias(tx *gorm.DB, existingCriterias []models.Criterias, newCriterias []models.CreateCriteriaRequest, groupID string) ([]models.Criterias, error) {
@@ -471,13 +686,19 @@ func UpdateCriterias(tx *gorm.DB, existingCriterias []models.Criterias, newCrite
 			// Update existing criteria
 
 			updateData := map[string]interface{}{
-				"employment_status": newCriteria.EmploymentStatus,
-				"marital_status":    newCriteria.MaritalStatus,
-				"sex":               newCriteria.Sex,
-				"age_upper_limit":   newCriteria.AgeUpperLimit,
-				"age_lower_limit":   newCriteria.AgeLowerLimit,
-				"relation":          newCriteria.Relation,
-				"is_house_hold":     newCriteria.IsHouseHold,
+				"employment_status":  newCriteria.EmploymentStatus,
+				"marital_status":     newCriteria.MaritalStatus,
+				"sex":                newCriteria.Sex,
+				"age_upper_limit":    newCriteria.AgeUpperLimit,
+				"age_lower_limit":    newCriteria.AgeLowerLimit,
+				"relation":           newCriteria.Relation,
+				"is_house_hold":      newCriteria.IsHouseHold,
+				"criteria_type":      newCriteria.GetCriteriaType(),
+				"min_count":          newCriteria.MinCount,
+				"max_count":          newCriteria.MaxCount,
+				"max_monthly_income": newCriteria.MaxMonthlyIncome,
+				"max_assets":         newCriteria.MaxAssets,
+				"expression":         newCriteria.Expression,
 			}
 
 			if err := tx.Model(&existingCriteria).Updates(updateData).Error; err != nil {
@@ -493,6 +714,12 @@ func UpdateCriterias(tx *gorm.DB, existingCriterias []models.Criterias, newCrite
 			existingCriteria.AgeLowerLimit = newCriteria.AgeLowerLimit
 			existingCriteria.Relation = newCriteria.Relation
 			existingCriteria.IsHouseHold = *newCriteria.IsHouseHold
+			existingCriteria.CriteriaType = newCriteria.GetCriteriaType()
+			existingCriteria.MinCount = newCriteria.MinCount
+			existingCriteria.MaxCount = newCriteria.MaxCount
+			existingCriteria.MaxMonthlyIncome = newCriteria.MaxMonthlyIncome
+			existingCriteria.MaxAssets = newCriteria.MaxAssets
+			existingCriteria.Expression = newCriteria.Expression
 			updatedCriterias = append(updatedCriterias, existingCriteria)
 			delete(existingCriteriasMap, criteriaID) // Mark as processed
 		} else {
@@ -506,6 +733,12 @@ func UpdateCriterias(tx *gorm.DB, existingCriterias []models.Criterias, newCrite
 				AgeLowerLimit:    newCriteria.AgeLowerLimit,
 				Relation:         newCriteria.Relation,
 				IsHouseHold:      *newCriteria.IsHouseHold,
+				CriteriaType:     newCriteria.GetCriteriaType(),
+				MinCount:         newCriteria.MinCount,
+				MaxCount:         newCriteria.MaxCount,
+				MaxMonthlyIncome: newCriteria.MaxMonthlyIncome,
+				MaxAssets:        newCriteria.MaxAssets,
+				Expression:       newCriteria.Expression,
 				CriteriaGroupID:  groupID,
 			})
 		}
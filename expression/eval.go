@@ -0,0 +1,189 @@
+package expression
+
+import (
+	"fmt"
+)
+
+type value struct {
+	number  float64
+	boolean bool
+}
+
+// aggregate functions over the household members, and the type they return
+var aggregates = map[string]Type{
+	"count": TypeNumber,
+	"any":   TypeBool,
+	"all":   TypeBool,
+}
+
+func check(n node, scope *Scope) (Type, error) {
+	switch n := n.(type) {
+	case *numberNode:
+		return TypeNumber, nil
+	case *boolNode:
+		return TypeBool, nil
+	case *identNode:
+		for _, variable := range scope.Variables {
+			if variable == n.name {
+				return TypeNumber, nil
+			}
+		}
+		return 0, &Error{Pos: n.pos, Msg: fmt.Sprintf("unknown attribute %q", n.name)}
+	case *unaryNode:
+		want := TypeNumber
+		if n.op == "!" {
+			want = TypeBool
+		}
+		if err := expectType(n.operand, scope, want, n.op); err != nil {
+			return 0, err
+		}
+		return want, nil
+	case *binaryNode:
+		switch n.op {
+		case "&&", "||":
+			if err := expectType(n.left, scope, TypeBool, n.op); err != nil {
+				return 0, err
+			}
+			if err := expectType(n.right, scope, TypeBool, n.op); err != nil {
+				return 0, err
+			}
+			return TypeBool, nil
+		case "+", "-", "*":
+			if err := expectType(n.left, scope, TypeNumber, n.op); err != nil {
+				return 0, err
+			}
+			if err := expectType(n.right, scope, TypeNumber, n.op); err != nil {
+				return 0, err
+			}
+			return TypeNumber, nil
+		case "==", "!=":
+			leftType, err := check(n.left, scope)
+			if err != nil {
+				return 0, err
+			}
+			if err := expectType(n.right, scope, leftType, n.op); err != nil {
+				return 0, err
+			}
+			return TypeBool, nil
+		default:
+			if err := expectType(n.left, scope, TypeNumber, n.op); err != nil {
+				return 0, err
+			}
+			if err := expectType(n.right, scope, TypeNumber, n.op); err != nil {
+				return 0, err
+			}
+			return TypeBool, nil
+		}
+	case *inNode:
+		if err := expectType(n.operand, scope, TypeNumber, "in"); err != nil {
+			return 0, err
+		}
+		for _, v := range n.values {
+			if err := expectType(v, scope, TypeNumber, "in"); err != nil {
+				return 0, err
+			}
+		}
+		return TypeBool, nil
+	case *callNode:
+		returnType, ok := aggregates[n.name]
+		if !ok {
+			return 0, &Error{Pos: n.pos, Msg: fmt.Sprintf("unknown function %q", n.name)}
+		}
+		if scope.Members == nil {
+			return 0, &Error{Pos: n.pos, Msg: fmt.Sprintf("%s can not be used on a household member", n.name)}
+		}
+		if err := expectType(n.arg, scope.Members, TypeBool, n.name); err != nil {
+			return 0, err
+		}
+		return returnType, nil
+	}
+	return 0, &Error{Pos: n.position(), Msg: "unsupported expression"}
+}
+
+func expectType(n node, scope *Scope, want Type, op string) error {
+	got, err := check(n, scope)
+	if err != nil {
+		return err
+	}
+	if got != want {
+		return &Error{Pos: n.position(), Msg: fmt.Sprintf("%s expects a %v, got %v", op, want, got)}
+	}
+	return nil
+}
+
+func eval(n node, env Env) value {
+	switch n := n.(type) {
+	case *numberNode:
+		return value{number: n.value}
+	case *boolNode:
+		return value{boolean: n.value}
+	case *identNode:
+		return value{number: env.Variables[n.name]}
+	case *unaryNode:
+		operand := eval(n.operand, env)
+		if n.op == "!" {
+			return value{boolean: !operand.boolean}
+		}
+		return value{number: -operand.number}
+	case *binaryNode:
+		left := eval(n.left, env)
+		// short circuit the logical operators
+		switch n.op {
+		case "&&":
+			if !left.boolean {
+				return value{}
+			}
+			return value{boolean: eval(n.right, env).boolean}
+		case "||":
+			if left.boolean {
+				return value{boolean: true}
+			}
+			return value{boolean: eval(n.right, env).boolean}
+		}
+		right := eval(n.right, env)
+		switch n.op {
+		case "+":
+			return value{number: left.number + right.number}
+		case "-":
+			return value{number: left.number - right.number}
+		case "*":
+			return value{number: left.number * right.number}
+		case "==":
+			return value{boolean: left == right}
+		case "!=":
+			return value{boolean: left != right}
+		case "<":
+			return value{boolean: left.number < right.number}
+		case "<=":
+			return value{boolean: left.number <= right.number}
+		case ">":
+			return value{boolean: left.number > right.number}
+		case ">=":
+			return value{boolean: left.number >= right.number}
+		}
+	case *inNode:
+		operand := eval(n.operand, env)
+		for _, v := range n.values {
+			if eval(v, env).number == operand.number {
+				return value{boolean: true}
+			}
+		}
+		return value{}
+	case *callNode:
+		matched := 0
+		for _, member := range env.Members {
+			if eval(n.arg, member).boolean {
+				matched++
+			}
+		}
+		switch n.name {
+		case "count":
+			return value{number: float64(matched)}
+		case "any":
+			return value{boolean: matched > 0}
+		case "all":
+			return value{boolean: matched == len(env.Members)}
+		}
+	}
+	return value{}
+}
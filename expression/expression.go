@@ -0,0 +1,99 @@
+// Package expression implements the small rule language used by scheme criteria.
+//
+// An expression is compiled against a Scope, which lists the attributes that can be
+// referenced, and is type checked at compile time so that a stored rule can never fail
+// at evaluation time. The language only has numbers and booleans:
+//
+//	age < 18 or employment_status == 3
+//	not (marital_status == 4)
+//	count(relation == 1 and age < 12) >= 2
+//	marital_status in (1, 3)
+//
+// count, any and all evaluate their argument against every household member of the scope.
+package expression
+
+import (
+	"fmt"
+)
+
+const maxSourceLength = 1000
+
+type Type int
+
+const (
+	TypeNumber Type = iota + 1
+	TypeBool
+)
+
+func (t Type) String() string {
+	switch t {
+	case TypeNumber:
+		return "number"
+	case TypeBool:
+		return "bool"
+	}
+	return "unknown"
+}
+
+// Scope lists the variables an expression can use,
+// Members is the scope of a single household member, and enables count, any and all
+type Scope struct {
+	Variables []string
+	Members   *Scope
+}
+
+// Env holds the values of the variables of a Scope
+type Env struct {
+	Variables map[string]float64
+	Members   []Env
+}
+
+type Error struct {
+	Pos int
+	Msg string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("position %d: %s", e.Pos, e.Msg)
+}
+
+type Program struct {
+	source string
+	root   node
+}
+
+func (p *Program) String() string {
+	return p.source
+}
+
+// Compile parses and type checks source against scope, the result must be a boolean
+func Compile(source string, scope Scope) (*Program, error) {
+	if len(source) > maxSourceLength {
+		return nil, &Error{Pos: maxSourceLength, Msg: fmt.Sprintf("expression is longer than %d characters", maxSourceLength)}
+	}
+	tokens, err := tokenize(source)
+	if err != nil {
+		return nil, err
+	}
+	p := parser{tokens: tokens}
+	root, err := p.parseExpression()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.kind != tokenEOF {
+		return nil, &Error{Pos: tok.pos, Msg: fmt.Sprintf("unexpected %q", tok.text)}
+	}
+	rootType, err := check(root, &scope)
+	if err != nil {
+		return nil, err
+	}
+	if rootType != TypeBool {
+		return nil, &Error{Pos: root.position(), Msg: fmt.Sprintf("expression must be a bool, got %v", rootType)}
+	}
+	return &Program{source: source, root: root}, nil
+}
+
+// Eval runs the program, unknown variables in env evaluate to 0
+func (p *Program) Eval(env Env) bool {
+	return eval(p.root, env).boolean
+}
@@ -0,0 +1,164 @@
+package expression
+
+import (
+	"strings"
+	"testing"
+)
+
+var memberScope = Scope{Variables: []string{"age", "relation"}}
+
+var applicantScope = Scope{
+	Variables: []string{"age", "marital_status", "employment_status", "household_size"},
+	Members:   &memberScope,
+}
+
+func TestTokenize(t *testing.T) {
+	tests := []struct {
+		name    string
+		source  string
+		want    []string
+		wantErr string
+	}{
+		{name: "two character operators first", source: "age>=18&&x!=1", want: []string{"age", ">=", "18", "&&", "x", "!=", "1"}},
+		{name: "keywords are operators", source: "not a and b or c", want: []string{"!", "a", "&&", "b", "||", "c"}},
+		{name: "decimal numbers", source: "1.5 + .5", want: []string{"1.5", "+", ".5"}},
+		{name: "identifiers with digits and underscores", source: "per_capita_income2", want: []string{"per_capita_income2"}},
+		{name: "empty source", source: "   ", want: []string{}},
+		{name: "invalid number", source: "1.2.3", wantErr: "position 0: invalid number"},
+		{name: "unexpected character", source: "age = 1", wantErr: "position 4: unexpected character '='"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tokens, err := tokenize(tt.source)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("tokenize(%q) error = %v, want %q", tt.source, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("tokenize(%q) error = %v", tt.source, err)
+			}
+			if tokens[len(tokens)-1].kind != tokenEOF {
+				t.Fatalf("tokenize(%q) does not end with EOF", tt.source)
+			}
+			got := []string{}
+			for _, tok := range tokens[:len(tokens)-1] {
+				got = append(got, tok.text)
+			}
+			if strings.Join(got, " ") != strings.Join(tt.want, " ") {
+				t.Fatalf("tokenize(%q) = %v, want %v", tt.source, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		source  string
+		scope   Scope
+		wantErr string
+	}{
+		{name: "unknown attribute", source: "salary > 1", scope: applicantScope, wantErr: `position 0: unknown attribute "salary"`},
+		{name: "not a bool", source: "age + 1", scope: applicantScope, wantErr: "expression must be a bool, got number"},
+		{name: "and on numbers", source: "age && true", scope: applicantScope, wantErr: "&& expects a bool, got number"},
+		{name: "comparison of bool and number", source: "true == 1", scope: applicantScope, wantErr: "== expects a bool, got number"},
+		{name: "not on a number", source: "not age", scope: applicantScope, wantErr: "! expects a bool, got number"},
+		{name: "in on a bool", source: "true in (1)", scope: applicantScope, wantErr: "in expects a number, got bool"},
+		{name: "unknown function", source: "sum(age > 1) > 1", scope: applicantScope, wantErr: `unknown function "sum"`},
+		{name: "aggregate on a member", source: "count(age > 1) > 1", scope: memberScope, wantErr: "count can not be used on a household member"},
+		{name: "aggregate of a number", source: "count(age) > 1", scope: applicantScope, wantErr: "count expects a bool, got number"},
+		{name: "member attribute outside aggregate", source: "relation == 1", scope: applicantScope, wantErr: `unknown attribute "relation"`},
+		{name: "unbalanced parenthesis", source: "(age > 1", scope: applicantScope, wantErr: `expected ")"`},
+		{name: "trailing token", source: "age > 1 1", scope: applicantScope, wantErr: `position 8: unexpected "1"`},
+		{name: "missing operand", source: "age >", scope: applicantScope, wantErr: "unexpected end of expression"},
+		{name: "chained comparison", source: "age == 1 == true", scope: applicantScope, wantErr: `position 9: unexpected "=="`},
+		{name: "in without list", source: "age in 1", scope: applicantScope, wantErr: `expected "("`},
+		{name: "too long", source: "age > " + strings.Repeat("1", maxSourceLength), scope: applicantScope, wantErr: "longer than 1000 characters"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			program, err := Compile(tt.source, tt.scope)
+			if err == nil {
+				t.Fatalf("Compile(%q) = %v, want error %q", tt.source, program, tt.wantErr)
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Compile(%q) error = %q, want %q", tt.source, err.Error(), tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestEval(t *testing.T) {
+	env := Env{
+		Variables: map[string]float64{"age": 40, "marital_status": 2, "employment_status": 1, "household_size": 4},
+		Members: []Env{
+			{Variables: map[string]float64{"age": 5, "relation": 1}},
+			{Variables: map[string]float64{"age": 10, "relation": 1}},
+			{Variables: map[string]float64{"age": 38, "relation": 2}},
+		},
+	}
+	tests := []struct {
+		source string
+		want   bool
+	}{
+		{source: "age >= 40", want: true},
+		{source: "age > 40", want: false},
+		{source: "age < 18 or employment_status == 1", want: true},
+		{source: "age < 18 || employment_status == 2", want: false},
+		{source: "not (marital_status == 4)", want: true},
+		{source: "!true", want: false},
+		{source: "marital_status in (1, 3)", want: false},
+		{source: "marital_status in (1, 2)", want: true},
+		{source: "age - 2 * 10 == 20", want: true},
+		{source: "(age - 2) * 10 == 380", want: true},
+		{source: "-age + 50 == 10", want: true},
+		{source: "household_size != 4", want: false},
+		{source: "count(relation == 1 and age < 12) >= 2", want: true},
+		{source: "count(relation == 1) == 3", want: false},
+		{source: "any(relation == 2)", want: true},
+		{source: "all(age < 18)", want: false},
+		{source: "all(age < 40)", want: true},
+		{source: "true and false or true", want: true},
+		{source: "true or false and false", want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.source, func(t *testing.T) {
+			program, err := Compile(tt.source, applicantScope)
+			if err != nil {
+				t.Fatalf("Compile(%q) error = %v", tt.source, err)
+			}
+			if got := program.Eval(env); got != tt.want {
+				t.Fatalf("Eval(%q) = %t, want %t", tt.source, got, tt.want)
+			}
+			if program.String() != tt.source {
+				t.Fatalf("String() = %q, want %q", program.String(), tt.source)
+			}
+		})
+	}
+}
+
+// variables and members missing from the env evaluate as 0 and as an empty household
+func TestEvalEmptyEnv(t *testing.T) {
+	tests := []struct {
+		source string
+		want   bool
+	}{
+		{source: "age == 0", want: true},
+		{source: "count(age > 0) == 0", want: true},
+		{source: "any(age > 0)", want: false},
+		{source: "all(age > 0)", want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.source, func(t *testing.T) {
+			program, err := Compile(tt.source, applicantScope)
+			if err != nil {
+				t.Fatalf("Compile(%q) error = %v", tt.source, err)
+			}
+			if got := program.Eval(Env{}); got != tt.want {
+				t.Fatalf("Eval(%q) = %t, want %t", tt.source, got, tt.want)
+			}
+		})
+	}
+}
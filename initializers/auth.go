@@ -0,0 +1,34 @@
+package initializers
+
+import (
+	"log"
+	"os"
+	"time"
+)
+
+var JWTSecret []byte
+
+// a HS256 secret shorter than the 32 bytes of its hash can be brute forced offline from any token
+const minJWTSecretLength = 32
+
+// how long the tokens issued at login are valid
+var JWTTTL = 12 * time.Hour
+
+func LoadJWTConfig() {
+	secret := os.Getenv("JWT_SECRET")
+	if secret == "" {
+		log.Fatal("JWT_SECRET is not set")
+	}
+	if len(secret) < minJWTSecretLength {
+		log.Fatalf("JWT_SECRET must be at least %d bytes long", minJWTSecretLength)
+	}
+	JWTSecret = []byte(secret)
+
+	if ttl := os.Getenv("JWT_TTL"); ttl != "" {
+		duration, err := time.ParseDuration(ttl)
+		if err != nil {
+			log.Fatal("Invalid JWT_TTL:", err)
+		}
+		JWTTTL = duration
+	}
+}
@@ -0,0 +1,20 @@
+package initializers
+
+import (
+	"log"
+	"os"
+	"time"
+)
+
+// how often the benefit scheduler materialises the due instalments, 0 disables it
+var SchedulerInterval = time.Hour
+
+func LoadSchedulerConfig() {
+	if interval := os.Getenv("SCHEDULER_INTERVAL"); interval != "" {
+		duration, err := time.ParseDuration(interval)
+		if err != nil {
+			log.Fatal("Invalid SCHEDULER_INTERVAL:", err)
+		}
+		SchedulerInterval = duration
+	}
+}
@@ -3,6 +3,7 @@ package main
 import (
 	"FASMS/controllers"
 	"FASMS/initializers"
+	"FASMS/models"
 
 	"github.com/gin-contrib/cors"
 
@@ -12,6 +13,8 @@ import (
 func init() {
 	initializers.GetEnvs()
 	initializers.ConnectDB()
+	initializers.LoadJWTConfig()
+	initializers.LoadSchedulerConfig()
 }
 
 func main() {
@@ -21,42 +24,126 @@ func main() {
 	router.Use(cors.New(cors.Config{
 		AllowOrigins:     []string{"http://localhost:3000", "http://13.228.252.37"}, // Change to frontend URL
 		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowHeaders:     []string{"Origin", "Content-Type", "Authorization"},
+		AllowHeaders:     []string{"Origin", "Content-Type", "Authorization", "X-API-Key"},
 		AllowCredentials: true,
 	}))
 
 	ApplicantController := controllers.NewApplicantController(initializers.DB)
 	ApplicationController := controllers.NewApplicationController(initializers.DB)
 	SchemeController := controllers.NewSchemeController(initializers.DB)
+	AuditController := controllers.NewAuditController(initializers.DB)
+	AuthController := controllers.NewAuthController(initializers.DB, initializers.JWTSecret, initializers.JWTTTL)
+	ApiKeyController := controllers.NewApiKeyController(initializers.DB)
+	DisbursementController := controllers.NewDisbursementController(initializers.DB)
+	BenefitScheduler := controllers.NewBenefitScheduler(initializers.DB)
+	BenefitScheduleController := controllers.NewBenefitScheduleController(initializers.DB, BenefitScheduler)
+	if initializers.SchedulerInterval > 0 {
+		BenefitScheduler.Start(initializers.SchedulerInterval)
+	}
 	apiRouter := router.Group("/api")
 	{
-		applicantRouter := apiRouter.Group("/applicants")
+		authRouter := apiRouter.Group("/auth")
+		{
+			authRouter.POST("/login", AuthController.Login)
+		}
+	}
+
+	// every other endpoint needs a bearer token or an api key, the mutations are restricted per role and admins can call all of them.
+	// api keys can only call the route groups they are scoped to
+	securedRouter := apiRouter.Group("/", AuthController.Authenticate())
+	adminOnly := controllers.RequireRoles()
+	policyEditor := controllers.RequireRoles(models.RolePolicyEditor)
+	caseOfficer := controllers.RequireRoles(models.RoleCaseOfficer)
+	{
+		userRouter := securedRouter.Group("/users")
+		{
+			userRouter.GET("/me", AuthController.GetCurrentUser)
+			userRouter.POST("/", adminOnly, AuthController.CreateUser)
+		}
+
+		apiKeyRouter := securedRouter.Group("/api-keys", adminOnly)
+		{
+			apiKeyRouter.GET("/", ApiKeyController.GetApiKeys)
+			apiKeyRouter.POST("/", ApiKeyController.CreateApiKey)
+			apiKeyRouter.DELETE("/:id", ApiKeyController.RevokeApiKey)
+		}
+
+		applicantRouter := securedRouter.Group("/applicants", controllers.RequireScope(models.ScopeApplicants))
 		{
 			applicantRouter.GET("/", ApplicantController.GetApplicantsList)
-			applicantRouter.POST("/", ApplicantController.CreateApplicants)
+			applicantRouter.POST("/", caseOfficer, ApplicantController.CreateApplicants)
 
-			applicantRouter.PUT("/:id", ApplicantController.UpdateApplicant)
-			applicantRouter.DELETE("/:id", ApplicantController.DeleteApplicant)
+			applicantRouter.PUT("/:id", caseOfficer, ApplicantController.UpdateApplicant)
+			applicantRouter.DELETE("/:id", caseOfficer, ApplicantController.DeleteApplicant)
 		}
 
-		schemesRouter := apiRouter.Group("/schemes")
+		schemesRouter := securedRouter.Group("/schemes", controllers.RequireScope(models.ScopeSchemes))
 		{
 			schemesRouter.GET("/", SchemeController.GetSchemesList)
-			schemesRouter.GET("/eligible", SchemeController.GetEligibleSchemesList) // ?applicant={id}
+			schemesRouter.GET("/eligible", SchemeController.GetEligibleSchemesList)      // ?applicant={id}
+			schemesRouter.GET("/:id/eligibility", SchemeController.GetSchemeEligibility) // ?applicant={id}
+			schemesRouter.GET("/:id/near-miss", SchemeController.GetSchemeNearMiss)      // ?applicant={id}
+			schemesRouter.GET("/:id/eligible-applicants", SchemeController.GetEligibleApplicants)
+			schemesRouter.GET("/:id/versions", SchemeController.GetSchemeVersions)
+			schemesRouter.GET("/:id/versions/diff", SchemeController.GetSchemeVersionsDiff) // ?from={version}&to={version}
 
-			schemesRouter.POST("/", SchemeController.AddSchemes)
-			schemesRouter.PUT("/:id", SchemeController.UpdateScheme)
-			schemesRouter.DELETE("/:id", SchemeController.DeleteScheme)
+			schemesRouter.POST("/", policyEditor, SchemeController.AddSchemes)
+			schemesRouter.PUT("/:id", policyEditor, SchemeController.UpdateScheme)
+			schemesRouter.POST("/:id/simulate", policyEditor, SchemeController.SimulateScheme)
+			schemesRouter.POST("/:id/submit", policyEditor, SchemeController.SubmitScheme)
+			schemesRouter.POST("/:id/reject", adminOnly, SchemeController.RejectScheme)
+			schemesRouter.POST("/:id/publish", adminOnly, SchemeController.PublishScheme)
+			schemesRouter.POST("/:id/retire", adminOnly, SchemeController.RetireScheme)
+			schemesRouter.DELETE("/:id", policyEditor, SchemeController.DeleteScheme)
+		}
+
+		eligibilityRouter := securedRouter.Group("/eligibility", controllers.RequireScope(models.ScopeSchemes))
+		{
+			eligibilityRouter.POST("/check", SchemeController.CheckEligibility)
 		}
 
-		applicationRouter := apiRouter.Group("/applications")
+		applicationRouter := securedRouter.Group("/applications", controllers.RequireScope(models.ScopeApplications))
 
 		{
 			applicationRouter.GET("/", ApplicationController.GetApplicationList)
-			applicationRouter.POST("/", ApplicationController.CreateApplication)
+			applicationRouter.GET("/:id/evidence", ApplicationController.GetApplicationEvidence)
+			applicationRouter.POST("/", caseOfficer, ApplicationController.CreateApplication)
 
-			applicationRouter.PUT("/:id", ApplicationController.UpdateApplication)
-			applicationRouter.DELETE("/:id", ApplicationController.DeleteApplication)
+			applicationRouter.PUT("/:id", caseOfficer, ApplicationController.UpdateApplication)
+			applicationRouter.POST("/:id/approve", caseOfficer, ApplicationController.ApproveApplication)
+			applicationRouter.POST("/:id/reject", caseOfficer, ApplicationController.RejectApplication)
+			applicationRouter.POST("/:id/withdraw", caseOfficer, ApplicationController.WithdrawApplication)
+			applicationRouter.POST("/:id/reopen", caseOfficer, ApplicationController.ReopenApplication)
+			applicationRouter.DELETE("/:id", caseOfficer, ApplicationController.DeleteApplication)
+		}
+
+		disbursementRouter := securedRouter.Group("/disbursements", controllers.RequireScope(models.ScopeDisbursements))
+		{
+			disbursementRouter.GET("/", DisbursementController.GetDisbursements) // ?applicant={id}&scheme={id}&run={id}
+			disbursementRouter.GET("/:id/ledger", DisbursementController.GetDisbursementLedger)
+
+			disbursementRouter.POST("/:id/reverse", caseOfficer, DisbursementController.ReverseDisbursement)
+			disbursementRouter.POST("/:id/retry", caseOfficer, DisbursementController.RetryDisbursement)
+		}
+
+		paymentRunRouter := securedRouter.Group("/payment-runs", controllers.RequireScope(models.ScopeDisbursements))
+		{
+			paymentRunRouter.GET("/", DisbursementController.GetPaymentRuns)
+			paymentRunRouter.GET("/:id", DisbursementController.GetPaymentRun)
+
+			paymentRunRouter.POST("/", caseOfficer, DisbursementController.CreatePaymentRun)
+			paymentRunRouter.POST("/:id/settle", caseOfficer, DisbursementController.SettlePaymentRun)
+		}
+
+		scheduleRouter := securedRouter.Group("/schedules", controllers.RequireScope(models.ScopeDisbursements))
+		{
+			scheduleRouter.GET("/", BenefitScheduleController.GetBenefitSchedules) // ?application={id}
+			scheduleRouter.POST("/run", adminOnly, BenefitScheduleController.RunScheduler)
+		}
+
+		auditRouter := securedRouter.Group("/audit")
+		{
+			auditRouter.GET("/", adminOnly, AuditController.GetAuditLogs)
 		}
 	}
 	router.Run()
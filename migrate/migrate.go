@@ -3,8 +3,18 @@ package main
 import (
 	"FASMS/initializers"
 	"FASMS/models"
+	"FASMS/utils"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
 
 	"log"
+	"os"
+
+	"gorm.io/gorm"
 )
 
 func init() {
@@ -17,20 +27,44 @@ func main() {
 		log.Fatal("Database connection is nil")
 	}
 
+	// incomes and assets used to be floats, they are converted to numeric before the tables are migrated
+	if err := migrateMoneyColumns(&models.Applicants{}, "monthly_income", "assets"); err != nil {
+		log.Fatal("Failed to convert Applicants incomes and assets to numeric:", err)
+	}
+
 	err := initializers.DB.AutoMigrate(&models.Applicants{})
 	if err != nil {
 		log.Fatal("Failed to migrate Applicants table:", err)
 	}
 
+	if err := migrateMoneyColumns(&models.Households{}, "monthly_income", "assets"); err != nil {
+		log.Fatal("Failed to convert Households incomes and assets to numeric:", err)
+	}
+
 	err = initializers.DB.AutoMigrate(&models.Households{})
 	if err != nil {
 		log.Fatal("Failed to migrate Households table:", err)
 	}
 
+	if err := migrateMoneyColumns(&models.Applications{}, "entitlement"); err != nil {
+		log.Fatal("Failed to convert Applications entitlement to numeric:", err)
+	}
+
 	err = initializers.DB.AutoMigrate(&models.Applications{})
 	if err != nil {
 		log.Fatal("Failed to migrate Applications table:", err)
 	}
+	if err := migrateJSONMoney(&models.Applications{}, "entitlement_detail"); err != nil {
+		log.Fatal("Failed to round Applications entitlement detail amounts:", err)
+	}
+
+	err = initializers.DB.AutoMigrate(&models.ApplicationEvidences{})
+	if err != nil {
+		log.Fatal("Failed to migrate Application Evidences table:", err)
+	}
+	if err := migrateJSONMoney(&models.ApplicationEvidences{}, "snapshot"); err != nil {
+		log.Fatal("Failed to round Application Evidences snapshot amounts:", err)
+	}
 
 	err = initializers.DB.AutoMigrate(&models.Schemes{})
 	if err != nil {
@@ -41,16 +75,270 @@ func main() {
 	if err != nil {
 		log.Fatal("Failed to migrate Criteria Group table:", err)
 	}
+	// before the criterias table is migrated, which drops the old max_count default
+	if err := migrateMaxCount(); err != nil {
+		log.Fatal("Failed to convert Criterias max_count:", err)
+	}
+	if err := migrateMoneyColumns(&models.Criterias{}, "max_monthly_income", "max_assets"); err != nil {
+		log.Fatal("Failed to convert Criterias income and asset limits to numeric:", err)
+	}
+
 	err = initializers.DB.AutoMigrate(&models.Criterias{})
 	if err != nil {
 		log.Fatal("Failed to migrate Criterias table:", err)
 	}
 
+	err = initializers.DB.AutoMigrate(&models.CriteriaNode{})
+	if err != nil {
+		log.Fatal("Failed to migrate Criteria Node table:", err)
+	}
+
+	err = initializers.DB.AutoMigrate(&models.SchemeVersions{})
+	if err != nil {
+		log.Fatal("Failed to migrate Scheme Versions table:", err)
+	}
+	if err := migrateJSONMoney(&models.SchemeVersions{}, "definition"); err != nil {
+		log.Fatal("Failed to round Scheme Versions definition amounts:", err)
+	}
+
+	// amounts used to be floats, they are converted to numeric before the benefits table is migrated
+	if err := migrateMoneyColumns(&models.Benefits{}, "amount", "min_amount", "max_amount"); err != nil {
+		log.Fatal("Failed to convert Benefits amounts to numeric:", err)
+	}
+
 	err = initializers.DB.AutoMigrate(&models.Benefits{})
 	if err != nil {
 		log.Fatal("Failed to migrate Benefits table:", err)
 	}
+	if err := migrateJSONMoney(&models.Benefits{}, "tiers"); err != nil {
+		log.Fatal("Failed to round Benefits tier amounts:", err)
+	}
+
+	err = initializers.DB.AutoMigrate(&models.SchemeRelations{})
+	if err != nil {
+		log.Fatal("Failed to migrate Scheme Relations table:", err)
+	}
+
+	err = initializers.DB.AutoMigrate(&models.PaymentRuns{})
+	if err != nil {
+		log.Fatal("Failed to migrate Payment Runs table:", err)
+	}
+
+	err = initializers.DB.AutoMigrate(&models.BenefitSchedules{})
+	if err != nil {
+		log.Fatal("Failed to migrate Benefit Schedules table:", err)
+	}
+
+	err = initializers.DB.AutoMigrate(&models.Disbursements{})
+	if err != nil {
+		log.Fatal("Failed to migrate Disbursements table:", err)
+	}
+
+	err = initializers.DB.AutoMigrate(&models.LedgerEntries{})
+	if err != nil {
+		log.Fatal("Failed to migrate Ledger Entries table:", err)
+	}
+
+	err = initializers.DB.AutoMigrate(&models.AuditLogs{})
+	if err != nil {
+		log.Fatal("Failed to migrate Audit Logs table:", err)
+	}
+
+	err = initializers.DB.AutoMigrate(&models.Users{})
+	if err != nil {
+		log.Fatal("Failed to migrate Users table:", err)
+	}
+
+	err = initializers.DB.AutoMigrate(&models.ApiKeys{})
+	if err != nil {
+		log.Fatal("Failed to migrate Api Keys table:", err)
+	}
 
+	// the first admin is created from the environment, the other users are created by admins through the API
+	adminUsername := os.Getenv("ADMIN_USERNAME")
+	adminPassword := os.Getenv("ADMIN_PASSWORD")
+	if adminUsername != "" && adminPassword != "" {
+		var count int64
+		if err := initializers.DB.Model(&models.Users{}).Where("username = ?", adminUsername).Count(&count).Error; err != nil {
+			log.Fatal("Failed to check admin user:", err)
+		}
+		if count == 0 {
+			admin, err := models.NewUser(adminUsername, adminPassword, models.RoleAdmin)
+			if err != nil {
+				log.Fatal("Failed to hash admin password:", err)
+			}
+			if err := initializers.DB.Create(&admin).Error; err != nil {
+				log.Fatal("Failed to create admin user:", err)
+			}
+		}
+	}
+
+}
+
+// max_count used to default to 999 for no upper limit, no limit is now null so that a max of 0 can be stored.
+// the conversion only runs while the column still has the old default, a max of 999 saved since then is kept.
+// nothing is converted when the table does not exist yet
+func migrateMaxCount() error {
+	var columnDefault *string
+	if err := initializers.DB.Raw(`SELECT column_default FROM information_schema.columns WHERE table_schema = current_schema() AND table_name = ? AND column_name = ?`, "criterias", "max_count").Scan(&columnDefault).Error; err != nil {
+		return err
+	}
+	if columnDefault == nil || !strings.Contains(*columnDefault, "999") {
+		return nil
+	}
+	return initializers.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec(`ALTER TABLE criterias ALTER COLUMN max_count DROP DEFAULT`).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.Criterias{}).Where("max_count = ?", 999).Update("max_count", nil).Error; err != nil {
+			return err
+		}
+		log.Printf("converted criterias.max_count 999 to null\n")
+		return nil
+	})
+}
+
+// converts the float money columns of an existing table to numeric(14,2). the cast goes through the shortest text
+// of the float, a direct float4 to numeric cast keeps only 6 significant digits, rounding that text to cents
+// gives back the amount that was entered
+func migrateMoneyColumns(model interface{}, columns ...string) error {
+	migrator := initializers.DB.Migrator()
+	if !migrator.HasTable(model) {
+		return nil
+	}
+	columnTypes, err := migrator.ColumnTypes(model)
+	if err != nil {
+		return err
+	}
+	stmt := &gorm.Statement{DB: initializers.DB}
+	if err := stmt.Parse(model); err != nil {
+		return err
+	}
+	for _, columnType := range columnTypes {
+		if !slices.Contains(columns, columnType.Name()) {
+			continue
+		}
+		switch strings.ToLower(columnType.DatabaseTypeName()) {
+		case "float4", "float8", "real", "double precision":
+		default:
+			continue
+		}
+		sql := fmt.Sprintf(`ALTER TABLE %q ALTER COLUMN %q TYPE numeric(14,2) USING round(%q::text::numeric, 2)`, stmt.Schema.Table, columnType.Name(), columnType.Name())
+		if err := initializers.DB.Exec(sql).Error; err != nil {
+			return err
+		}
+		log.Printf("converted %s.%s to numeric\n", stmt.Schema.Table, columnType.Name())
+	}
+	return nil
+}
+
+// the json keys holding an amount in the jsonb columns: benefit tiers, scheme definitions, entitlement details
+// and the applicants and criterias of the evidence snapshots
+var jsonMoneyKeys = []string{"amount", "min_amount", "max_amount", "budget", "total", "up_to",
+	"monthly_income", "assets", "max_monthly_income", "max_assets"}
+
+// rounds the amounts stored as floats in a jsonb column to cents, like migrateMoneyColumns does for the numeric
+// columns, an amount with more than 2 decimals no longer decodes. only the rows with such an amount are updated
+func migrateJSONMoney(model interface{}, column string) error {
+	stmt := &gorm.Statement{DB: initializers.DB}
+	if err := stmt.Parse(model); err != nil {
+		return err
+	}
+	var rows []struct {
+		ID       string
+		Document string
+	}
+	query := fmt.Sprintf(`SELECT id, %q::text AS document FROM %q WHERE %q IS NOT NULL`, column, stmt.Schema.Table, column)
+	if err := initializers.DB.Raw(query).Scan(&rows).Error; err != nil {
+		return err
+	}
+	for _, row := range rows {
+		decoder := json.NewDecoder(bytes.NewReader([]byte(row.Document)))
+		decoder.UseNumber()
+		var document interface{}
+		if err := decoder.Decode(&document); err != nil {
+			return fmt.Errorf("%s %s: %w", stmt.Schema.Table, row.ID, err)
+		}
+		rounded, changed, err := roundJSONMoney(document)
+		if err != nil {
+			return fmt.Errorf("%s %s: %w", stmt.Schema.Table, row.ID, err)
+		}
+		if !changed {
+			continue
+		}
+		encoded, err := json.Marshal(rounded)
+		if err != nil {
+			return err
+		}
+		update := fmt.Sprintf(`UPDATE %q SET %q = ? WHERE id = ?`, stmt.Schema.Table, column)
+		if err := initializers.DB.Exec(update, string(encoded), row.ID).Error; err != nil {
+			return err
+		}
+		log.Printf("rounded the amounts of %s.%s of %s\n", stmt.Schema.Table, column, row.ID)
+	}
+	return nil
+}
+
+// rounds the amounts of the json keys holding one, in every nested object and array
+func roundJSONMoney(value interface{}) (interface{}, bool, error) {
+	changed := false
+	switch value := value.(type) {
+	case map[string]interface{}:
+		for key, item := range value {
+			if number, ok := item.(json.Number); ok && slices.Contains(jsonMoneyKeys, key) {
+				if _, err := utils.ParseMoney(number.String()); err == nil {
+					continue
+				}
+				amount, err := roundMoney(number.String())
+				if err != nil {
+					return nil, false, err
+				}
+				value[key] = amount
+				changed = true
+				continue
+			}
+			rounded, itemChanged, err := roundJSONMoney(item)
+			if err != nil {
+				return nil, false, err
+			}
+			value[key] = rounded
+			changed = changed || itemChanged
+		}
+	case []interface{}:
+		for i, item := range value {
+			rounded, itemChanged, err := roundJSONMoney(item)
+			if err != nil {
+				return nil, false, err
+			}
+			value[i] = rounded
+			changed = changed || itemChanged
+		}
+	}
+	return value, changed, nil
+}
+
+// rounds the decimal text of an amount half away from zero to cents, on the text so that 0.125 gives 0.13
+func roundMoney(text string) (utils.Money, error) {
+	units, decimals, _ := strings.Cut(text, ".")
+	if strings.ContainsAny(text, "eE") || len(decimals) <= 2 {
+		amount, err := strconv.ParseFloat(text, 64)
+		if err != nil {
+			return 0, err
+		}
+		return utils.NewMoneyFromFloat(amount), nil
+	}
+	amount, err := utils.ParseMoney(units + "." + decimals[:2])
+	if err != nil {
+		return 0, err
+	}
+	if decimals[2] >= '5' {
+		if strings.HasPrefix(units, "-") {
+			amount--
+		} else {
+			amount++
+		}
+	}
+	return amount, nil
 }
 
 //go mod migrate/migrate.go
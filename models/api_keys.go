@@ -0,0 +1,108 @@
+package models
+
+import (
+	"FASMS/utils"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"time"
+)
+
+// the route groups an api key can be scoped to, the audit log is admin only so no api key can read it
+const (
+	ScopeApplicants    = "applicants"
+	ScopeSchemes       = "schemes"
+	ScopeApplications  = "applications"
+	ScopeDisbursements = "disbursements"
+)
+
+const apiKeyPrefix = "fasms_"
+
+// a key for system to system integrations, only the sha256 of the key is stored,
+// the key itself is shown once when it is created
+type ApiKeys struct {
+	ID         string     `json:"id" gorm:"primaryKey"`
+	Name       string     `json:"name" gorm:"not null"`
+	KeyPrefix  string     `json:"key_prefix" gorm:"comment:'the first characters of the key, to recognise it'"`
+	KeyHash    string     `json:"-" gorm:"uniqueIndex;not null"`
+	Role       uint       `json:"role" gorm:"not null;comment:'2: policy editor, 3: case officer, 4: read only'"`
+	Scopes     string     `json:"scopes" gorm:"comment:'comma separated route groups: applicants, schemes, applications, disbursements'"`
+	CreatedBy  string     `json:"created_by"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+	CommonTime
+}
+
+type CreateApiKeyRequest struct {
+	Name   string   `json:"name" binding:"required"`
+	Role   uint     `json:"role" binding:"required,oneof=2 3 4"`
+	Scopes []string `json:"scopes" binding:"required,min=1,dive,oneof=applicants schemes applications disbursements"`
+}
+
+type ApiKeysResponse struct {
+	ID         string     `json:"id"`
+	Name       string     `json:"name"`
+	KeyPrefix  string     `json:"key_prefix"`
+	Role       uint       `json:"role"`
+	Scopes     []string   `json:"scopes"`
+	CreatedBy  string     `json:"created_by"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+}
+
+// the response of a creation, the only one holding the key
+type CreateApiKeyResponse struct {
+	Key string `json:"key"`
+	ApiKeysResponse
+}
+
+// returns the new api key and the key to give to the integration
+func (r *CreateApiKeyRequest) ConvertToModel(createdBy string) (ApiKeys, string, error) {
+	secret := make([]byte, 24)
+	if _, err := rand.Read(secret); err != nil {
+		return ApiKeys{}, "", err
+	}
+	key := apiKeyPrefix + hex.EncodeToString(secret)
+	return ApiKeys{
+		ID:        utils.GenerateUUID(),
+		Name:      r.Name,
+		KeyPrefix: key[:len(apiKeyPrefix)+8],
+		KeyHash:   HashApiKey(key),
+		Role:      r.Role,
+		Scopes:    strings.Join(r.Scopes, ","),
+		CreatedBy: createdBy,
+	}, key, nil
+}
+
+// the keys are random, so a plain sha256 is enough to keep them unusable if the table leaks
+func HashApiKey(key string) string {
+	hash := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(hash[:])
+}
+
+func (k *ApiKeys) GetScopes() []string {
+	if k.Scopes == "" {
+		return []string{}
+	}
+	return strings.Split(k.Scopes, ",")
+}
+
+func (k *ApiKeys) IsRevoked() bool {
+	return k.RevokedAt != nil
+}
+
+func (k *ApiKeys) ConvertToResponse() ApiKeysResponse {
+	return ApiKeysResponse{
+		ID:         k.ID,
+		Name:       k.Name,
+		KeyPrefix:  k.KeyPrefix,
+		Role:       k.Role,
+		Scopes:     k.GetScopes(),
+		CreatedBy:  k.CreatedBy,
+		CreatedAt:  k.CreatedAt,
+		LastUsedAt: k.LastUsedAt,
+		RevokedAt:  k.RevokedAt,
+	}
+}
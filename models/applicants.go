@@ -6,28 +6,33 @@ import (
 )
 
 type Applicants struct {
-	ID               string       `json:"id" gorm:"primaryKey"`
-	Name             string       `json:"name"`
-	MaritalStatus    uint         `json:"marital_status"  gorm:"comment:'1: Single,, 2: Married,, 3: Widowed, 4:Divorced'"`
-	IC               string       `json:"ic" gorm:"unique,not null"`
-	EmploymentStatus uint         `json:"employment_status" gorm:"comment:'1: unemployed, 2: employed, 3: in school'"`
-	Sex              uint         `json:"sex" gorm:"comment:'1: male, 2: female"`
-	DOB              time.Time    `gorm:"type:date" json:"dob"`
-	Households       []Households `json:"households" gorm:"foreignKey:ApplicantID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE"`
+	ID               string         `json:"id" gorm:"primaryKey"`
+	Name             string         `json:"name"`
+	MaritalStatus    uint           `json:"marital_status"  gorm:"comment:'1: Single,, 2: Married,, 3: Widowed, 4:Divorced'"`
+	IC               string         `json:"ic" gorm:"unique,not null"`
+	EmploymentStatus uint           `json:"employment_status" gorm:"comment:'1: unemployed, 2: employed, 3: in school'"`
+	Sex              uint           `json:"sex" gorm:"comment:'1: male, 2: female"`
+	DOB              time.Time      `gorm:"type:date" json:"dob"`
+	MonthlyIncome    utils.Money    `json:"monthly_income" gorm:"type:numeric(14,2);default:0"`
+	Assets           utils.Money    `json:"assets" gorm:"type:numeric(14,2);default:0"`
+	Households       []Households   `json:"households" gorm:"foreignKey:ApplicantID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE"`
+	Applications     []Applications `json:"-" gorm:"foreignKey:ApplicantID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE"`
 	CommonTime
 }
 
 type Households struct {
-	ID               string     `json:"id" gorm:"primaryKey"`
-	Name             string     `json:"name"`
-	MaritalStatus    uint       `json:"marital_status"  gorm:"comment:'1: Single,, 2: Married,, 3: Widowed, 4:Divorced'"`
-	IC               string     `json:"ic"`
-	EmploymentStatus uint       `json:"employment_status" gorm:"comment:'1: unemployed, 2: employed, 3: in school'"`
-	Sex              uint       `json:"sex" gorm:"comment:'1: male, 2: female"`
-	DOB              time.Time  `gorm:"type:date" json:"dob"`
-	Relation         uint       `json:"relation" gorm:"comment:'1: children, 2: spouse, 3: parents'"`
-	ApplicantID      string     `json:"applicant_id" gorm:"index;not null"`
-	Applicant        Applicants `json:"-" gorm:"foreignKey:ApplicantID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE"`
+	ID               string      `json:"id" gorm:"primaryKey"`
+	Name             string      `json:"name"`
+	MaritalStatus    uint        `json:"marital_status"  gorm:"comment:'1: Single,, 2: Married,, 3: Widowed, 4:Divorced'"`
+	IC               string      `json:"ic"`
+	EmploymentStatus uint        `json:"employment_status" gorm:"comment:'1: unemployed, 2: employed, 3: in school'"`
+	Sex              uint        `json:"sex" gorm:"comment:'1: male, 2: female"`
+	DOB              time.Time   `gorm:"type:date" json:"dob"`
+	Relation         uint        `json:"relation" gorm:"comment:'1: children, 2: spouse, 3: parents'"`
+	MonthlyIncome    utils.Money `json:"monthly_income" gorm:"type:numeric(14,2);default:0"`
+	Assets           utils.Money `json:"assets" gorm:"type:numeric(14,2);default:0"`
+	ApplicantID      string      `json:"applicant_id" gorm:"index;not null"`
+	Applicant        Applicants  `json:"-" gorm:"foreignKey:ApplicantID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE"`
 	CommonTime
 }
 
@@ -41,17 +46,34 @@ type CreateApplicants struct {
 	EmploymentStatus uint               `json:"employment_status" binding:"required,oneof=1 2 3"`
 	Sex              uint               `json:"sex" binding:"required,oneof=1 2"`
 	DOB              utils.Date         `json:"dob" binding:"required"`
+	MonthlyIncome    utils.Money        `json:"monthly_income" binding:"gte=0"`
+	Assets           utils.Money        `json:"assets" binding:"gte=0"`
 	Households       []CreateHouseholds `json:"households"`
 }
 type CreateHouseholds struct {
-	ID               string     `json:"id" gorm:"primaryKey"`
-	Name             string     `json:"name" binding:"required"`
-	MaritalStatus    uint       `json:"marital_status" binding:"required,oneof=1 2 3 4"`
-	IC               string     `json:"ic"  binding:"required"`
-	EmploymentStatus uint       `json:"employment_status" binding:"required,oneof=1 2 3"`
-	Sex              uint       `json:"sex" binding:"required,oneof=1 2"`
-	DOB              utils.Date `json:"dob" binding:"required"`
-	Relation         uint       `json:"relation" binding:"required,oneof=1 2 3"`
+	ID               string      `json:"id" gorm:"primaryKey"`
+	Name             string      `json:"name" binding:"required"`
+	MaritalStatus    uint        `json:"marital_status" binding:"required,oneof=1 2 3 4"`
+	IC               string      `json:"ic"  binding:"required"`
+	EmploymentStatus uint        `json:"employment_status" binding:"required,oneof=1 2 3"`
+	Sex              uint        `json:"sex" binding:"required,oneof=1 2"`
+	DOB              utils.Date  `json:"dob" binding:"required"`
+	Relation         uint        `json:"relation" binding:"required,oneof=1 2 3"`
+	MonthlyIncome    utils.Money `json:"monthly_income" binding:"gte=0"`
+	Assets           utils.Money `json:"assets" binding:"gte=0"`
+}
+
+// an applicant screened without being registered, with the applications it already holds
+type CheckEligibilityRequest struct {
+	CreateApplicants
+	Applications []CheckEligibilityApplication `json:"applications" binding:"dive"`
+}
+
+// an application held by the screened applicant, applied_at defaults to today
+type CheckEligibilityApplication struct {
+	SchemeID          string      `json:"scheme_id" binding:"required"`
+	ApplicationStatus uint        `json:"application_status" binding:"required,oneof=1 2 3 4 5 6 7 8 9"`
+	AppliedAt         *utils.Date `json:"applied_at"`
 }
 type CreateApplicantsRequest struct {
 	Applicants []CreateApplicants `json:"applicants" binding:"required,dive"`
@@ -65,17 +87,21 @@ type ApplicantsResponse struct {
 	EmploymentStatus uint                 `json:"employment_status"`
 	Sex              uint                 `json:"sex"`
 	DOB              utils.Date           `json:"dob"`
+	MonthlyIncome    utils.Money          `json:"monthly_income"`
+	Assets           utils.Money          `json:"assets"`
 	Households       []HouseholdsResponse `json:"households"`
 }
 type HouseholdsResponse struct {
-	ID               string     `json:"id"`
-	Name             string     `json:"name"`
-	MaritalStatus    uint       `json:"marital_status"`
-	IC               string     `json:"ic"`
-	EmploymentStatus uint       `json:"employment_status"`
-	Sex              uint       `json:"sex"`
-	DOB              utils.Date `json:"dob"`
-	Relation         uint       `json:"relation"`
+	ID               string      `json:"id"`
+	Name             string      `json:"name"`
+	MaritalStatus    uint        `json:"marital_status"`
+	IC               string      `json:"ic"`
+	EmploymentStatus uint        `json:"employment_status"`
+	Sex              uint        `json:"sex"`
+	DOB              utils.Date  `json:"dob"`
+	Relation         uint        `json:"relation"`
+	MonthlyIncome    utils.Money `json:"monthly_income"`
+	Assets           utils.Money `json:"assets"`
 }
 
 func (a *Applicants) ConvertToResponse() ApplicantsResponse {
@@ -87,6 +113,8 @@ func (a *Applicants) ConvertToResponse() ApplicantsResponse {
 		EmploymentStatus: a.EmploymentStatus,
 		Sex:              a.Sex,
 		DOB:              utils.Date(a.DOB),
+		MonthlyIncome:    a.MonthlyIncome,
+		Assets:           a.Assets,
 	}
 	for _, household := range a.Households {
 		applicants.Households = append(applicants.Households, HouseholdsResponse{
@@ -98,6 +126,8 @@ func (a *Applicants) ConvertToResponse() ApplicantsResponse {
 			Sex:              household.Sex,
 			DOB:              utils.Date(household.DOB),
 			Relation:         household.Relation,
+			MonthlyIncome:    household.MonthlyIncome,
+			Assets:           household.Assets,
 		})
 	}
 	return applicants
@@ -107,51 +137,109 @@ func (a *CreateApplicantsRequest) ConvertToModel() []Applicants {
 
 	var applicants []Applicants
 	for _, appReq := range a.Applicants {
-		applicant := Applicants{
+		applicants = append(applicants, appReq.ConvertToModel())
+	}
+	return applicants
+}
+
+func (a *CreateApplicants) ConvertToModel() Applicants {
+	applicant := Applicants{
+		ID:               utils.GenerateUUID(),
+		IC:               a.IC,
+		Name:             a.Name,
+		MaritalStatus:    a.MaritalStatus,
+		EmploymentStatus: a.EmploymentStatus,
+		Sex:              a.Sex,
+		DOB:              a.DOB.ToTime(),
+		MonthlyIncome:    a.MonthlyIncome,
+		Assets:           a.Assets,
+	}
+	var households []Households
+
+	for _, household := range a.Households {
+		households = append(households, Households{
 			ID:               utils.GenerateUUID(),
-			IC:               appReq.IC,
-			Name:             appReq.Name,
-			MaritalStatus:    appReq.MaritalStatus,
-			EmploymentStatus: appReq.EmploymentStatus,
-			Sex:              appReq.Sex,
-			DOB:              appReq.DOB.ToTime(),
-		}
-		var households []Households
-
-		for _, household := range appReq.Households {
-			households = append(households, Households{
-				ID:               utils.GenerateUUID(),
-				Name:             household.Name,
-				MaritalStatus:    household.MaritalStatus,
-				IC:               household.IC,
-				EmploymentStatus: household.EmploymentStatus,
-				Sex:              household.Sex,
-				DOB:              household.DOB.ToTime(),
-				Relation:         household.Relation,
-				ApplicantID:      applicant.ID,
-			})
+			Name:             household.Name,
+			MaritalStatus:    household.MaritalStatus,
+			IC:               household.IC,
+			EmploymentStatus: household.EmploymentStatus,
+			Sex:              household.Sex,
+			DOB:              household.DOB.ToTime(),
+			Relation:         household.Relation,
+			MonthlyIncome:    household.MonthlyIncome,
+			Assets:           household.Assets,
+			ApplicantID:      applicant.ID,
+		})
+	}
+	applicant.Households = households
+	return applicant
+}
+
+// the applications the screened applicant holds are only kept in memory, with generated ids,
+// so that the relations of the schemes are checked against them
+func (r *CheckEligibilityRequest) ConvertToModel() Applicants {
+	applicant := r.CreateApplicants.ConvertToModel()
+	now := utils.Now()
+	for _, application := range r.Applications {
+		appliedAt := now
+		if application.AppliedAt != nil {
+			appliedAt = application.AppliedAt.ToTime()
 		}
-		applicant.Households = households
-		applicants = append(applicants, applicant)
+		applicant.Applications = append(applicant.Applications, Applications{
+			ID:                utils.GenerateUUID(),
+			ApplicantID:       applicant.ID,
+			SchemeID:          application.SchemeID,
+			ApplicationStatus: application.ApplicationStatus,
+			CommonTime:        CommonTime{CreatedAt: appliedAt},
+		})
 	}
-	return applicants
+	return applicant
 }
 
-func (a *Applicants) GetAge() uint32 {
-	today := time.Now()
-	age := today.Year() - a.DOB.Year()
-	// Adjust if birthday hasn't occurred yet this year
-	if today.YearDay() < a.DOB.YearDay() {
-		age--
+// total monthly income of the applicant and all household members
+func (a *Applicants) GetHouseholdIncome() utils.Money {
+	total := a.MonthlyIncome
+	for _, household := range a.Households {
+		total += household.MonthlyIncome
 	}
-	return uint32(age)
+	return total
 }
-func (h *Households) GetAge() uint32 {
-	today := time.Now()
-	age := today.Year() - h.DOB.Year()
-	// Adjust if birthday hasn't occurred yet this year
-	if today.YearDay() < h.DOB.YearDay() {
-		age--
+
+// household income divided by the household size, applicant included, rounded to the cent.
+// limits are checked with PerCapitaIncomeAbove, which does not round
+func (a *Applicants) GetPerCapitaIncome() utils.Money {
+	return a.GetHouseholdIncome().Div(int64(len(a.Households) + 1))
+}
+
+// whether the per capita income is above the limit, compared as the household income against the limit
+// times the household size so that the comparison is exact
+func (a *Applicants) PerCapitaIncomeAbove(limit utils.Money) bool {
+	return a.GetHouseholdIncome() > limit.Mul(int64(len(a.Households)+1))
+}
+
+// total assets of the applicant and all household members
+func (a *Applicants) GetHouseholdAssets() utils.Money {
+	total := a.Assets
+	for _, household := range a.Households {
+		total += household.Assets
 	}
-	return uint32(age)
+	return total
+}
+
+// age as of today
+func (a *Applicants) GetAge() uint32 {
+	return a.GetAgeAt(utils.Now())
+}
+
+func (a *Applicants) GetAgeAt(at time.Time) uint32 {
+	return utils.AgeAt(a.DOB, at)
+}
+
+// age as of today
+func (h *Households) GetAge() uint32 {
+	return h.GetAgeAt(utils.Now())
+}
+
+func (h *Households) GetAgeAt(at time.Time) uint32 {
+	return utils.AgeAt(h.DOB, at)
 }
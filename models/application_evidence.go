@@ -0,0 +1,64 @@
+package models
+
+import (
+	"FASMS/utils"
+	"encoding/json"
+	"time"
+)
+
+// the data an eligibility decision on an application was made with, kept as it was at decision time
+// so that later changes to the applicant, their households or the scheme do not lose the evidence
+type ApplicationEvidences struct {
+	ID            string       `json:"id" gorm:"primaryKey"`
+	ApplicationID string       `json:"application_id" gorm:"index;not null"`
+	Application   Applications `json:"-" gorm:"foreignKey:ApplicationID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE"`
+	SchemeVersion uint         `json:"scheme_version"`
+	EvaluatedAt   time.Time    `json:"evaluated_at"`
+	Snapshot      string       `json:"snapshot" gorm:"type:jsonb;comment:'the applicant with households, the scheme with criterias and the eligibility trace'"`
+	CommonTime
+}
+
+type EligibilitySnapshot struct {
+	Applicant   ApplicantsResponse `json:"applicant"`
+	Scheme      SchemesResponse    `json:"scheme"`
+	Eligibility EligibilityTrace   `json:"eligibility"`
+}
+
+type ApplicationEvidencesResponse struct {
+	ID            string              `json:"id"`
+	ApplicationID string              `json:"application_id"`
+	SchemeVersion uint                `json:"scheme_version"`
+	EvaluatedAt   time.Time           `json:"evaluated_at"`
+	Snapshot      EligibilitySnapshot `json:"snapshot"`
+}
+
+func NewApplicationEvidence(applicationID string, applicant Applicants, scheme Schemes, eligibility EligibilityTrace, evaluatedAt time.Time) (ApplicationEvidences, error) {
+	snapshot, err := json.Marshal(EligibilitySnapshot{
+		Applicant:   applicant.ConvertToResponse(),
+		Scheme:      scheme.ConvertToResponse(),
+		Eligibility: eligibility,
+	})
+	if err != nil {
+		return ApplicationEvidences{}, err
+	}
+	return ApplicationEvidences{
+		ID:            utils.GenerateUUID(),
+		ApplicationID: applicationID,
+		SchemeVersion: scheme.CurrentVersion,
+		EvaluatedAt:   evaluatedAt,
+		Snapshot:      string(snapshot),
+	}, nil
+}
+
+func (e *ApplicationEvidences) ConvertToResponse() ApplicationEvidencesResponse {
+	// the snapshot was marshalled from an EligibilitySnapshot, so it always unmarshals
+	var snapshot EligibilitySnapshot
+	_ = json.Unmarshal([]byte(e.Snapshot), &snapshot)
+	return ApplicationEvidencesResponse{
+		ID:            e.ID,
+		ApplicationID: e.ApplicationID,
+		SchemeVersion: e.SchemeVersion,
+		EvaluatedAt:   e.EvaluatedAt,
+		Snapshot:      snapshot,
+	}
+}
@@ -0,0 +1,84 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+)
+
+const (
+	RejectionReasonNotEligible         uint = 1
+	RejectionReasonIncompleteDocuments uint = 2
+	RejectionReasonDuplicate           uint = 3
+	RejectionReasonMeansAboveLimit     uint = 4
+	RejectionReasonFalseInformation    uint = 5
+	RejectionReasonOther               uint = 99
+)
+
+var applicationStatusNames = map[uint]string{
+	ApplicationStatusSubmitted:   "submitted",
+	ApplicationStatusApproved:    "approved",
+	ApplicationStatusRejected:    "rejected",
+	ApplicationStatusNeedReview:  "need review",
+	ApplicationStatusUnderReview: "under review",
+	ApplicationStatusWithdrawn:   "withdrawn",
+	ApplicationStatusDisbursed:   "disbursed",
+	ApplicationStatusClosed:      "closed",
+	ApplicationStatusWaitlisted:  "waitlisted",
+}
+
+// the statuses an application can move to from each status, closed is final
+var applicationTransitions = map[uint][]uint{
+	ApplicationStatusSubmitted:   {ApplicationStatusUnderReview, ApplicationStatusApproved, ApplicationStatusRejected, ApplicationStatusWithdrawn, ApplicationStatusNeedReview, ApplicationStatusWaitlisted},
+	ApplicationStatusUnderReview: {ApplicationStatusApproved, ApplicationStatusRejected, ApplicationStatusWithdrawn, ApplicationStatusNeedReview, ApplicationStatusWaitlisted},
+	ApplicationStatusNeedReview:  {ApplicationStatusUnderReview, ApplicationStatusApproved, ApplicationStatusRejected, ApplicationStatusWithdrawn, ApplicationStatusWaitlisted},
+	ApplicationStatusApproved:    {ApplicationStatusDisbursed, ApplicationStatusNeedReview, ApplicationStatusClosed},
+	ApplicationStatusDisbursed:   {ApplicationStatusNeedReview, ApplicationStatusClosed},
+	ApplicationStatusRejected:    {ApplicationStatusSubmitted},
+	ApplicationStatusWithdrawn:   {ApplicationStatusSubmitted},
+	ApplicationStatusClosed:      {},
+	ApplicationStatusWaitlisted:  {ApplicationStatusSubmitted, ApplicationStatusRejected, ApplicationStatusWithdrawn},
+}
+
+// a rejection, withdrawal or reopening, the reason is required for rejections
+type ApplicationActionRequest struct {
+	RejectionReason *uint  `json:"rejection_reason" binding:"omitempty,oneof=1 2 3 4 5 99"`
+	RejectionNote   string `json:"rejection_note"`
+}
+
+func ApplicationStatusName(status uint) string {
+	if name, ok := applicationStatusNames[status]; ok {
+		return name
+	}
+	return fmt.Sprintf("unknown status %d", status)
+}
+
+func CanTransitionApplication(from uint, to uint) bool {
+	for _, allowed := range applicationTransitions[from] {
+		if allowed == to {
+			return true
+		}
+	}
+	return false
+}
+
+// the statuses from which an application can move to the given status
+func ApplicationStatusesTransitioningTo(to uint) []uint {
+	var statuses []uint
+	for from := range applicationTransitions {
+		if CanTransitionApplication(from, to) {
+			statuses = append(statuses, from)
+		}
+	}
+	return statuses
+}
+
+// a rejection must give a reason code, and a note when the reason is other
+func ValidateRejection(reason *uint, note string) error {
+	if reason == nil {
+		return errors.New("rejection_reason is required to reject an application")
+	}
+	if *reason == RejectionReasonOther && note == "" {
+		return errors.New("rejection_note is required when the rejection_reason is other")
+	}
+	return nil
+}
@@ -0,0 +1,107 @@
+package models
+
+import (
+	"FASMS/utils"
+	"encoding/json"
+	"time"
+)
+
+const (
+	AuditEntityApplicant     = "applicant"
+	AuditEntityScheme        = "scheme"
+	AuditEntitySchemeVersion = "scheme_version"
+	AuditEntityApplication   = "application"
+	AuditEntityDisbursement  = "disbursement"
+	AuditEntityPaymentRun    = "payment_run"
+	AuditEntitySchedule      = "benefit_schedule"
+	AuditEntityUser          = "user"
+	AuditEntityApiKey        = "api_key"
+)
+
+const (
+	AuditActionCreate = "create"
+	AuditActionUpdate = "update"
+	AuditActionDelete = "delete"
+)
+
+// an append only record of a change, the rows are never updated or deleted
+type AuditLogs struct {
+	ID         string    `json:"id" gorm:"primaryKey"`
+	Actor      string    `json:"actor" gorm:"index;not null"`
+	EntityType string    `json:"entity_type" gorm:"index:idx_audit_logs_entity;not null"`
+	EntityID   string    `json:"entity_id" gorm:"index:idx_audit_logs_entity;not null"`
+	Action     string    `json:"action" gorm:"not null;comment:'create, update, delete or the name of a status transition'"`
+	Before     *string   `json:"before" gorm:"type:jsonb"`
+	After      *string   `json:"after" gorm:"type:jsonb"`
+	CreatedAt  time.Time `json:"created_at" gorm:"index"`
+}
+
+type GetAuditLogsRequest struct {
+	EntityType string     `form:"entity_type"`
+	EntityID   string     `form:"entity_id"`
+	Actor      string     `form:"actor"`
+	From       *time.Time `form:"from" time_format:"2006-01-02T15:04:05Z07:00"`
+	To         *time.Time `form:"to" time_format:"2006-01-02T15:04:05Z07:00"`
+	PaginationQuery
+}
+
+type AuditLogsResponse struct {
+	ID         string          `json:"id"`
+	Actor      string          `json:"actor"`
+	EntityType string          `json:"entity_type"`
+	EntityID   string          `json:"entity_id"`
+	Action     string          `json:"action"`
+	Before     json.RawMessage `json:"before"`
+	After      json.RawMessage `json:"after"`
+	CreatedAt  time.Time       `json:"created_at"`
+}
+
+// before and after are stored as json, nil is stored as null for creates and deletes
+func NewAuditLog(actor string, entityType string, entityID string, action string, before interface{}, after interface{}) (AuditLogs, error) {
+	auditLog := AuditLogs{
+		ID:         utils.GenerateUUID(),
+		Actor:      actor,
+		EntityType: entityType,
+		EntityID:   entityID,
+		Action:     action,
+		CreatedAt:  utils.Now(),
+	}
+	var err error
+	if auditLog.Before, err = encodeAuditValue(before); err != nil {
+		return AuditLogs{}, err
+	}
+	if auditLog.After, err = encodeAuditValue(after); err != nil {
+		return AuditLogs{}, err
+	}
+	return auditLog, nil
+}
+
+func encodeAuditValue(value interface{}) (*string, error) {
+	if value == nil {
+		return nil, nil
+	}
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	encodedValue := string(encoded)
+	return &encodedValue, nil
+}
+
+func (l *AuditLogs) ConvertToResponse() AuditLogsResponse {
+	auditLogResponse := AuditLogsResponse{
+		ID:         l.ID,
+		Actor:      l.Actor,
+		EntityType: l.EntityType,
+		EntityID:   l.EntityID,
+		Action:     l.Action,
+		CreatedAt:  l.CreatedAt,
+	}
+	if l.Before != nil {
+		auditLogResponse.Before = json.RawMessage(*l.Before)
+	}
+	if l.After != nil {
+		auditLogResponse.After = json.RawMessage(*l.After)
+	}
+	return auditLogResponse
+}
@@ -0,0 +1,356 @@
+package models
+
+import (
+	"FASMS/utils"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+)
+
+const (
+	// the amount as is
+	BenefitAmountFixed uint = 1
+	// the amount for each household member matched by the scheme's household criterias
+	BenefitAmountPerMatchedMember uint = 2
+	// the amount for each household member satisfying the member expression, every member without expression
+	BenefitAmountPerMember uint = 3
+	// the amount for each person of the household, applicant included
+	BenefitAmountPerHouseholdSize uint = 4
+	// the amount of the first tier the tier basis falls in
+	BenefitAmountTiered uint = 5
+)
+
+const (
+	TierBasisPerCapitaIncome uint = 1
+	TierBasisHouseholdIncome uint = 2
+	TierBasisApplicantAge    uint = 3
+)
+
+// a tier applies when the tier basis is up to up_to included, a tier without up_to has no upper limit.
+// up_to is an amount for the income bases and a whole age for the age basis
+type BenefitTier struct {
+	UpTo   *utils.Money `json:"up_to"`
+	Amount utils.Money  `json:"amount" binding:"gte=0"`
+}
+
+// the amount of one benefit for an application, units is what the amount was multiplied by
+type BenefitEntitlement struct {
+	BenefitID string      `json:"benefit_id"`
+	Name      string      `json:"name"`
+	Units     int64       `json:"units"`
+	Amount    utils.Money `json:"amount"`
+	Frequency uint        `json:"frequency"`
+}
+
+type Entitlement struct {
+	Total      utils.Money          `json:"total"`
+	Currency   string               `json:"currency"`
+	Benefits   []BenefitEntitlement `json:"benefits"`
+	ComputedAt time.Time            `json:"computed_at"`
+}
+
+// computes the amounts of all the benefits of the scheme from the eligibility of the applicant
+func ComputeEntitlement(applicant Applicants, scheme Schemes, eligibility EligibilityTrace, ctx EligibilityContext) (Entitlement, error) {
+	entitlement := Entitlement{Currency: utils.DefaultCurrency, Benefits: []BenefitEntitlement{}, ComputedAt: ctx.Now}
+	referenceDate := scheme.GetReferenceDate(ctx)
+	for _, benefit := range scheme.Benefits {
+		entitlement.Currency = benefit.GetCurrency()
+		benefitEntitlement, err := benefit.ComputeAmount(applicant, eligibility, referenceDate)
+		if err != nil {
+			return Entitlement{}, err
+		}
+		entitlement.Benefits = append(entitlement.Benefits, benefitEntitlement)
+		entitlement.Total += benefitEntitlement.Amount
+	}
+	return entitlement, nil
+}
+
+func (b *Benefits) GetAmountType() uint {
+	if b.AmountType == 0 {
+		return BenefitAmountFixed
+	}
+	return b.AmountType
+}
+
+func (b *Benefits) ComputeAmount(applicant Applicants, eligibility EligibilityTrace, referenceDate time.Time) (BenefitEntitlement, error) {
+	amount := b.Amount
+	var units int64 = 1
+	switch b.GetAmountType() {
+	case BenefitAmountPerMatchedMember:
+		units = int64(len(eligibility.MatchedHouseholdIDs()))
+	case BenefitAmountPerMember:
+		count, err := b.countMembers(applicant, referenceDate)
+		if err != nil {
+			return BenefitEntitlement{}, err
+		}
+		units = int64(count)
+	case BenefitAmountPerHouseholdSize:
+		units = int64(len(applicant.Households) + 1)
+	case BenefitAmountTiered:
+		tierAmount, err := b.tierAmount(applicant, referenceDate)
+		if err != nil {
+			return BenefitEntitlement{}, err
+		}
+		amount = tierAmount
+	}
+
+	amount = amount.Mul(units)
+	if b.MinAmount != nil && amount < *b.MinAmount {
+		amount = *b.MinAmount
+	}
+	if b.MaxAmount != nil && amount > *b.MaxAmount {
+		amount = *b.MaxAmount
+	}
+	return BenefitEntitlement{BenefitID: b.ID, Name: b.Name, Units: units, Amount: amount, Frequency: b.GetFrequency()}, nil
+}
+
+func (b *Benefits) countMembers(applicant Applicants, referenceDate time.Time) (int, error) {
+	if b.MemberExpression == "" {
+		return len(applicant.Households), nil
+	}
+	// the expression was compiled when the scheme was saved, an expression failing now is not paid as 0 members
+	program, err := CompileCriteriaExpression(b.MemberExpression, true)
+	if err != nil {
+		return 0, fmt.Errorf("benefit %s: invalid member expression: %w", b.ID, err)
+	}
+	count := 0
+	for _, household := range applicant.Households {
+		if program.Eval(household.expressionEnv(referenceDate)) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (b *Benefits) tierAmount(applicant Applicants, referenceDate time.Time) (utils.Money, error) {
+	tiers, err := b.GetTiers()
+	if err != nil {
+		return 0, err
+	}
+	var within func(upTo utils.Money) bool
+	switch b.TierBasis {
+	case TierBasisPerCapitaIncome:
+		within = func(upTo utils.Money) bool { return !applicant.PerCapitaIncomeAbove(upTo) }
+	case TierBasisHouseholdIncome:
+		within = func(upTo utils.Money) bool { return applicant.GetHouseholdIncome() <= upTo }
+	case TierBasisApplicantAge:
+		// the age is compared in the unit of up_to, 12 years old is 12.00
+		age := utils.Money(applicant.GetAgeAt(referenceDate)).Mul(100)
+		within = func(upTo utils.Money) bool { return age <= upTo }
+	default:
+		return 0, fmt.Errorf("benefit %s: unknown tier basis %d", b.ID, b.TierBasis)
+	}
+	for _, tier := range sortedTiers(tiers) {
+		if tier.UpTo == nil || within(*tier.UpTo) {
+			return tier.Amount, nil
+		}
+	}
+	// tiers saved before a tier without up_to was required can leave the basis above every tier
+	return 0, fmt.Errorf("benefit %s: no tier applies, the basis is above every up_to", b.ID)
+}
+
+// tiers are applied from the lowest up_to, the one without up_to last
+func sortedTiers(tiers []BenefitTier) []BenefitTier {
+	sorted := append([]BenefitTier{}, tiers...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[j].UpTo == nil {
+			return sorted[i].UpTo != nil
+		}
+		return sorted[i].UpTo != nil && *sorted[i].UpTo < *sorted[j].UpTo
+	})
+	return sorted
+}
+
+// the household members matched by the satisfied household criterias
+func (t *EligibilityTrace) MatchedHouseholdIDs() []string {
+	matched := make(map[string]bool)
+	var householdIDs []string
+	add := func(householdID string) {
+		if householdID != "" && !matched[householdID] {
+			matched[householdID] = true
+			householdIDs = append(householdIDs, householdID)
+		}
+	}
+	for _, group := range t.CriteriaGroups {
+		if !group.IsHouseHold || !group.Satisfied {
+			continue
+		}
+		for _, criteria := range group.Criterias {
+			if !criteria.Satisfied {
+				continue
+			}
+			add(criteria.HouseholdID)
+			for _, householdID := range criteria.MatchedHouseholdIDs {
+				add(householdID)
+			}
+		}
+	}
+	return householdIDs
+}
+
+func (b *Benefits) GetCurrency() string {
+	if b.Currency == "" {
+		return utils.DefaultCurrency
+	}
+	return b.Currency
+}
+
+func (b *CreateBenefitRequest) GetCurrency() string {
+	if b.Currency == "" {
+		return utils.DefaultCurrency
+	}
+	return b.Currency
+}
+
+func (b *CreateBenefitRequest) GetAmountType() uint {
+	if b.AmountType == 0 {
+		return BenefitAmountFixed
+	}
+	return b.AmountType
+}
+
+func (b *CreateBenefitRequest) validate() error {
+	if b.MinAmount != nil && b.MaxAmount != nil && *b.MinAmount > *b.MaxAmount {
+		return fmt.Errorf("benefit %s: the min amount can not be larger than the max amount", b.Name)
+	}
+	// the amount of a tiered benefit comes from its tiers, the other formulas pay the amount or a multiple of it
+	if b.GetAmountType() != BenefitAmountTiered && b.Amount <= 0 {
+		return fmt.Errorf("benefit %s: the amount must be positive", b.Name)
+	}
+	if b.GetAmountType() == BenefitAmountTiered {
+		if b.TierBasis == 0 {
+			return fmt.Errorf("benefit %s: a tiered benefit must specify the tier basis", b.Name)
+		}
+		if len(b.Tiers) == 0 {
+			return fmt.Errorf("benefit %s: a tiered benefit must have at least one tier", b.Name)
+		}
+		unlimited := 0
+		for _, tier := range b.Tiers {
+			if tier.UpTo == nil {
+				unlimited++
+			}
+		}
+		if unlimited != 1 {
+			return fmt.Errorf("benefit %s: exactly one tier must be without up to, so that every basis has a tier", b.Name)
+		}
+	}
+	if b.MemberExpression != "" && b.GetAmountType() != BenefitAmountPerMember {
+		return fmt.Errorf("benefit %s: only a per household member benefit can have a member expression", b.Name)
+	}
+	return nil
+}
+
+func (b *CreateBenefitRequest) ConvertToModel(benefitID string, schemeID string) Benefits {
+	benefit := Benefits{
+		ID:               benefitID,
+		Name:             b.Name,
+		Amount:           b.Amount,
+		Currency:         b.GetCurrency(),
+		AmountType:       b.GetAmountType(),
+		MemberExpression: b.MemberExpression,
+		TierBasis:        b.TierBasis,
+		MinAmount:        b.MinAmount,
+		MaxAmount:        b.MaxAmount,
+		Frequency:        b.GetFrequency(),
+		Instalments:      b.Instalments,
+		ScheduleStart:    b.ScheduleStart.ToTimePtr(),
+		ScheduleEnd:      b.ScheduleEnd.ToTimePtr(),
+		StopOnIneligible: b.StopOnIneligible,
+		StopExpression:   b.StopExpression,
+		SchemeID:         schemeID,
+	}
+	benefit.SetTiers(b.Tiers)
+	return benefit
+}
+
+// the columns of the benefit a scheme update writes
+func (b *Benefits) UpdateColumns() map[string]interface{} {
+	return map[string]interface{}{
+		"name":               b.Name,
+		"amount":             b.Amount,
+		"currency":           b.Currency,
+		"amount_type":        b.AmountType,
+		"member_expression":  b.MemberExpression,
+		"tier_basis":         b.TierBasis,
+		"tiers":              b.Tiers,
+		"min_amount":         b.MinAmount,
+		"max_amount":         b.MaxAmount,
+		"frequency":          b.Frequency,
+		"instalments":        b.Instalments,
+		"schedule_start":     b.ScheduleStart,
+		"schedule_end":       b.ScheduleEnd,
+		"stop_on_ineligible": b.StopOnIneligible,
+		"stop_expression":    b.StopExpression,
+	}
+}
+
+func (b *Benefits) SetTiers(tiers []BenefitTier) {
+	if len(tiers) == 0 {
+		b.Tiers = nil
+		return
+	}
+	// tiers only hold numbers, marshaling can not fail
+	encoded, _ := json.Marshal(tiers)
+	value := string(encoded)
+	b.Tiers = &value
+}
+
+func (b *Benefits) GetTiers() ([]BenefitTier, error) {
+	tiers := []BenefitTier{}
+	if b.Tiers == nil {
+		return tiers, nil
+	}
+	if err := json.Unmarshal([]byte(*b.Tiers), &tiers); err != nil {
+		return []BenefitTier{}, fmt.Errorf("benefit %s: invalid tiers: %w", b.ID, err)
+	}
+	return tiers, nil
+}
+
+func (b *Benefits) ConvertToResponse() BenefitsResponse {
+	// invalid tiers fail the entitlement computations, the response shows the benefit without them
+	tiers, _ := b.GetTiers()
+	return BenefitsResponse{
+		ID:               b.ID,
+		Name:             b.Name,
+		Amount:           b.Amount,
+		Currency:         b.GetCurrency(),
+		AmountType:       b.GetAmountType(),
+		MemberExpression: b.MemberExpression,
+		TierBasis:        b.TierBasis,
+		Tiers:            tiers,
+		MinAmount:        b.MinAmount,
+		MaxAmount:        b.MaxAmount,
+		Frequency:        b.GetFrequency(),
+		Instalments:      b.Instalments,
+		ScheduleStart:    utils.NewDatePtr(b.ScheduleStart),
+		ScheduleEnd:      utils.NewDatePtr(b.ScheduleEnd),
+		StopOnIneligible: b.StopOnIneligible,
+		StopExpression:   b.StopExpression,
+	}
+}
+
+func (b *Benefits) toRequest() (CreateBenefitRequest, error) {
+	tiers, err := b.GetTiers()
+	if err != nil {
+		return CreateBenefitRequest{}, err
+	}
+	return CreateBenefitRequest{
+		ID:               b.ID,
+		Name:             b.Name,
+		Amount:           b.Amount,
+		Currency:         b.GetCurrency(),
+		AmountType:       b.GetAmountType(),
+		MemberExpression: b.MemberExpression,
+		TierBasis:        b.TierBasis,
+		Tiers:            tiers,
+		MinAmount:        b.MinAmount,
+		MaxAmount:        b.MaxAmount,
+		Frequency:        b.GetFrequency(),
+		Instalments:      b.Instalments,
+		ScheduleStart:    utils.NewDatePtr(b.ScheduleStart),
+		ScheduleEnd:      utils.NewDatePtr(b.ScheduleEnd),
+		StopOnIneligible: b.StopOnIneligible,
+		StopExpression:   b.StopExpression,
+	}, nil
+}
@@ -0,0 +1,203 @@
+package models
+
+import (
+	"FASMS/utils"
+	"strings"
+	"testing"
+	"time"
+)
+
+func upTo(amount utils.Money) *utils.Money {
+	return &amount
+}
+
+func tieredBenefit(basis uint, tiers ...BenefitTier) Benefits {
+	benefit := Benefits{ID: "tiered", AmountType: BenefitAmountTiered, TierBasis: basis}
+	benefit.SetTiers(tiers)
+	return benefit
+}
+
+func TestComputeAmount(t *testing.T) {
+	pinNow(t, eligibilityNow)
+	referenceDate := utils.StartOfDay(eligibilityNow)
+	// amounts are in cents, the applicant earns 1000.00 and the spouse 2000.00, the household earns 3000.00
+	earningSpouse := spouse("spouse")
+	earningSpouse.MonthlyIncome = 2000_00
+	household := testApplicant(earningSpouse, child("young", date(2018, time.May, 1)), child("older", date(2010, time.May, 1)))
+	household.MonthlyIncome = 1000_00
+	couple := testApplicant(earningSpouse)
+	couple.MonthlyIncome = 1000_00
+	childless := testApplicant()
+
+	// given out of order, the tier without up_to first
+	incomeTiers := []BenefitTier{
+		{Amount: 50_00},
+		{UpTo: upTo(2000_00), Amount: 150_00},
+		{UpTo: upTo(1000_00), Amount: 300_00},
+	}
+	perMember := func(expression string) Benefits {
+		return Benefits{ID: "per-member", Amount: 100_00, AmountType: BenefitAmountPerMember, MemberExpression: expression}
+	}
+	withLimits := func(benefit Benefits, min *utils.Money, max *utils.Money) Benefits {
+		benefit.MinAmount = min
+		benefit.MaxAmount = max
+		return benefit
+	}
+
+	tests := []struct {
+		name      string
+		benefit   Benefits
+		applicant Applicants
+		want      utils.Money
+		wantUnits int64
+		wantErr   string
+	}{
+		{name: "fixed", benefit: Benefits{ID: "fixed", Amount: 250_00}, applicant: household, want: 250_00, wantUnits: 1},
+		{name: "no amount type is fixed", benefit: Benefits{ID: "fixed", Amount: 250_00, AmountType: 0}, applicant: household, want: 250_00, wantUnits: 1},
+		{name: "per household member", benefit: perMember(""), applicant: household, want: 300_00, wantUnits: 3},
+		{name: "per household member satisfying the expression", benefit: perMember("relation == 1 && age < 12"), applicant: household, want: 100_00, wantUnits: 1},
+		{name: "invalid member expression", benefit: perMember("relation =="), applicant: household, wantErr: "invalid member expression"},
+		{name: "per household size", benefit: Benefits{ID: "size", Amount: 100_00, AmountType: BenefitAmountPerHouseholdSize}, applicant: household, want: 400_00, wantUnits: 4},
+		{name: "per household size of the applicant alone", benefit: Benefits{ID: "size", Amount: 100_00, AmountType: BenefitAmountPerHouseholdSize}, applicant: childless, want: 100_00, wantUnits: 1},
+		{name: "min amount floors the amount", benefit: withLimits(perMember(""), upTo(50_00), nil), applicant: childless, want: 50_00, wantUnits: 0},
+		{name: "max amount caps the amount", benefit: withLimits(perMember(""), nil, upTo(250_00)), applicant: household, want: 250_00, wantUnits: 3},
+		{name: "amount between the limits", benefit: withLimits(perMember(""), upTo(50_00), upTo(500_00)), applicant: household, want: 300_00, wantUnits: 3},
+		{name: "per capita income on the up_to of a tier", benefit: tieredBenefit(TierBasisPerCapitaIncome, incomeTiers...), applicant: testApplicant(earningSpouse), want: 300_00, wantUnits: 1},
+		{name: "per capita income between two up_to", benefit: tieredBenefit(TierBasisPerCapitaIncome, incomeTiers...), applicant: couple, want: 150_00, wantUnits: 1},
+		{name: "household income above every up_to takes the tier without up_to", benefit: tieredBenefit(TierBasisHouseholdIncome, incomeTiers...), applicant: household, want: 50_00, wantUnits: 1},
+		{name: "lowest up_to applies first", benefit: tieredBenefit(TierBasisHouseholdIncome, incomeTiers...), applicant: childless, want: 300_00, wantUnits: 1},
+		{name: "applicant age", benefit: tieredBenefit(TierBasisApplicantAge, BenefitTier{UpTo: upTo(64_00), Amount: 100_00}, BenefitTier{UpTo: upTo(17_00), Amount: 500_00}, BenefitTier{Amount: 800_00}), applicant: household, want: 100_00, wantUnits: 1},
+		{name: "basis above every tier", benefit: tieredBenefit(TierBasisHouseholdIncome, incomeTiers[1:]...), applicant: household, wantErr: "no tier applies"},
+		{name: "unknown tier basis", benefit: tieredBenefit(0, incomeTiers...), applicant: household, wantErr: "unknown tier basis"},
+		{name: "invalid tiers", benefit: Benefits{ID: "tiered", AmountType: BenefitAmountTiered, TierBasis: TierBasisHouseholdIncome, Tiers: func() *string { tiers := "{"; return &tiers }()}, applicant: household, wantErr: "invalid tiers"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.benefit.ComputeAmount(tt.applicant, EligibilityTrace{}, referenceDate)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("ComputeAmount error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ComputeAmount error = %v", err)
+			}
+			if got.Amount != tt.want || got.Units != tt.wantUnits {
+				t.Fatalf("ComputeAmount = %s for %d units, want %s for %d units", got.Amount, got.Units, tt.want, tt.wantUnits)
+			}
+		})
+	}
+}
+
+func TestSortedTiers(t *testing.T) {
+	tiers := []BenefitTier{
+		{Amount: 1},
+		{UpTo: upTo(2000_00), Amount: 2},
+		{UpTo: upTo(500_00), Amount: 3},
+		{UpTo: upTo(1000_00), Amount: 4},
+	}
+	sorted := sortedTiers(tiers)
+	want := []utils.Money{3, 4, 2, 1}
+	for i, tier := range sorted {
+		if tier.Amount != want[i] {
+			t.Fatalf("sortedTiers = %+v, want the amounts %v", sorted, want)
+		}
+	}
+	if tiers[0].UpTo != nil {
+		t.Fatalf("sortedTiers changed the tiers it was given")
+	}
+}
+
+func TestMatchedHouseholdIDs(t *testing.T) {
+	trace := EligibilityTrace{CriteriaGroups: []CriteriaGroupTrace{
+		{CriteriaGroupID: "applicant", Satisfied: true, Criterias: []CriteriaTrace{{CriteriaID: "unemployed", Satisfied: true}}},
+		{CriteriaGroupID: "children", IsHouseHold: true, Satisfied: true, Criterias: []CriteriaTrace{
+			{CriteriaID: "child", HouseholdID: "first", Satisfied: true},
+			{CriteriaID: "child", HouseholdID: "second", Satisfied: false},
+		}},
+		// a household composition criteria counts the members it matched, a member matched twice counts once
+		{CriteriaGroupID: "count", IsHouseHold: true, Satisfied: true, Criterias: []CriteriaTrace{
+			{CriteriaID: "two-children", MatchedHouseholdIDs: []string{"first", "third"}, Satisfied: true},
+		}},
+		{CriteriaGroupID: "spouse", IsHouseHold: true, Satisfied: false, Criterias: []CriteriaTrace{
+			{CriteriaID: "spouse", HouseholdID: "spouse", Satisfied: true},
+		}},
+	}}
+
+	got := trace.MatchedHouseholdIDs()
+	want := []string{"first", "third"}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Fatalf("MatchedHouseholdIDs = %v, want %v", got, want)
+	}
+
+	benefit := Benefits{ID: "per-matched", Amount: 100_00, AmountType: BenefitAmountPerMatchedMember}
+	entitlement, err := benefit.ComputeAmount(testApplicant(), trace, eligibilityNow)
+	if err != nil {
+		t.Fatalf("ComputeAmount error = %v", err)
+	}
+	if entitlement.Units != 2 || entitlement.Amount != 200_00 {
+		t.Fatalf("ComputeAmount = %s for %d units, want 200.00 for 2 units", entitlement.Amount, entitlement.Units)
+	}
+}
+
+func TestComputeEntitlement(t *testing.T) {
+	pinNow(t, eligibilityNow)
+	// every household member is matched by one of the satisfied household groups, the older child by none
+	applicant := testApplicant(spouse("spouse"), child("young", date(2018, time.May, 1)), child("younger", date(2020, time.May, 1)), child("older", date(2010, time.May, 1)))
+	scheme := Schemes{
+		ID: "scheme",
+		CriteriaGroups: []CriteriaGroup{
+			group("applicant", unemployedCriteria()),
+			group("children", childCriteria("child")),
+			group("spouse", spouseCriteria("spouse")),
+		},
+		Benefits: []Benefits{
+			{ID: "fixed", Name: "fixed", Amount: 100_00, Currency: "SGD"},
+			{ID: "per-matched", Name: "per matched member", Amount: 50_00, AmountType: BenefitAmountPerMatchedMember, Currency: "SGD"},
+		},
+	}
+	ctx := NewEligibilityContext()
+	eligibility := ExplainEligibility(applicant, scheme, ctx)
+	if !eligibility.Eligible {
+		t.Fatalf("ExplainEligibility not eligible, want eligible")
+	}
+
+	entitlement, err := ComputeEntitlement(applicant, scheme, eligibility, ctx)
+	if err != nil {
+		t.Fatalf("ComputeEntitlement error = %v", err)
+	}
+	if entitlement.Total != 250_00 || entitlement.Currency != "SGD" || len(entitlement.Benefits) != 2 {
+		t.Fatalf("ComputeEntitlement = %+v, want a total of 250.00 SGD over 2 benefits", entitlement)
+	}
+	if perMatched := entitlement.Benefits[1]; perMatched.Units != 3 || perMatched.Amount != 150_00 {
+		t.Fatalf("per matched member benefit = %+v, want 150.00 for 3 members", perMatched)
+	}
+
+	// a benefit that can not be computed fails the whole entitlement instead of paying 0
+	scheme.Benefits = append(scheme.Benefits, Benefits{ID: "broken", Amount: 10_00, AmountType: BenefitAmountPerMember, MemberExpression: "relation =="})
+	if _, err := ComputeEntitlement(applicant, scheme, eligibility, ctx); err == nil {
+		t.Fatalf("ComputeEntitlement with an invalid member expression, want error")
+	}
+}
+
+func TestValidateBenefitTiers(t *testing.T) {
+	tests := []struct {
+		name    string
+		tiers   []BenefitTier
+		wantErr bool
+	}{
+		{name: "one tier without up_to", tiers: []BenefitTier{{UpTo: upTo(1000_00), Amount: 300_00}, {Amount: 0}}},
+		{name: "no tier without up_to leaves a gap", tiers: []BenefitTier{{UpTo: upTo(1000_00), Amount: 300_00}}, wantErr: true},
+		{name: "two tiers without up_to", tiers: []BenefitTier{{Amount: 300_00}, {Amount: 0}}, wantErr: true},
+		{name: "no tiers", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			benefit := CreateBenefitRequest{Name: "tiered", AmountType: BenefitAmountTiered, TierBasis: TierBasisHouseholdIncome, Tiers: tt.tiers}
+			if err := benefit.validate(); (err != nil) != tt.wantErr {
+				t.Fatalf("validate error = %v, want error %t", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,284 @@
+package models
+
+import (
+	"FASMS/utils"
+	"fmt"
+	"time"
+)
+
+const (
+	BenefitFrequencyOneOff    uint = 1
+	BenefitFrequencyMonthly   uint = 2
+	BenefitFrequencyQuarterly uint = 3
+)
+
+const (
+	BenefitScheduleStatusActive    uint = 1
+	BenefitScheduleStatusCompleted uint = 2
+	BenefitScheduleStatusStopped   uint = 3
+)
+
+// the instalments of one benefit of an approved application. the amount is the entitlement computed at approval,
+// the scheduler creates a disbursement for each instalment when it is due
+type BenefitSchedules struct {
+	ID               string       `json:"id" gorm:"primaryKey"`
+	ApplicationID    string       `json:"application_id" gorm:"index;not null"`
+	Application      Applications `json:"-" gorm:"foreignKey:ApplicationID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE"`
+	BenefitID        string       `json:"benefit_id"`
+	BenefitName      string       `json:"benefit_name"`
+	Amount           utils.Money  `json:"amount" gorm:"type:numeric(14,2);not null;comment:'the amount of each instalment'"`
+	Currency         string       `json:"currency" gorm:"size:3;not null"`
+	Frequency        uint         `json:"frequency" gorm:"not null;comment:'1: one-off, 2: monthly, 3: quarterly'"`
+	Instalments      uint         `json:"instalments" gorm:"comment:'the planned instalments, 0: until the stop condition'"`
+	StartDate        time.Time    `json:"start_date"`
+	EndDate          *time.Time   `json:"end_date" gorm:"comment:'null: no end date'"`
+	StopOnIneligible bool         `json:"stop_on_ineligible"`
+	StopExpression   string       `json:"stop_expression"`
+	NextInstalment   uint         `json:"next_instalment"`
+	NextDueDate      *time.Time   `json:"next_due_date" gorm:"index;comment:'null once the schedule is completed or stopped'"`
+	Status           uint         `json:"status" gorm:"index;not null;comment:'1: active, 2: completed, 3: stopped'"`
+	StopReason       string       `json:"stop_reason"`
+	CommonTime
+}
+
+type GetBenefitSchedulesRequest struct {
+	ApplicationID string `form:"application"`
+	Status        uint   `form:"status" binding:"omitempty,oneof=1 2 3"`
+	PaginationQuery
+}
+
+type BenefitSchedulesResponse struct {
+	ID               string      `json:"id"`
+	ApplicationID    string      `json:"application_id"`
+	BenefitID        string      `json:"benefit_id"`
+	BenefitName      string      `json:"benefit_name"`
+	Amount           utils.Money `json:"amount"`
+	Currency         string      `json:"currency"`
+	Frequency        uint        `json:"frequency"`
+	Instalments      uint        `json:"instalments"`
+	StartDate        utils.Date  `json:"start_date"`
+	EndDate          *utils.Date `json:"end_date"`
+	StopOnIneligible bool        `json:"stop_on_ineligible"`
+	StopExpression   string      `json:"stop_expression"`
+	NextInstalment   uint        `json:"next_instalment"`
+	NextDueDate      *utils.Date `json:"next_due_date"`
+	Status           uint        `json:"status"`
+	StopReason       string      `json:"stop_reason"`
+}
+
+// the schedules of an application approved at approvedAt, one per benefit with an amount to pay.
+// a schedule starts at the benefit's schedule start, or at the approval if that is later,
+// and ends at the benefit's schedule end or at the end of the scheme's effective period, whichever is first
+func NewBenefitSchedules(application Applications, scheme Schemes, entitlement Entitlement, approvedAt time.Time) []BenefitSchedules {
+	benefits := make(map[string]Benefits)
+	for _, benefit := range scheme.Benefits {
+		benefits[benefit.ID] = benefit
+	}
+
+	schedules := []BenefitSchedules{}
+	for _, benefitEntitlement := range entitlement.Benefits {
+		benefit, exists := benefits[benefitEntitlement.BenefitID]
+		if !exists || benefitEntitlement.Amount <= 0 {
+			continue
+		}
+		startDate := utils.StartOfDay(approvedAt)
+		if benefit.ScheduleStart != nil && benefit.ScheduleStart.After(startDate) {
+			startDate = *benefit.ScheduleStart
+		}
+		endDate := benefit.ScheduleEnd
+		if scheme.EffectiveTo != nil && (endDate == nil || scheme.EffectiveTo.Before(*endDate)) {
+			endDate = scheme.EffectiveTo
+		}
+		schedule := BenefitSchedules{
+			ID:               utils.GenerateUUID(),
+			ApplicationID:    application.ID,
+			BenefitID:        benefit.ID,
+			BenefitName:      benefit.Name,
+			Amount:           benefitEntitlement.Amount,
+			Currency:         entitlement.Currency,
+			Frequency:        benefit.GetFrequency(),
+			Instalments:      benefit.Instalments,
+			StartDate:        startDate,
+			EndDate:          endDate,
+			StopOnIneligible: benefit.StopOnIneligible,
+			StopExpression:   benefit.StopExpression,
+			NextInstalment:   1,
+			Status:           BenefitScheduleStatusActive,
+		}
+		if schedule.Frequency == BenefitFrequencyOneOff {
+			schedule.Instalments = 1
+		}
+		schedule.advanceTo(1)
+		// a schedule ending at a date plans the instalments until then
+		if schedule.Instalments == 0 && schedule.EndDate != nil {
+			schedule.Instalments = schedule.plannedInstalments()
+		}
+		schedules = append(schedules, schedule)
+	}
+	return schedules
+}
+
+// the schedules replacing the stopped schedules of an application approved again, without the instalments the stopped
+// ones already materialised, by benefit. a benefit with a number of instalments only plans the ones left, a one-off
+// benefit already materialised is not planned again. the other schedules start again at the approval, the instalments
+// of the stopped ones were due before it
+func ResumeBenefitSchedules(schedules []BenefitSchedules, scheme Schemes, materialised map[string]uint) []BenefitSchedules {
+	fixedInstalments := make(map[string]bool)
+	for _, benefit := range scheme.Benefits {
+		fixedInstalments[benefit.ID] = benefit.GetFrequency() == BenefitFrequencyOneOff || benefit.Instalments != 0
+	}
+
+	resumed := []BenefitSchedules{}
+	for _, schedule := range schedules {
+		done := materialised[schedule.BenefitID]
+		if fixedInstalments[schedule.BenefitID] && done > 0 {
+			if done >= schedule.Instalments {
+				continue
+			}
+			schedule.Instalments -= done
+		}
+		resumed = append(resumed, schedule)
+	}
+	return resumed
+}
+
+// the due date of the nth instalment, counted from the start date so that the days do not drift
+func (s *BenefitSchedules) DueDate(instalment uint) time.Time {
+	months := 0
+	switch s.Frequency {
+	case BenefitFrequencyMonthly:
+		months = 1
+	case BenefitFrequencyQuarterly:
+		months = 3
+	}
+	return utils.AddMonths(s.StartDate, months*int(instalment-1))
+}
+
+// moves the schedule to the nth instalment, the schedule is completed when there is no such instalment
+func (s *BenefitSchedules) advanceTo(instalment uint) {
+	dueDate := s.DueDate(instalment)
+	if (s.Instalments != 0 && instalment > s.Instalments) ||
+		(s.EndDate != nil && utils.StartOfDay(dueDate).After(*s.EndDate)) ||
+		(s.Frequency == BenefitFrequencyOneOff && instalment > 1) {
+		s.Status = BenefitScheduleStatusCompleted
+		s.NextDueDate = nil
+		return
+	}
+	s.NextInstalment = instalment
+	s.NextDueDate = &dueDate
+}
+
+// the number of instalments due until the end date
+func (s *BenefitSchedules) plannedInstalments() uint {
+	var instalments uint
+	for s.EndDate != nil && !utils.StartOfDay(s.DueDate(instalments+1)).After(*s.EndDate) {
+		instalments++
+	}
+	return instalments
+}
+
+// the instalment that is due, nil when there is none at now
+func (s *BenefitSchedules) DueInstalment(now time.Time) *time.Time {
+	if s.Status != BenefitScheduleStatusActive || s.NextDueDate == nil || s.NextDueDate.After(now) {
+		return nil
+	}
+	return s.NextDueDate
+}
+
+// the disbursement of the due instalment, the schedule moves to the next instalment
+func (s *BenefitSchedules) Materialise(application Applications) Disbursements {
+	instalment := s.NextInstalment
+	dueDate := *s.NextDueDate
+	scheduleID := s.ID
+	disbursement := Disbursements{
+		ID:            utils.GenerateUUID(),
+		ApplicationID: application.ID,
+		ApplicantID:   application.ApplicantID,
+		SchemeID:      application.SchemeID,
+		BenefitID:     s.BenefitID,
+		BenefitName:   s.BenefitName,
+		Amount:        s.Amount,
+		Currency:      s.Currency,
+		Status:        DisbursementStatusPending,
+		ScheduleID:    &scheduleID,
+		Instalment:    instalment,
+		DueDate:       &dueDate,
+	}
+	s.advanceTo(instalment + 1)
+	return disbursement
+}
+
+// whether the schedule stops before the instalment due at dueDate, with the reason
+func (s *BenefitSchedules) CheckStop(applicant Applicants, scheme Schemes, dueDate time.Time) (bool, string) {
+	if s.StopExpression != "" {
+		program, err := CompileCriteriaExpression(s.StopExpression, false)
+		if err != nil {
+			return true, fmt.Sprintf("invalid stop expression, %v", err)
+		}
+		if program.Eval(applicant.expressionEnv(dueDate)) {
+			return true, fmt.Sprintf("stop expression %q is true", s.StopExpression)
+		}
+	}
+	if s.StopOnIneligible && !CheckEligiblity(applicant, scheme, EligibilityContext{Now: dueDate}) {
+		return true, "the applicant is no longer eligible"
+	}
+	return false, ""
+}
+
+func (s *BenefitSchedules) Stop(reason string) {
+	s.Status = BenefitScheduleStatusStopped
+	s.StopReason = reason
+	s.NextDueDate = nil
+}
+
+func (s *BenefitSchedules) ConvertToResponse() BenefitSchedulesResponse {
+	return BenefitSchedulesResponse{
+		ID:               s.ID,
+		ApplicationID:    s.ApplicationID,
+		BenefitID:        s.BenefitID,
+		BenefitName:      s.BenefitName,
+		Amount:           s.Amount,
+		Currency:         s.Currency,
+		Frequency:        s.Frequency,
+		Instalments:      s.Instalments,
+		StartDate:        utils.Date(s.StartDate),
+		EndDate:          utils.NewDatePtr(s.EndDate),
+		StopOnIneligible: s.StopOnIneligible,
+		StopExpression:   s.StopExpression,
+		NextInstalment:   s.NextInstalment,
+		NextDueDate:      utils.NewDatePtr(s.NextDueDate),
+		Status:           s.Status,
+		StopReason:       s.StopReason,
+	}
+}
+
+func (b *Benefits) GetFrequency() uint {
+	if b.Frequency == 0 {
+		return BenefitFrequencyOneOff
+	}
+	return b.Frequency
+}
+
+func (b *CreateBenefitRequest) GetFrequency() uint {
+	if b.Frequency == 0 {
+		return BenefitFrequencyOneOff
+	}
+	return b.Frequency
+}
+
+// a recurring benefit has to end, by a number of instalments, an end date, a stop condition or the scheme's effective period
+func (b *CreateBenefitRequest) validateSchedule(schemeEnds bool) error {
+	if b.ScheduleStart != nil && b.ScheduleEnd != nil && b.ScheduleEnd.ToTime().Before(b.ScheduleStart.ToTime()) {
+		return fmt.Errorf("benefit %s: the schedule end can not be before the schedule start", b.Name)
+	}
+	if b.GetFrequency() == BenefitFrequencyOneOff {
+		if b.Instalments > 1 {
+			return fmt.Errorf("benefit %s: a one-off benefit has a single instalment", b.Name)
+		}
+		return nil
+	}
+	if b.Instalments == 0 && b.ScheduleEnd == nil && b.StopExpression == "" && !b.StopOnIneligible && !schemeEnds {
+		return fmt.Errorf("benefit %s: a recurring benefit must have instalments, a schedule end or a stop condition", b.Name)
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestResumeBenefitSchedules(t *testing.T) {
+	scheme := Schemes{Benefits: []Benefits{
+		{ID: "one-off", Frequency: BenefitFrequencyOneOff},
+		{ID: "six-months", Frequency: BenefitFrequencyMonthly, Instalments: 6},
+		{ID: "until-end", Frequency: BenefitFrequencyMonthly},
+	}}
+	end := time.Date(2026, 12, 31, 0, 0, 0, 0, time.UTC)
+	schedules := []BenefitSchedules{
+		{BenefitID: "one-off", Instalments: 1},
+		{BenefitID: "six-months", Instalments: 6},
+		{BenefitID: "until-end", Instalments: 4, EndDate: &end},
+	}
+
+	tests := []struct {
+		name         string
+		materialised map[string]uint
+		want         map[string]uint
+	}{
+		{name: "nothing materialised", materialised: map[string]uint{}, want: map[string]uint{"one-off": 1, "six-months": 6, "until-end": 4}},
+		{name: "one-off materialised", materialised: map[string]uint{"one-off": 1}, want: map[string]uint{"six-months": 6, "until-end": 4}},
+		{name: "some instalments materialised", materialised: map[string]uint{"six-months": 2}, want: map[string]uint{"one-off": 1, "six-months": 4, "until-end": 4}},
+		{name: "every instalment materialised", materialised: map[string]uint{"six-months": 6}, want: map[string]uint{"one-off": 1, "until-end": 4}},
+		{name: "schedule until an end date starts again", materialised: map[string]uint{"until-end": 3}, want: map[string]uint{"one-off": 1, "six-months": 6, "until-end": 4}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := make(map[string]uint)
+			for _, schedule := range ResumeBenefitSchedules(schedules, scheme, tt.materialised) {
+				got[schedule.BenefitID] = schedule.Instalments
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("ResumeBenefitSchedules = %v, want %v", got, tt.want)
+			}
+			for benefitID, instalments := range tt.want {
+				if got[benefitID] != instalments {
+					t.Fatalf("ResumeBenefitSchedules = %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+	if schedules[1].Instalments != 6 {
+		t.Fatalf("ResumeBenefitSchedules changed the schedules it was given")
+	}
+}
@@ -0,0 +1,199 @@
+package models
+
+import (
+	"FASMS/utils"
+	"fmt"
+	"time"
+)
+
+const (
+	DisbursementStatusPending  uint = 1
+	DisbursementStatusPaid     uint = 2
+	DisbursementStatusFailed   uint = 3
+	DisbursementStatusReversed uint = 4
+	// the disbursement will not be paid, its application left the scheme before it was
+	DisbursementStatusCancelled uint = 5
+)
+
+const (
+	PaymentRunStatusOpen    uint = 1
+	PaymentRunStatusSettled uint = 2
+)
+
+// an amount payable to an applicant for one benefit of an approved application
+type Disbursements struct {
+	ID            string       `json:"id" gorm:"primaryKey"`
+	ApplicationID string       `json:"application_id" gorm:"index;not null"`
+	Application   Applications `json:"-" gorm:"foreignKey:ApplicationID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE"`
+	ApplicantID   string       `json:"applicant_id" gorm:"index;not null"`
+	SchemeID      string       `json:"scheme_id" gorm:"index;not null"`
+	BenefitID     string       `json:"benefit_id"`
+	BenefitName   string       `json:"benefit_name"`
+	Amount        utils.Money  `json:"amount" gorm:"type:numeric(14,2);not null"`
+	Currency      string       `json:"currency" gorm:"size:3;not null"`
+	Status        uint         `json:"status" gorm:"index;not null;comment:'1: pending, 2: paid, 3: failed, 4: reversed, 5: cancelled'"`
+	PaymentRunID  *string      `json:"payment_run_id" gorm:"index"`
+	ScheduleID    *string      `json:"schedule_id" gorm:"index"`
+	Instalment    uint         `json:"instalment" gorm:"comment:'the instalment number in the schedule, from 1'"`
+	DueDate       *time.Time   `json:"due_date" gorm:"type:date"`
+	PaidAt        *time.Time   `json:"paid_at"`
+	FailureReason string       `json:"failure_reason"`
+	ReversalNote  string       `json:"reversal_note"`
+	CancelReason  string       `json:"cancel_reason"`
+	CommonTime
+}
+
+// a batch of pending disbursements paid together, in one currency
+type PaymentRuns struct {
+	ID            string          `json:"id" gorm:"primaryKey"`
+	Status        uint            `json:"status" gorm:"not null;comment:'1: open, 2: settled'"`
+	SchemeID      *string         `json:"scheme_id" gorm:"comment:'null: the run covers every scheme'"`
+	Currency      string          `json:"currency" gorm:"size:3;not null"`
+	Total         utils.Money     `json:"total" gorm:"type:numeric(14,2);not null"`
+	Count         int             `json:"count"`
+	CreatedBy     string          `json:"created_by"`
+	SettledAt     *time.Time      `json:"settled_at"`
+	Disbursements []Disbursements `json:"-" gorm:"foreignKey:PaymentRunID;references:ID"`
+	CommonTime
+}
+
+type GetDisbursementsRequest struct {
+	ApplicantID   string `form:"applicant"`
+	SchemeID      string `form:"scheme"`
+	ApplicationID string `form:"application"`
+	PaymentRunID  string `form:"run"`
+	Status        uint   `form:"status" binding:"omitempty,oneof=1 2 3 4 5"`
+	PaginationQuery
+}
+
+type GetPaymentRunsRequest struct {
+	Status uint `form:"status" binding:"omitempty,oneof=1 2"`
+	PaginationQuery
+}
+
+// batches every pending disbursement of approved applications not in a run yet, optionally of one scheme
+type CreatePaymentRunRequest struct {
+	SchemeID string `json:"scheme_id"`
+	Currency string `json:"currency" binding:"omitempty,iso4217"`
+}
+
+// settles an open run, the listed disbursements failed and every other one of the run is paid
+type SettlePaymentRunRequest struct {
+	Failed []FailedDisbursementRequest `json:"failed" binding:"dive"`
+}
+
+type FailedDisbursementRequest struct {
+	DisbursementID string `json:"disbursement_id" binding:"required"`
+	Reason         string `json:"reason" binding:"required"`
+}
+
+type ReverseDisbursementRequest struct {
+	Note string `json:"note" binding:"required"`
+}
+
+type DisbursementsResponse struct {
+	ID            string      `json:"id"`
+	ApplicationID string      `json:"application_id"`
+	ApplicantID   string      `json:"applicant_id"`
+	SchemeID      string      `json:"scheme_id"`
+	BenefitID     string      `json:"benefit_id"`
+	BenefitName   string      `json:"benefit_name"`
+	Amount        utils.Money `json:"amount"`
+	Currency      string      `json:"currency"`
+	Status        uint        `json:"status"`
+	PaymentRunID  *string     `json:"payment_run_id"`
+	ScheduleID    *string     `json:"schedule_id"`
+	Instalment    uint        `json:"instalment"`
+	DueDate       *utils.Date `json:"due_date"`
+	PaidAt        *time.Time  `json:"paid_at"`
+	FailureReason string      `json:"failure_reason"`
+	ReversalNote  string      `json:"reversal_note"`
+	CancelReason  string      `json:"cancel_reason"`
+	CreatedAt     time.Time   `json:"created_at"`
+}
+
+type PaymentRunsResponse struct {
+	ID            string                  `json:"id"`
+	Status        uint                    `json:"status"`
+	SchemeID      *string                 `json:"scheme_id"`
+	Currency      string                  `json:"currency"`
+	Total         utils.Money             `json:"total"`
+	Count         int                     `json:"count"`
+	CreatedBy     string                  `json:"created_by"`
+	CreatedAt     time.Time               `json:"created_at"`
+	SettledAt     *time.Time              `json:"settled_at"`
+	Disbursements []DisbursementsResponse `json:"disbursements,omitempty"`
+}
+
+var disbursementStatusNames = map[uint]string{
+	DisbursementStatusPending:   "pending",
+	DisbursementStatusPaid:      "paid",
+	DisbursementStatusFailed:    "failed",
+	DisbursementStatusReversed:  "reversed",
+	DisbursementStatusCancelled: "cancelled",
+}
+
+func DisbursementStatusName(status uint) string {
+	if name, ok := disbursementStatusNames[status]; ok {
+		return name
+	}
+	return fmt.Sprintf("unknown (%d)", status)
+}
+
+func (r *CreatePaymentRunRequest) ConvertToModel(createdBy string) PaymentRuns {
+	run := PaymentRuns{
+		ID:        utils.GenerateUUID(),
+		Status:    PaymentRunStatusOpen,
+		Currency:  r.Currency,
+		CreatedBy: createdBy,
+	}
+	if run.Currency == "" {
+		run.Currency = utils.DefaultCurrency
+	}
+	if r.SchemeID != "" {
+		schemeID := r.SchemeID
+		run.SchemeID = &schemeID
+	}
+	return run
+}
+
+func (d *Disbursements) ConvertToResponse() DisbursementsResponse {
+	return DisbursementsResponse{
+		ID:            d.ID,
+		ApplicationID: d.ApplicationID,
+		ApplicantID:   d.ApplicantID,
+		SchemeID:      d.SchemeID,
+		BenefitID:     d.BenefitID,
+		BenefitName:   d.BenefitName,
+		Amount:        d.Amount,
+		Currency:      d.Currency,
+		Status:        d.Status,
+		PaymentRunID:  d.PaymentRunID,
+		ScheduleID:    d.ScheduleID,
+		Instalment:    d.Instalment,
+		DueDate:       utils.NewDatePtr(d.DueDate),
+		PaidAt:        d.PaidAt,
+		FailureReason: d.FailureReason,
+		ReversalNote:  d.ReversalNote,
+		CancelReason:  d.CancelReason,
+		CreatedAt:     d.CreatedAt,
+	}
+}
+
+func (r *PaymentRuns) ConvertToResponse() PaymentRunsResponse {
+	response := PaymentRunsResponse{
+		ID:        r.ID,
+		Status:    r.Status,
+		SchemeID:  r.SchemeID,
+		Currency:  r.Currency,
+		Total:     r.Total,
+		Count:     r.Count,
+		CreatedBy: r.CreatedBy,
+		CreatedAt: r.CreatedAt,
+		SettledAt: r.SettledAt,
+	}
+	for _, disbursement := range r.Disbursements {
+		response.Disbursements = append(response.Disbursements, disbursement.ConvertToResponse())
+	}
+	return response
+}
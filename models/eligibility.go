@@ -0,0 +1,670 @@
+package models
+
+import (
+	"FASMS/expression"
+	"FASMS/utils"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// An applicant must satisfy the criteria tree of the scheme, AND/OR/NOT nodes over criteria groups,
+// without a tree the applicant must satisfy all criteria groups.
+// A group is considered satisfied if any one of its criteria is met.
+// Criteria can apply to:
+// 	The applicant (e.g., age, employment, sex, marital status)
+// 	Household members (with unique household IDs and matching logic)
+
+// criteria types, a household member criteria is matched by one distinct household member,
+// the others are evaluated on the household as a whole, applicant included for size and income
+const (
+	CriteriaTypeMember          uint = 1
+	CriteriaTypeMemberCount     uint = 2
+	CriteriaTypeHouseholdSize   uint = 3
+	CriteriaTypeRelationPresent uint = 4
+	CriteriaTypeRelationAbsent  uint = 5
+	CriteriaTypeHouseholdIncome uint = 6
+	CriteriaTypePerCapitaIncome uint = 7
+)
+
+// operators of the criteria tree nodes
+const (
+	CriteriaNodeAnd   uint = 1
+	CriteriaNodeOr    uint = 2
+	CriteriaNodeNot   uint = 3
+	CriteriaNodeGroup uint = 4
+)
+
+// the date ages are evaluated at, see Schemes.GetReferenceDate
+const (
+	AgeReferenceEvaluationDate  uint = 1
+	AgeReferenceFixedDate       uint = 2
+	AgeReferenceStartOfYear     uint = 3
+	AgeReferenceApplicationDate uint = 4
+)
+
+// EligibilityContext holds the time an eligibility check is made at,
+// ApplicationDate is only set when the check is made for an application
+type EligibilityContext struct {
+	Now             time.Time
+	ApplicationDate *time.Time
+}
+
+// context of a check made now, outside of any application
+func NewEligibilityContext() EligibilityContext {
+	return EligibilityContext{Now: utils.Now()}
+}
+
+// context of a check made for an application submitted at applicationDate
+func NewApplicationEligibilityContext(applicationDate time.Time) EligibilityContext {
+	return EligibilityContext{Now: utils.Now(), ApplicationDate: &applicationDate}
+}
+
+type EligibilityTrace struct {
+	ApplicantID          string                     `json:"applicant_id"`
+	SchemeID             string                     `json:"scheme_id"`
+	Eligible             bool                       `json:"eligible"`
+	ReferenceDate        utils.Date                 `json:"reference_date"`
+	Rule                 *CriteriaNodeTrace         `json:"rule"`
+	CriteriaGroups       []CriteriaGroupTrace       `json:"criteria_groups"`
+	HouseholdAssignments []HouseholdAssignmentTrace `json:"household_assignments"`
+	Relations            []SchemeRelationTrace      `json:"relations"`
+}
+
+type CriteriaNodeTrace struct {
+	CriteriaNodeID  string              `json:"criteria_node_id,omitempty"`
+	Operator        uint                `json:"operator"`
+	CriteriaGroupID *string             `json:"criteria_group_id,omitempty"`
+	Satisfied       bool                `json:"satisfied"`
+	Children        []CriteriaNodeTrace `json:"children"`
+}
+
+type CriteriaGroupTrace struct {
+	CriteriaGroupID string          `json:"criteria_group_id"`
+	IsHouseHold     bool            `json:"is_household"`
+	Satisfied       bool            `json:"satisfied"`
+	Criterias       []CriteriaTrace `json:"criterias"`
+}
+
+// one row per criteria tried, for household groups one row per (criteria, household member) pair
+type CriteriaTrace struct {
+	CriteriaID          string          `json:"criteria_id"`
+	HouseholdID         string          `json:"household_id,omitempty"`
+	MatchedHouseholdIDs []string        `json:"matched_household_ids,omitempty"`
+	Satisfied           bool            `json:"satisfied"`
+	Mismatches          []FieldMismatch `json:"mismatches"`
+}
+
+type FieldMismatch struct {
+	Field    string `json:"field"`
+	Expected string `json:"expected"`
+	Actual   string `json:"actual"`
+}
+
+// records every step of the backtracking in IsHouseholdEligible,
+// action is one of "assigned", "backtracked", "exhausted" or "succeeded"
+type HouseholdAssignmentTrace struct {
+	Step            int    `json:"step"`
+	CriteriaGroupID string `json:"criteria_group_id"`
+	HouseholdID     string `json:"household_id,omitempty"`
+	CriteriaID      string `json:"criteria_id,omitempty"`
+	Action          string `json:"action"`
+}
+
+type GetSchemeEligibilityRequest struct {
+	ApplicantID string `form:"applicant"  binding:"required"`
+}
+
+// eligibilityEvaluator runs the eligibility rules, and records the trace when explain is set.
+// the scheme relations are checked against the applicant's applications as of at
+type eligibilityEvaluator struct {
+	explain       bool
+	referenceDate time.Time
+	at            time.Time
+	trace         EligibilityTrace
+}
+
+func newEligibilityEvaluator(scheme Schemes, ctx EligibilityContext, explain bool) eligibilityEvaluator {
+	evaluator := eligibilityEvaluator{explain: explain, referenceDate: scheme.GetReferenceDate(ctx), at: ctx.Now}
+	if ctx.ApplicationDate != nil {
+		evaluator.at = *ctx.ApplicationDate
+	}
+	return evaluator
+}
+
+// biz logic: applicant must satisify the criteria tree of the scheme, by default all the criteria groups.
+// a criteria group is considered as satisified if any of the criteria in the groupo is satisified
+func CheckEligiblity(applicant Applicants, scheme Schemes, ctx EligibilityContext) bool {
+	evaluator := newEligibilityEvaluator(scheme, ctx, false)
+	return evaluator.evaluate(applicant, scheme)
+}
+
+// same as CheckEligiblity, but returns the full trace of why the applicant passes or fails the scheme
+func ExplainEligibility(applicant Applicants, scheme Schemes, ctx EligibilityContext) EligibilityTrace {
+	evaluator := newEligibilityEvaluator(scheme, ctx, true)
+	evaluator.trace = EligibilityTrace{
+		ApplicantID:          applicant.ID,
+		SchemeID:             scheme.ID,
+		ReferenceDate:        utils.Date(evaluator.referenceDate),
+		CriteriaGroups:       []CriteriaGroupTrace{},
+		HouseholdAssignments: []HouseholdAssignmentTrace{},
+		Relations:            []SchemeRelationTrace{},
+	}
+	evaluator.trace.Eligible = evaluator.evaluate(applicant, scheme)
+	return evaluator.trace
+}
+
+func (s *Schemes) GetAgeReferenceType() uint {
+	if s.AgeReferenceType == 0 {
+		return AgeReferenceEvaluationDate
+	}
+	return s.AgeReferenceType
+}
+
+// the date ages are evaluated at for this scheme,
+// an application date scheme checked outside of an application uses the evaluation date
+func (s *Schemes) GetReferenceDate(ctx EligibilityContext) time.Time {
+	switch s.GetAgeReferenceType() {
+	case AgeReferenceFixedDate:
+		if s.AgeReferenceDate != nil {
+			return *s.AgeReferenceDate
+		}
+	case AgeReferenceStartOfYear:
+		return utils.StartOfYear(ctx.Now)
+	case AgeReferenceApplicationDate:
+		if ctx.ApplicationDate != nil {
+			return utils.StartOfDay(*ctx.ApplicationDate)
+		}
+	}
+	return utils.StartOfDay(ctx.Now)
+}
+
+// the applicant must satisfy both the criteria and the relations of the scheme,
+// the relations are checked even when the criteria fail so that the trace shows every reason
+func (e *eligibilityEvaluator) evaluate(applicant Applicants, scheme Schemes) bool {
+	satisfied := e.evaluateCriteria(applicant, scheme)
+	if !satisfied && !e.explain {
+		return false
+	}
+	return e.evaluateRelations(applicant, scheme) && satisfied
+}
+
+// the relations are checked against the applicant's applications, which have to be loaded with the applicant
+func (e *eligibilityEvaluator) evaluateRelations(applicant Applicants, scheme Schemes) bool {
+	satisfied := true
+	for _, relation := range scheme.Relations {
+		relationTrace := relation.check(applicant.Applications, e.at)
+		if e.explain {
+			e.trace.Relations = append(e.trace.Relations, relationTrace)
+		}
+		if !relationTrace.Satisfied {
+			satisfied = false
+			if !e.explain {
+				return false
+			}
+		}
+	}
+	return satisfied
+}
+
+func (e *eligibilityEvaluator) evaluateCriteria(applicant Applicants, scheme Schemes) bool {
+	if len(scheme.CriteriaGroups) == 0 {
+		return true
+	}
+
+	groups := make(map[string]CriteriaGroup)
+	for _, group := range scheme.CriteriaGroups {
+		groups[group.ID] = group
+	}
+	satisfied, ruleTrace := e.evaluateNode(scheme.GetCriteriaTree(), groups, applicant)
+	if e.explain {
+		e.trace.Rule = &ruleTrace
+	}
+	return satisfied
+}
+
+// evaluates a node of the criteria tree, household member groups directly under the same AND node
+// are matched by distinct household members, a household member group anywhere else only needs any one member
+func (e *eligibilityEvaluator) evaluateNode(node CriteriaNode, groups map[string]CriteriaGroup, applicant Applicants) (bool, CriteriaNodeTrace) {
+	nodeTrace := CriteriaNodeTrace{
+		CriteriaNodeID:  node.ID,
+		Operator:        node.Operator,
+		CriteriaGroupID: node.CriteriaGroupID,
+		Children:        []CriteriaNodeTrace{},
+	}
+
+	switch node.Operator {
+	case CriteriaNodeGroup:
+		group, ok := groups[node.getCriteriaGroupID()]
+		if !ok {
+			return false, nodeTrace
+		}
+		if group.IsHouseHold() {
+			nodeTrace.Satisfied = e.evaluateHouseholdGroups([]CriteriaGroup{group}, applicant)
+		} else {
+			nodeTrace.Satisfied = e.isApplicantEligible(applicant, group)
+		}
+	case CriteriaNodeAnd:
+		nodeTrace.Satisfied = true
+		var householdCriteriaGroups []CriteriaGroup
+		var householdTraceIndexes []int
+		for _, child := range node.Children {
+			if child.Operator == CriteriaNodeGroup {
+				if group, ok := groups[child.getCriteriaGroupID()]; ok && group.IsHouseHold() {
+					householdCriteriaGroups = append(householdCriteriaGroups, group)
+					householdTraceIndexes = append(householdTraceIndexes, len(nodeTrace.Children))
+					nodeTrace.Children = append(nodeTrace.Children, CriteriaNodeTrace{
+						CriteriaNodeID:  child.ID,
+						Operator:        child.Operator,
+						CriteriaGroupID: child.CriteriaGroupID,
+						Children:        []CriteriaNodeTrace{},
+					})
+					continue
+				}
+			}
+			childSatisfied, childTrace := e.evaluateNode(child, groups, applicant)
+			nodeTrace.Children = append(nodeTrace.Children, childTrace)
+			nodeTrace.Satisfied = nodeTrace.Satisfied && childSatisfied
+			if !nodeTrace.Satisfied && !e.explain {
+				return false, nodeTrace
+			}
+		}
+		if len(householdCriteriaGroups) > 0 {
+			householdSatisfied := e.evaluateHouseholdGroups(householdCriteriaGroups, applicant)
+			for _, index := range householdTraceIndexes {
+				nodeTrace.Children[index].Satisfied = householdSatisfied
+			}
+			nodeTrace.Satisfied = nodeTrace.Satisfied && householdSatisfied
+		}
+	case CriteriaNodeOr:
+		for _, child := range node.Children {
+			childSatisfied, childTrace := e.evaluateNode(child, groups, applicant)
+			nodeTrace.Children = append(nodeTrace.Children, childTrace)
+			nodeTrace.Satisfied = nodeTrace.Satisfied || childSatisfied
+			if nodeTrace.Satisfied && !e.explain {
+				return true, nodeTrace
+			}
+		}
+	case CriteriaNodeNot:
+		if len(node.Children) == 1 {
+			childSatisfied, childTrace := e.evaluateNode(node.Children[0], groups, applicant)
+			nodeTrace.Children = append(nodeTrace.Children, childTrace)
+			nodeTrace.Satisfied = !childSatisfied
+		}
+	}
+	return nodeTrace.Satisfied, nodeTrace
+}
+
+// household member groups are all satisfied when each of them is matched by a distinct household member,
+// or by one of its household composition criterias
+func (e *eligibilityEvaluator) evaluateHouseholdGroups(householdCriteriaGroups []CriteriaGroup, applicant Applicants) bool {
+	if e.explain {
+		// every household group is checked on its own, so the trace shows which members could match it
+		for _, group := range householdCriteriaGroups {
+			e.explainHouseholdGroup(group, applicant)
+		}
+	}
+
+	// groups satisfied by a household composition criteria do not need a member of their own,
+	// the remaining groups are matched by distinct household members
+	var houseHoldEligible = true
+	var memberCriteriaGroups []CriteriaGroup
+	for _, group := range householdCriteriaGroups {
+		compositionSatisfied, memberGroup := splitCompositionCriterias(group, applicant, e.referenceDate)
+		if compositionSatisfied {
+			continue
+		}
+		if len(memberGroup.Criterias) == 0 {
+			houseHoldEligible = false
+			continue
+		}
+		memberCriteriaGroups = append(memberCriteriaGroups, memberGroup)
+	}
+	if houseHoldEligible || e.explain {
+		var usedHouseholds = make(map[string]bool)
+		houseHoldEligible = e.isHouseholdEligible(memberCriteriaGroups, applicant.Households, usedHouseholds, 0) && houseHoldEligible
+	}
+	return houseHoldEligible
+}
+
+// the criteria tree of the scheme, schemes without a stored tree require all their criteria groups
+func (s *Schemes) GetCriteriaTree() CriteriaNode {
+	childrenByParent := make(map[string][]CriteriaNode)
+	var root *CriteriaNode
+	for i, node := range s.CriteriaNodes {
+		if node.ParentID == nil {
+			root = &s.CriteriaNodes[i]
+			continue
+		}
+		childrenByParent[*node.ParentID] = append(childrenByParent[*node.ParentID], node)
+	}
+	if root == nil {
+		tree := CriteriaNode{Operator: CriteriaNodeAnd}
+		for _, group := range s.CriteriaGroups {
+			groupID := group.ID
+			tree.Children = append(tree.Children, CriteriaNode{Operator: CriteriaNodeGroup, CriteriaGroupID: &groupID})
+		}
+		return tree
+	}
+	return buildCriteriaTree(*root, childrenByParent)
+}
+
+func buildCriteriaTree(node CriteriaNode, childrenByParent map[string][]CriteriaNode) CriteriaNode {
+	children := childrenByParent[node.ID]
+	sort.Slice(children, func(i, j int) bool { return children[i].Position < children[j].Position })
+	node.Children = nil
+	for _, child := range children {
+		node.Children = append(node.Children, buildCriteriaTree(child, childrenByParent))
+	}
+	return node
+}
+
+func (n *CriteriaNode) getCriteriaGroupID() string {
+	if n.CriteriaGroupID == nil {
+		return ""
+	}
+	return *n.CriteriaGroupID
+}
+
+// a group is a household group when all its criterias are on household members
+func (g *CriteriaGroup) IsHouseHold() bool {
+	for _, criteria := range g.Criterias {
+		if !criteria.IsHouseHold {
+			return false
+		}
+	}
+	return true
+}
+
+// returns whether any household composition criteria of the group holds,
+// and the group with only its household member criterias
+func splitCompositionCriterias(group CriteriaGroup, applicant Applicants, at time.Time) (bool, CriteriaGroup) {
+	memberGroup := group
+	memberGroup.Criterias = nil
+	for _, criteria := range group.Criterias {
+		if criteria.GetCriteriaType() == CriteriaTypeMember {
+			memberGroup.Criterias = append(memberGroup.Criterias, criteria)
+			continue
+		}
+		if mismatches, _ := matchCompositionCriteria(applicant, criteria, at); len(mismatches) == 0 {
+			return true, memberGroup
+		}
+	}
+	return false, memberGroup
+}
+
+// checks a single applicant criteria group as of today
+func IsApplicantEligible(applicant Applicants, criteriaGroup CriteriaGroup) bool {
+	evaluator := eligibilityEvaluator{referenceDate: utils.StartOfDay(utils.Now())}
+	return evaluator.isApplicantEligible(applicant, criteriaGroup)
+}
+
+func (e *eligibilityEvaluator) isApplicantEligible(applicant Applicants, criteriaGroup CriteriaGroup) bool {
+	groupTrace := CriteriaGroupTrace{CriteriaGroupID: criteriaGroup.ID, Criterias: []CriteriaTrace{}}
+	satisfied := false
+	for _, criteria := range criteriaGroup.Criterias {
+		mismatches := matchApplicantCriteria(applicant, criteria, e.referenceDate)
+		if len(mismatches) == 0 {
+			satisfied = true
+		}
+		if !e.explain {
+			if satisfied {
+				return true
+			}
+			continue
+		}
+		groupTrace.Criterias = append(groupTrace.Criterias, CriteriaTrace{
+			CriteriaID: criteria.ID,
+			Satisfied:  len(mismatches) == 0,
+			Mismatches: mismatches,
+		})
+	}
+	if e.explain {
+		groupTrace.Satisfied = satisfied
+		e.trace.CriteriaGroups = append(e.trace.CriteriaGroups, groupTrace)
+	}
+	return satisfied
+}
+
+// checks household member criteria groups as of today
+func IsHouseholdEligible(criteriaGroups []CriteriaGroup, households []Households, usedHouseholds map[string]bool, index int) bool {
+	evaluator := eligibilityEvaluator{referenceDate: utils.StartOfDay(utils.Now())}
+	return evaluator.isHouseholdEligible(criteriaGroups, households, usedHouseholds, index)
+}
+
+func (e *eligibilityEvaluator) isHouseholdEligible(criteriaGroups []CriteriaGroup, households []Households, usedHouseholds map[string]bool, index int) bool {
+	if index >= len(criteriaGroups) {
+		if index > 0 {
+			e.recordAssignment("", "", "", "succeeded")
+		}
+		return true
+	}
+	for _, household := range households {
+		if usedHouseholds[household.ID] {
+			continue // Skip already used households
+		}
+
+		for _, criteria := range criteriaGroups[index].Criterias {
+			if len(matchHouseholdCriteria(household, criteria, e.referenceDate)) == 0 {
+
+				// Mark household as used
+				usedHouseholds[household.ID] = true
+				e.recordAssignment(criteriaGroups[index].ID, household.ID, criteria.ID, "assigned")
+
+				// Recur to check next criteriaGroup
+				if e.isHouseholdEligible(criteriaGroups, households, usedHouseholds, index+1) {
+					return true
+				}
+
+				// Backtrack: unmark this household
+				delete(usedHouseholds, household.ID)
+				e.recordAssignment(criteriaGroups[index].ID, household.ID, criteria.ID, "backtracked")
+			}
+		}
+	}
+	e.recordAssignment(criteriaGroups[index].ID, "", "", "exhausted")
+
+	return false // No valid assignment found
+}
+
+func (e *eligibilityEvaluator) explainHouseholdGroup(criteriaGroup CriteriaGroup, applicant Applicants) {
+	groupTrace := CriteriaGroupTrace{CriteriaGroupID: criteriaGroup.ID, IsHouseHold: true, Criterias: []CriteriaTrace{}}
+	for _, criteria := range criteriaGroup.Criterias {
+		if criteria.GetCriteriaType() != CriteriaTypeMember {
+			mismatches, matchedHouseholdIDs := matchCompositionCriteria(applicant, criteria, e.referenceDate)
+			if len(mismatches) == 0 {
+				groupTrace.Satisfied = true
+			}
+			groupTrace.Criterias = append(groupTrace.Criterias, CriteriaTrace{
+				CriteriaID:          criteria.ID,
+				MatchedHouseholdIDs: matchedHouseholdIDs,
+				Satisfied:           len(mismatches) == 0,
+				Mismatches:          mismatches,
+			})
+			continue
+		}
+		for _, household := range applicant.Households {
+			mismatches := matchHouseholdCriteria(household, criteria, e.referenceDate)
+			if len(mismatches) == 0 {
+				groupTrace.Satisfied = true
+			}
+			groupTrace.Criterias = append(groupTrace.Criterias, CriteriaTrace{
+				CriteriaID:  criteria.ID,
+				HouseholdID: household.ID,
+				Satisfied:   len(mismatches) == 0,
+				Mismatches:  mismatches,
+			})
+		}
+	}
+	e.trace.CriteriaGroups = append(e.trace.CriteriaGroups, groupTrace)
+}
+
+func (e *eligibilityEvaluator) recordAssignment(groupID string, householdID string, criteriaID string, action string) {
+	if !e.explain {
+		return
+	}
+	e.trace.HouseholdAssignments = append(e.trace.HouseholdAssignments, HouseholdAssignmentTrace{
+		Step:            len(e.trace.HouseholdAssignments) + 1,
+		CriteriaGroupID: groupID,
+		HouseholdID:     householdID,
+		CriteriaID:      criteriaID,
+		Action:          action,
+	})
+}
+
+// Match logic for the applicant and a single criteria, returns the fields that did not match
+func matchApplicantCriteria(a Applicants, c Criterias, at time.Time) []FieldMismatch {
+	mismatches := []FieldMismatch{}
+	age := a.GetAgeAt(at)
+	if age < c.AgeLowerLimit || age > c.AgeUpperLimit {
+		mismatches = append(mismatches, ageMismatch(age, c))
+	}
+	if c.EmploymentStatus != 99 && a.EmploymentStatus != c.EmploymentStatus {
+		mismatches = append(mismatches, uintMismatch("employment_status", c.EmploymentStatus, a.EmploymentStatus))
+	}
+	if c.Sex != 99 && a.Sex != c.Sex {
+		mismatches = append(mismatches, uintMismatch("sex", c.Sex, a.Sex))
+	}
+	if c.MaritalStatus != 99 && a.MaritalStatus != c.MaritalStatus {
+		mismatches = append(mismatches, uintMismatch("marital_status", c.MaritalStatus, a.MaritalStatus))
+	}
+	mismatches = append(mismatches, matchMeans(c, a.MonthlyIncome, a.Assets)...)
+	if mismatch := matchCriteriaExpression(c, func() expression.Env { return a.expressionEnv(at) }); mismatch != nil {
+		mismatches = append(mismatches, *mismatch)
+	}
+	return mismatches
+}
+
+// Match logic for a household and a single criteria, returns the fields that did not match
+func matchHouseholdCriteria(h Households, c Criterias, at time.Time) []FieldMismatch {
+	mismatches := []FieldMismatch{}
+	age := h.GetAgeAt(at)
+	if age < c.AgeLowerLimit || age > c.AgeUpperLimit {
+		mismatches = append(mismatches, ageMismatch(age, c))
+	}
+	if c.EmploymentStatus != 99 && h.EmploymentStatus != c.EmploymentStatus {
+		mismatches = append(mismatches, uintMismatch("employment_status", c.EmploymentStatus, h.EmploymentStatus))
+	}
+	if c.Sex != 99 && h.Sex != c.Sex {
+		mismatches = append(mismatches, uintMismatch("sex", c.Sex, h.Sex))
+	}
+	if c.Relation != 99 && h.Relation != c.Relation {
+		mismatches = append(mismatches, uintMismatch("relation", c.Relation, h.Relation))
+	}
+	if c.MaritalStatus != 99 && h.MaritalStatus != c.MaritalStatus {
+		mismatches = append(mismatches, uintMismatch("marital_status", c.MaritalStatus, h.MaritalStatus))
+	}
+	mismatches = append(mismatches, matchMeans(c, h.MonthlyIncome, h.Assets)...)
+	if mismatch := matchCriteriaExpression(c, func() expression.Env { return h.expressionEnv(at) }); mismatch != nil {
+		mismatches = append(mismatches, *mismatch)
+	}
+	return mismatches
+}
+
+// Match logic for the income and asset limits of a single person
+func matchMeans(c Criterias, monthlyIncome utils.Money, assets utils.Money) []FieldMismatch {
+	mismatches := []FieldMismatch{}
+	if c.MaxMonthlyIncome != nil && monthlyIncome > *c.MaxMonthlyIncome {
+		mismatches = append(mismatches, moneyMismatch("monthly_income", *c.MaxMonthlyIncome, monthlyIncome))
+	}
+	if c.MaxAssets != nil && assets > *c.MaxAssets {
+		mismatches = append(mismatches, moneyMismatch("assets", *c.MaxAssets, assets))
+	}
+	return mismatches
+}
+
+// Match logic for a household composition or household income criteria, returns the fields that did not match
+// and the household members that were counted
+func matchCompositionCriteria(applicant Applicants, c Criterias, at time.Time) ([]FieldMismatch, []string) {
+	households := applicant.Households
+	mismatches := []FieldMismatch{}
+	matchedHouseholdIDs := []string{}
+	switch c.GetCriteriaType() {
+	case CriteriaTypeMemberCount:
+		for _, household := range households {
+			if len(matchHouseholdCriteria(household, c, at)) == 0 {
+				matchedHouseholdIDs = append(matchedHouseholdIDs, household.ID)
+			}
+		}
+		count := uint32(len(matchedHouseholdIDs))
+		if count < c.MinCount || (c.MaxCount != nil && count > *c.MaxCount) {
+			mismatches = append(mismatches, countMismatch("household_member_count", count, c))
+		}
+	case CriteriaTypeHouseholdSize:
+		// household size includes the applicant
+		size := uint32(len(households) + 1)
+		if size < c.MinCount || (c.MaxCount != nil && size > *c.MaxCount) {
+			mismatches = append(mismatches, countMismatch("household_size", size, c))
+		}
+	case CriteriaTypeRelationPresent, CriteriaTypeRelationAbsent:
+		for _, household := range households {
+			if household.Relation == c.Relation {
+				matchedHouseholdIDs = append(matchedHouseholdIDs, household.ID)
+			}
+		}
+		present := len(matchedHouseholdIDs) > 0
+		if present != (c.GetCriteriaType() == CriteriaTypeRelationPresent) {
+			mismatches = append(mismatches, FieldMismatch{
+				Field:    "relation_present",
+				Expected: fmt.Sprintf("%d: %t", c.Relation, !present),
+				Actual:   fmt.Sprintf("%d: %t", c.Relation, present),
+			})
+		}
+	case CriteriaTypeHouseholdIncome:
+		if c.MaxMonthlyIncome != nil && applicant.GetHouseholdIncome() > *c.MaxMonthlyIncome {
+			mismatches = append(mismatches, moneyMismatch("household_income", *c.MaxMonthlyIncome, applicant.GetHouseholdIncome()))
+		}
+		if c.MaxAssets != nil && applicant.GetHouseholdAssets() > *c.MaxAssets {
+			mismatches = append(mismatches, moneyMismatch("household_assets", *c.MaxAssets, applicant.GetHouseholdAssets()))
+		}
+	case CriteriaTypePerCapitaIncome:
+		if c.MaxMonthlyIncome != nil && applicant.PerCapitaIncomeAbove(*c.MaxMonthlyIncome) {
+			mismatches = append(mismatches, moneyMismatch("per_capita_income", *c.MaxMonthlyIncome, applicant.GetPerCapitaIncome()))
+		}
+	}
+	return mismatches, matchedHouseholdIDs
+}
+
+// criterias stored before criteria types existed have type 0, they are household member criterias
+func (c *Criterias) GetCriteriaType() uint {
+	if c.CriteriaType == 0 {
+		return CriteriaTypeMember
+	}
+	return c.CriteriaType
+}
+
+func countMismatch(field string, count uint32, c Criterias) FieldMismatch {
+	expected := fmt.Sprintf(">= %d", c.MinCount)
+	if c.MaxCount != nil {
+		expected = fmt.Sprintf("%d-%d", c.MinCount, *c.MaxCount)
+	}
+	return FieldMismatch{
+		Field:    field,
+		Expected: expected,
+		Actual:   fmt.Sprintf("%d", count),
+	}
+}
+
+func moneyMismatch(field string, limit utils.Money, actual utils.Money) FieldMismatch {
+	return FieldMismatch{
+		Field:    field,
+		Expected: fmt.Sprintf("<= %s", limit),
+		Actual:   actual.String(),
+	}
+}
+
+func ageMismatch(age uint32, c Criterias) FieldMismatch {
+	return FieldMismatch{
+		Field:    "age",
+		Expected: fmt.Sprintf("%d-%d", c.AgeLowerLimit, c.AgeUpperLimit),
+		Actual:   fmt.Sprintf("%d", age),
+	}
+}
+
+func uintMismatch(field string, expected uint, actual uint) FieldMismatch {
+	return FieldMismatch{
+		Field:    field,
+		Expected: fmt.Sprintf("%d", expected),
+		Actual:   fmt.Sprintf("%d", actual),
+	}
+}
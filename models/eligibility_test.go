@@ -0,0 +1,502 @@
+package models
+
+import (
+	"FASMS/utils"
+	"strings"
+	"testing"
+	"time"
+)
+
+func date(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+// pins utils.Now to at for the rest of the test
+func pinNow(t *testing.T, at time.Time) {
+	t.Helper()
+	now := utils.Now
+	utils.Now = func() time.Time { return at }
+	t.Cleanup(func() { utils.Now = now })
+}
+
+// a criteria without any limitation, the cases set the fields they test
+func anyCriteria(id string) Criterias {
+	return Criterias{ID: id, EmploymentStatus: 99, MaritalStatus: 99, Sex: 99, Relation: 99, AgeUpperLimit: 999}
+}
+
+func TestGetReferenceDate(t *testing.T) {
+	pinNow(t, time.Date(2026, time.October, 17, 15, 30, 0, 0, time.UTC))
+	fixedDate := date(2026, time.January, 1)
+	applicationDate := time.Date(2026, time.June, 15, 9, 0, 0, 0, time.UTC)
+
+	// the applicant turns 16 on 15 Jun 2026, the scheme is for applicants of 16 and over
+	applicant := Applicants{ID: "applicant", EmploymentStatus: 1, DOB: date(2010, time.June, 15)}
+	criteria := anyCriteria("adult")
+	criteria.AgeLowerLimit = 16
+
+	tests := []struct {
+		name          string
+		referenceType uint
+		referenceDate *time.Time
+		ctx           EligibilityContext
+		wantDate      time.Time
+		wantEligible  bool
+	}{
+		{name: "evaluation date", referenceType: AgeReferenceEvaluationDate, ctx: NewEligibilityContext(), wantDate: date(2026, time.October, 17), wantEligible: true},
+		{name: "no reference type is the evaluation date", ctx: NewEligibilityContext(), wantDate: date(2026, time.October, 17), wantEligible: true},
+		{name: "fixed date", referenceType: AgeReferenceFixedDate, referenceDate: &fixedDate, ctx: NewEligibilityContext(), wantDate: fixedDate, wantEligible: false},
+		{name: "fixed date without a date is the evaluation date", referenceType: AgeReferenceFixedDate, ctx: NewEligibilityContext(), wantDate: date(2026, time.October, 17), wantEligible: true},
+		{name: "start of year", referenceType: AgeReferenceStartOfYear, ctx: NewEligibilityContext(), wantDate: date(2026, time.January, 1), wantEligible: false},
+		{name: "application date on the birthday", referenceType: AgeReferenceApplicationDate, ctx: NewApplicationEligibilityContext(applicationDate), wantDate: date(2026, time.June, 15), wantEligible: true},
+		{name: "application date before the birthday", referenceType: AgeReferenceApplicationDate, ctx: NewApplicationEligibilityContext(applicationDate.AddDate(0, 0, -1)), wantDate: date(2026, time.June, 14), wantEligible: false},
+		{name: "application date outside of an application is the evaluation date", referenceType: AgeReferenceApplicationDate, ctx: NewEligibilityContext(), wantDate: date(2026, time.October, 17), wantEligible: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scheme := Schemes{
+				ID:               "scheme",
+				AgeReferenceType: tt.referenceType,
+				AgeReferenceDate: tt.referenceDate,
+				CriteriaGroups:   []CriteriaGroup{{ID: "group", Criterias: []Criterias{criteria}}},
+			}
+			if got := scheme.GetReferenceDate(tt.ctx); !got.Equal(tt.wantDate) {
+				t.Fatalf("GetReferenceDate = %s, want %s", got, tt.wantDate)
+			}
+			trace := ExplainEligibility(applicant, scheme, tt.ctx)
+			if trace.Eligible != tt.wantEligible {
+				t.Fatalf("ExplainEligibility eligible = %t, want %t", trace.Eligible, tt.wantEligible)
+			}
+			if !time.Time(trace.ReferenceDate).Equal(tt.wantDate) {
+				t.Fatalf("trace reference date = %s, want %s", time.Time(trace.ReferenceDate), tt.wantDate)
+			}
+			if got := CheckEligiblity(applicant, scheme, tt.ctx); got != tt.wantEligible {
+				t.Fatalf("CheckEligiblity = %t, want %t", got, tt.wantEligible)
+			}
+		})
+	}
+}
+
+// evaluated on 17 Oct 2026, the applicant is 40 and unemployed
+var eligibilityNow = time.Date(2026, time.October, 17, 12, 0, 0, 0, time.UTC)
+
+func testApplicant(households ...Households) Applicants {
+	return Applicants{ID: "applicant", EmploymentStatus: 1, MaritalStatus: 2, Sex: 2, DOB: date(1986, time.January, 1), Households: households}
+}
+
+func child(id string, dob time.Time) Households {
+	return Households{ID: id, Relation: 1, EmploymentStatus: 3, DOB: dob}
+}
+
+func spouse(id string) Households {
+	return Households{ID: id, Relation: 2, EmploymentStatus: 2, DOB: date(1985, time.March, 3)}
+}
+
+func unemployedCriteria() Criterias {
+	criteria := anyCriteria("unemployed")
+	criteria.EmploymentStatus = 1
+	return criteria
+}
+
+func seniorCriteria() Criterias {
+	criteria := anyCriteria("senior")
+	criteria.AgeLowerLimit = 65
+	return criteria
+}
+
+// a household member who is a child under 12
+func childCriteria(id string) Criterias {
+	criteria := anyCriteria(id)
+	criteria.IsHouseHold = true
+	criteria.Relation = 1
+	criteria.AgeUpperLimit = 11
+	return criteria
+}
+
+func spouseCriteria(id string) Criterias {
+	criteria := anyCriteria(id)
+	criteria.IsHouseHold = true
+	criteria.Relation = 2
+	return criteria
+}
+
+func group(id string, criterias ...Criterias) CriteriaGroup {
+	return CriteriaGroup{ID: id, Criterias: criterias}
+}
+
+// a criteria tree node stored flat, parent "" is the root
+func node(id string, parent string, position uint, operator uint, groupID string) CriteriaNode {
+	criteriaNode := CriteriaNode{ID: id, Position: position, Operator: operator}
+	if parent != "" {
+		criteriaNode.ParentID = &parent
+	}
+	if groupID != "" {
+		criteriaNode.CriteriaGroupID = &groupID
+	}
+	return criteriaNode
+}
+
+func TestEligibilityCriteriaTree(t *testing.T) {
+	pinNow(t, eligibilityNow)
+	groups := []CriteriaGroup{
+		group("unemployed", unemployedCriteria()),
+		group("senior", seniorCriteria()),
+		group("child", childCriteria("child under 12")),
+	}
+	withChild := testApplicant(child("child", date(2018, time.May, 1)))
+	withoutChild := testApplicant()
+
+	tests := []struct {
+		name      string
+		nodes     []CriteriaNode
+		applicant Applicants
+		want      bool
+	}{
+		{name: "no tree requires every group", applicant: withChild, want: false},
+		{
+			name:      "and",
+			nodes:     []CriteriaNode{node("and", "", 0, CriteriaNodeAnd, ""), node("a", "and", 0, CriteriaNodeGroup, "unemployed"), node("b", "and", 1, CriteriaNodeGroup, "child")},
+			applicant: withChild,
+			want:      true,
+		},
+		{
+			name:      "and with a failing group",
+			nodes:     []CriteriaNode{node("and", "", 0, CriteriaNodeAnd, ""), node("a", "and", 0, CriteriaNodeGroup, "unemployed"), node("b", "and", 1, CriteriaNodeGroup, "senior")},
+			applicant: withChild,
+			want:      false,
+		},
+		{
+			name:      "or",
+			nodes:     []CriteriaNode{node("or", "", 0, CriteriaNodeOr, ""), node("a", "or", 0, CriteriaNodeGroup, "senior"), node("b", "or", 1, CriteriaNodeGroup, "unemployed")},
+			applicant: withChild,
+			want:      true,
+		},
+		{
+			name:      "or without any group",
+			nodes:     []CriteriaNode{node("or", "", 0, CriteriaNodeOr, ""), node("a", "or", 0, CriteriaNodeGroup, "senior"), node("b", "or", 1, CriteriaNodeGroup, "child")},
+			applicant: withoutChild,
+			want:      false,
+		},
+		{
+			name:      "not",
+			nodes:     []CriteriaNode{node("not", "", 0, CriteriaNodeNot, ""), node("a", "not", 0, CriteriaNodeGroup, "senior")},
+			applicant: withChild,
+			want:      true,
+		},
+		{
+			name: "and of a group and not or, the or holds",
+			nodes: []CriteriaNode{
+				node("and", "", 0, CriteriaNodeAnd, ""),
+				node("a", "and", 0, CriteriaNodeGroup, "unemployed"),
+				node("not", "and", 1, CriteriaNodeNot, ""),
+				node("or", "not", 0, CriteriaNodeOr, ""),
+				node("b", "or", 0, CriteriaNodeGroup, "senior"),
+				node("c", "or", 1, CriteriaNodeGroup, "child"),
+			},
+			applicant: withChild,
+			want:      false,
+		},
+		{
+			name: "and of a group and not or, the or fails",
+			nodes: []CriteriaNode{
+				node("and", "", 0, CriteriaNodeAnd, ""),
+				node("a", "and", 0, CriteriaNodeGroup, "unemployed"),
+				node("not", "and", 1, CriteriaNodeNot, ""),
+				node("or", "not", 0, CriteriaNodeOr, ""),
+				node("b", "or", 0, CriteriaNodeGroup, "senior"),
+				node("c", "or", 1, CriteriaNodeGroup, "child"),
+			},
+			applicant: withoutChild,
+			want:      true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scheme := Schemes{ID: "scheme", CriteriaGroups: groups, CriteriaNodes: tt.nodes}
+			ctx := NewEligibilityContext()
+			if got := CheckEligiblity(tt.applicant, scheme, ctx); got != tt.want {
+				t.Fatalf("CheckEligiblity = %t, want %t", got, tt.want)
+			}
+			trace := ExplainEligibility(tt.applicant, scheme, ctx)
+			if trace.Eligible != tt.want {
+				t.Fatalf("ExplainEligibility eligible = %t, want %t", trace.Eligible, tt.want)
+			}
+			if trace.Rule == nil || trace.Rule.Satisfied != tt.want {
+				t.Fatalf("ExplainEligibility rule = %+v, want satisfied %t", trace.Rule, tt.want)
+			}
+		})
+	}
+}
+
+func TestEligibilityHouseholdMembers(t *testing.T) {
+	pinNow(t, eligibilityNow)
+	youngChild := child("young child", date(2020, time.February, 1))
+	otherChild := child("other child", date(2017, time.July, 9))
+	teenager := child("teenager", date(2010, time.January, 1))
+
+	tests := []struct {
+		name       string
+		groups     []CriteriaGroup
+		households []Households
+		want       bool
+		wantSteps  []string
+	}{
+		{
+			name:       "two groups need two distinct children",
+			groups:     []CriteriaGroup{group("first", childCriteria("first child")), group("second", childCriteria("second child"))},
+			households: []Households{youngChild},
+			want:       false,
+			wantSteps:  []string{"assigned", "exhausted", "backtracked", "exhausted"},
+		},
+		{
+			name:       "two groups matched by two children",
+			groups:     []CriteriaGroup{group("first", childCriteria("first child")), group("second", childCriteria("second child"))},
+			households: []Households{youngChild, otherChild},
+			want:       true,
+			wantSteps:  []string{"assigned", "assigned", "succeeded"},
+		},
+		{
+			name:       "a member too old does not count",
+			groups:     []CriteriaGroup{group("first", childCriteria("first child")), group("second", childCriteria("second child"))},
+			households: []Households{youngChild, teenager},
+			want:       false,
+		},
+		{
+			name:       "backtracks when the first assignment blocks the next group",
+			groups:     []CriteriaGroup{group("child or spouse", childCriteria("child"), spouseCriteria("spouse")), group("child", childCriteria("only child"))},
+			households: []Households{youngChild, spouse("spouse")},
+			want:       true,
+			wantSteps:  []string{"assigned", "exhausted", "backtracked", "assigned", "assigned", "succeeded"},
+		},
+		{
+			name:       "a household group without members",
+			groups:     []CriteriaGroup{group("child", childCriteria("child"))},
+			households: nil,
+			want:       false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			applicant := testApplicant(tt.households...)
+			scheme := Schemes{ID: "scheme", CriteriaGroups: tt.groups}
+			ctx := NewEligibilityContext()
+			if got := CheckEligiblity(applicant, scheme, ctx); got != tt.want {
+				t.Fatalf("CheckEligiblity = %t, want %t", got, tt.want)
+			}
+			trace := ExplainEligibility(applicant, scheme, ctx)
+			if trace.Eligible != tt.want {
+				t.Fatalf("ExplainEligibility eligible = %t, want %t", trace.Eligible, tt.want)
+			}
+			if tt.wantSteps == nil {
+				return
+			}
+			steps := []string{}
+			for i, assignment := range trace.HouseholdAssignments {
+				if assignment.Step != i+1 {
+					t.Fatalf("assignment %d has step %d", i, assignment.Step)
+				}
+				steps = append(steps, assignment.Action)
+			}
+			if len(steps) != len(tt.wantSteps) {
+				t.Fatalf("household assignments = %v, want %v", steps, tt.wantSteps)
+			}
+			for i := range steps {
+				if steps[i] != tt.wantSteps[i] {
+					t.Fatalf("household assignments = %v, want %v", steps, tt.wantSteps)
+				}
+			}
+		})
+	}
+}
+
+func TestEligibilityMeans(t *testing.T) {
+	pinNow(t, eligibilityNow)
+	limit := func(amount utils.Money) *utils.Money { return &amount }
+	incomeCriteria := func(criteriaType uint, maxIncome *utils.Money, maxAssets *utils.Money) Criterias {
+		criteria := anyCriteria("means")
+		criteria.CriteriaType = criteriaType
+		criteria.MaxMonthlyIncome = maxIncome
+		criteria.MaxAssets = maxAssets
+		if criteriaType != CriteriaTypeMember {
+			criteria.IsHouseHold = true
+		}
+		return criteria
+	}
+	// amounts are in cents, 3000_00 is 3000.00
+	earner := Households{ID: "earner", Relation: 2, MonthlyIncome: 3000_00, Assets: 20000_00, DOB: date(1985, time.March, 3)}
+	otherEarner := Households{ID: "other-earner", Relation: 3, MonthlyIncome: 999_99, DOB: date(1960, time.March, 3)}
+
+	tests := []struct {
+		name           string
+		criteria       Criterias
+		income         utils.Money
+		assets         utils.Money
+		households     []Households
+		want           bool
+		wantMismatches []string
+	}{
+		{name: "applicant income under the limit", criteria: incomeCriteria(CriteriaTypeMember, limit(1500_00), nil), income: 1500_00, want: true},
+		{name: "applicant income over the limit", criteria: incomeCriteria(CriteriaTypeMember, limit(1500_00), nil), income: 1500_01, want: false, wantMismatches: []string{"monthly_income"}},
+		{name: "applicant assets over the limit", criteria: incomeCriteria(CriteriaTypeMember, nil, limit(10000_00)), assets: 10001_00, want: false, wantMismatches: []string{"assets"}},
+		{name: "applicant income and assets over the limits", criteria: incomeCriteria(CriteriaTypeMember, limit(1500_00), limit(10000_00)), income: 2000_00, assets: 10001_00, want: false, wantMismatches: []string{"monthly_income", "assets"}},
+		{name: "no limits", criteria: incomeCriteria(CriteriaTypeMember, nil, nil), income: 100000_00, assets: 100000_00, want: true},
+		{name: "household income under the limit", criteria: incomeCriteria(CriteriaTypeHouseholdIncome, limit(4000_00), nil), income: 1000_00, households: []Households{earner}, want: true},
+		{name: "household income over the limit", criteria: incomeCriteria(CriteriaTypeHouseholdIncome, limit(3999_00), nil), income: 1000_00, households: []Households{earner}, want: false, wantMismatches: []string{"household_income"}},
+		{name: "household assets over the limit", criteria: incomeCriteria(CriteriaTypeHouseholdIncome, nil, limit(25000_00)), assets: 5001_00, households: []Households{earner}, want: false, wantMismatches: []string{"household_assets"}},
+		{name: "per capita income under the limit", criteria: incomeCriteria(CriteriaTypePerCapitaIncome, limit(2000_00), nil), income: 1000_00, households: []Households{earner}, want: true},
+		{name: "per capita income over the limit", criteria: incomeCriteria(CriteriaTypePerCapitaIncome, limit(1999_00), nil), income: 1000_00, households: []Households{earner}, want: false, wantMismatches: []string{"per_capita_income"}},
+		{name: "per capita income exactly at the limit", criteria: incomeCriteria(CriteriaTypePerCapitaIncome, limit(1333_33), nil), income: 0, households: []Households{earner, otherEarner}, want: true},
+		{name: "per capita income a third of a cent over the limit", criteria: incomeCriteria(CriteriaTypePerCapitaIncome, limit(1333_33), nil), income: 0_01, households: []Households{earner, otherEarner}, want: false, wantMismatches: []string{"per_capita_income"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			applicant := testApplicant(tt.households...)
+			applicant.MonthlyIncome = tt.income
+			applicant.Assets = tt.assets
+			scheme := Schemes{ID: "scheme", CriteriaGroups: []CriteriaGroup{group("means", tt.criteria)}}
+			trace := ExplainEligibility(applicant, scheme, NewEligibilityContext())
+			if trace.Eligible != tt.want {
+				t.Fatalf("ExplainEligibility eligible = %t, want %t", trace.Eligible, tt.want)
+			}
+			if len(trace.CriteriaGroups) != 1 || len(trace.CriteriaGroups[0].Criterias) != 1 {
+				t.Fatalf("ExplainEligibility criteria groups = %+v, want one criteria", trace.CriteriaGroups)
+			}
+			mismatches := trace.CriteriaGroups[0].Criterias[0].Mismatches
+			if len(mismatches) != len(tt.wantMismatches) {
+				t.Fatalf("mismatches = %+v, want %v", mismatches, tt.wantMismatches)
+			}
+			for i, mismatch := range mismatches {
+				if mismatch.Field != tt.wantMismatches[i] {
+					t.Fatalf("mismatches = %+v, want %v", mismatches, tt.wantMismatches)
+				}
+			}
+		})
+	}
+}
+
+func TestExplainEligibilityTrace(t *testing.T) {
+	pinNow(t, eligibilityNow)
+	applicant := testApplicant(child("child", date(2018, time.May, 1)))
+	scheme := Schemes{ID: "scheme", CriteriaGroups: []CriteriaGroup{
+		group("applicant", seniorCriteria(), unemployedCriteria()),
+		group("household", spouseCriteria("spouse")),
+	}}
+
+	trace := ExplainEligibility(applicant, scheme, NewEligibilityContext())
+	if trace.Eligible || trace.ApplicantID != "applicant" || trace.SchemeID != "scheme" {
+		t.Fatalf("ExplainEligibility = %+v, want not eligible", trace)
+	}
+	if len(trace.CriteriaGroups) != 2 {
+		t.Fatalf("criteria groups = %+v, want the applicant and the household group", trace.CriteriaGroups)
+	}
+
+	// every criteria of the applicant group is tried, the group holds with one of them
+	applicantGroup := trace.CriteriaGroups[0]
+	if !applicantGroup.Satisfied || applicantGroup.IsHouseHold || len(applicantGroup.Criterias) != 2 {
+		t.Fatalf("applicant group = %+v", applicantGroup)
+	}
+	senior := applicantGroup.Criterias[0]
+	if senior.Satisfied || len(senior.Mismatches) != 1 || senior.Mismatches[0] != (FieldMismatch{Field: "age", Expected: "65-999", Actual: "40"}) {
+		t.Fatalf("senior criteria = %+v", senior)
+	}
+	if !applicantGroup.Criterias[1].Satisfied {
+		t.Fatalf("unemployed criteria = %+v, want satisfied", applicantGroup.Criterias[1])
+	}
+
+	// the household group has one row per household member tried
+	householdGroup := trace.CriteriaGroups[1]
+	if householdGroup.Satisfied || !householdGroup.IsHouseHold || len(householdGroup.Criterias) != 1 {
+		t.Fatalf("household group = %+v", householdGroup)
+	}
+	row := householdGroup.Criterias[0]
+	if row.HouseholdID != "child" || row.Satisfied || len(row.Mismatches) != 1 || row.Mismatches[0].Field != "relation" {
+		t.Fatalf("household row = %+v", row)
+	}
+	if trace.Rule == nil || trace.Rule.Satisfied || len(trace.Rule.Children) != 2 || !trace.Rule.Children[0].Satisfied || trace.Rule.Children[1].Satisfied {
+		t.Fatalf("rule = %+v", trace.Rule)
+	}
+}
+
+func TestMatchCompositionCriteria(t *testing.T) {
+	at := date(2026, time.October, 17)
+	count := func(n uint32) *uint32 { return &n }
+	composition := func(criteriaType uint, minCount uint32, maxCount *uint32) Criterias {
+		criteria := childCriteria("composition")
+		criteria.CriteriaType = criteriaType
+		criteria.MinCount = minCount
+		criteria.MaxCount = maxCount
+		return criteria
+	}
+	relation := func(criteriaType uint, relation uint) Criterias {
+		criteria := anyCriteria("relation")
+		criteria.IsHouseHold = true
+		criteria.CriteriaType = criteriaType
+		criteria.Relation = relation
+		return criteria
+	}
+	withExpression := func(criteria Criterias, expression string) Criterias {
+		criteria.Expression = expression
+		return criteria
+	}
+	youngChild := child("young child", date(2020, time.February, 1))
+	otherChild := child("other child", date(2017, time.July, 9))
+	teenager := child("teenager", date(2010, time.January, 1))
+
+	tests := []struct {
+		name         string
+		criteria     Criterias
+		households   []Households
+		wantMismatch *FieldMismatch
+		wantMatched  []string
+	}{
+		{name: "member count without max", criteria: composition(CriteriaTypeMemberCount, 2, nil), households: []Households{youngChild, otherChild, teenager}, wantMatched: []string{"young child", "other child"}},
+		{name: "member count under min", criteria: composition(CriteriaTypeMemberCount, 2, nil), households: []Households{youngChild, teenager}, wantMismatch: &FieldMismatch{Field: "household_member_count", Expected: ">= 2", Actual: "1"}, wantMatched: []string{"young child"}},
+		{name: "member count within min and max", criteria: composition(CriteriaTypeMemberCount, 1, count(2)), households: []Households{youngChild, otherChild}, wantMatched: []string{"young child", "other child"}},
+		{name: "member count over max", criteria: composition(CriteriaTypeMemberCount, 0, count(1)), households: []Households{youngChild, otherChild}, wantMismatch: &FieldMismatch{Field: "household_member_count", Expected: "0-1", Actual: "2"}, wantMatched: []string{"young child", "other child"}},
+		{name: "member count max 0 without matching members", criteria: composition(CriteriaTypeMemberCount, 0, count(0)), households: []Households{teenager}},
+		{name: "member count max 0 with a matching member", criteria: composition(CriteriaTypeMemberCount, 0, count(0)), households: []Households{youngChild, teenager}, wantMismatch: &FieldMismatch{Field: "household_member_count", Expected: "0-0", Actual: "1"}, wantMatched: []string{"young child"}},
+		{name: "household size includes the applicant", criteria: composition(CriteriaTypeHouseholdSize, 3, nil), households: []Households{youngChild, teenager}},
+		{name: "household size under min", criteria: composition(CriteriaTypeHouseholdSize, 3, nil), households: []Households{teenager}, wantMismatch: &FieldMismatch{Field: "household_size", Expected: ">= 3", Actual: "2"}},
+		{name: "household size over max", criteria: composition(CriteriaTypeHouseholdSize, 1, count(2)), households: []Households{youngChild, teenager}, wantMismatch: &FieldMismatch{Field: "household_size", Expected: "1-2", Actual: "3"}},
+		{name: "household size max 0 never holds", criteria: composition(CriteriaTypeHouseholdSize, 0, count(0)), households: nil, wantMismatch: &FieldMismatch{Field: "household_size", Expected: "0-0", Actual: "1"}},
+		{name: "relation present", criteria: relation(CriteriaTypeRelationPresent, 2), households: []Households{youngChild, spouse("spouse")}, wantMatched: []string{"spouse"}},
+		{name: "relation present missing", criteria: relation(CriteriaTypeRelationPresent, 2), households: []Households{youngChild}, wantMismatch: &FieldMismatch{Field: "relation_present", Expected: "2: true", Actual: "2: false"}},
+		{name: "relation absent", criteria: relation(CriteriaTypeRelationAbsent, 2), households: []Households{youngChild}},
+		{name: "relation absent present", criteria: relation(CriteriaTypeRelationAbsent, 1), households: []Households{youngChild, teenager}, wantMismatch: &FieldMismatch{Field: "relation_present", Expected: "1: false", Actual: "1: true"}, wantMatched: []string{"young child", "teenager"}},
+		{name: "member count counts the members satisfying the expression", criteria: withExpression(composition(CriteriaTypeMemberCount, 1, nil), "age < 8"), households: []Households{youngChild, otherChild}, wantMatched: []string{"young child"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mismatches, matched := matchCompositionCriteria(testApplicant(tt.households...), tt.criteria, at)
+			if tt.wantMismatch == nil && len(mismatches) != 0 {
+				t.Fatalf("mismatches = %+v, want none", mismatches)
+			}
+			if tt.wantMismatch != nil && (len(mismatches) != 1 || mismatches[0] != *tt.wantMismatch) {
+				t.Fatalf("mismatches = %+v, want %+v", mismatches, *tt.wantMismatch)
+			}
+			if len(matched) != len(tt.wantMatched) {
+				t.Fatalf("matched = %v, want %v", matched, tt.wantMatched)
+			}
+			for i := range matched {
+				if matched[i] != tt.wantMatched[i] {
+					t.Fatalf("matched = %v, want %v", matched, tt.wantMatched)
+				}
+			}
+		})
+	}
+
+	// only a member count criteria evaluates its expression, on each household member,
+	// a scheme with an expression on any other household criteria type is not valid
+	isHouseHold := true
+	maxIncome := utils.Money(1000_00)
+	for _, criteriaType := range []uint{CriteriaTypeHouseholdSize, CriteriaTypeRelationPresent, CriteriaTypeRelationAbsent, CriteriaTypeHouseholdIncome, CriteriaTypePerCapitaIncome} {
+		criteria := CreateCriteriaRequest{EmploymentStatus: 99, MaritalStatus: 99, Sex: 99, Relation: 1, AgeUpperLimit: 999, IsHouseHold: &isHouseHold, CriteriaType: criteriaType, MinCount: 1, MaxMonthlyIncome: &maxIncome}
+		scheme := CreateSchemesRequest{Name: "scheme", CriteriaGroups: []CreateCriteriaGroupsRequest{{Criterias: []CreateCriteriaRequest{criteria}}}, Benefits: []CreateBenefitRequest{{Name: "one-off", Amount: 100_00}}}
+		if valid, err := scheme.IsValidScheme(); !valid {
+			t.Fatalf("IsValidScheme of criteria type %d = %v, want valid", criteriaType, err)
+		}
+		scheme.CriteriaGroups[0].Criterias[0].Expression = "age < 8"
+		if valid, err := scheme.IsValidScheme(); valid || !strings.Contains(err.Error(), "can have an expression") {
+			t.Fatalf("IsValidScheme of criteria type %d with an expression = %t, %v, want not valid", criteriaType, valid, err)
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package models
+
+import (
+	"FASMS/expression"
+	"container/list"
+	"sync"
+	"time"
+)
+
+// attributes a household criteria expression can use, evaluated on one household member
+var HouseholdExpressionScope = expression.Scope{
+	Variables: []string{"age", "sex", "marital_status", "employment_status", "relation", "monthly_income", "assets"},
+}
+
+// attributes an applicant criteria expression can use,
+// count/any/all(...) evaluate their argument on each household member
+var ApplicantExpressionScope = expression.Scope{
+	Variables: []string{"age", "sex", "marital_status", "employment_status", "monthly_income", "assets",
+		"household_size", "household_income", "household_assets", "per_capita_income"},
+	Members: &HouseholdExpressionScope,
+}
+
+// compiled programs keyed by scope and source, the least recently used are dropped past maxCompiledExpressions
+// so that the expressions of proposed definitions that are never saved do not pile up
+const maxCompiledExpressions = 1024
+
+var compiledExpressions = newExpressionCache(maxCompiledExpressions)
+
+type compiledExpressionKey struct {
+	isHouseHold bool
+	source      string
+}
+
+type compiledExpression struct {
+	key     compiledExpressionKey
+	program *expression.Program
+}
+
+// an LRU cache of compiled programs, safe for concurrent use
+type expressionCache struct {
+	mutex    sync.Mutex
+	capacity int
+	entries  map[compiledExpressionKey]*list.Element
+	order    *list.List
+}
+
+func newExpressionCache(capacity int) *expressionCache {
+	return &expressionCache{capacity: capacity, entries: make(map[compiledExpressionKey]*list.Element), order: list.New()}
+}
+
+func (c *expressionCache) load(key compiledExpressionKey) (*expression.Program, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	element, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	c.order.MoveToFront(element)
+	return element.Value.(*compiledExpression).program, true
+}
+
+func (c *expressionCache) store(key compiledExpressionKey, program *expression.Program) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if element, ok := c.entries[key]; ok {
+		c.order.MoveToFront(element)
+		return
+	}
+	c.entries[key] = c.order.PushFront(&compiledExpression{key: key, program: program})
+	for c.order.Len() > c.capacity {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*compiledExpression).key)
+	}
+}
+
+func (c *expressionCache) len() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.order.Len()
+}
+
+func CompileCriteriaExpression(source string, isHouseHold bool) (*expression.Program, error) {
+	key := compiledExpressionKey{isHouseHold: isHouseHold, source: source}
+	if program, ok := compiledExpressions.load(key); ok {
+		return program, nil
+	}
+	scope := ApplicantExpressionScope
+	if isHouseHold {
+		scope = HouseholdExpressionScope
+	}
+	program, err := expression.Compile(source, scope)
+	if err != nil {
+		return nil, err
+	}
+	compiledExpressions.store(key, program)
+	return program, nil
+}
+
+func (a *Applicants) expressionEnv(at time.Time) expression.Env {
+	env := expression.Env{
+		Variables: map[string]float64{
+			"age":               float64(a.GetAgeAt(at)),
+			"sex":               float64(a.Sex),
+			"marital_status":    float64(a.MaritalStatus),
+			"employment_status": float64(a.EmploymentStatus),
+			"monthly_income":    a.MonthlyIncome.Float64(),
+			"assets":            a.Assets.Float64(),
+			"household_size":    float64(len(a.Households) + 1),
+			"household_income":  a.GetHouseholdIncome().Float64(),
+			"household_assets":  a.GetHouseholdAssets().Float64(),
+			"per_capita_income": a.GetHouseholdIncome().Float64() / float64(len(a.Households)+1),
+		},
+	}
+	for _, household := range a.Households {
+		env.Members = append(env.Members, household.expressionEnv(at))
+	}
+	return env
+}
+
+func (h *Households) expressionEnv(at time.Time) expression.Env {
+	return expression.Env{
+		Variables: map[string]float64{
+			"age":               float64(h.GetAgeAt(at)),
+			"sex":               float64(h.Sex),
+			"marital_status":    float64(h.MaritalStatus),
+			"employment_status": float64(h.EmploymentStatus),
+			"relation":          float64(h.Relation),
+			"monthly_income":    h.MonthlyIncome.Float64(),
+			"assets":            h.Assets.Float64(),
+		},
+	}
+}
+
+// evaluates the expression of a criteria, a criteria without expression always passes
+func matchCriteriaExpression(c Criterias, env func() expression.Env) *FieldMismatch {
+	if c.Expression == "" {
+		return nil
+	}
+	program, err := CompileCriteriaExpression(c.Expression, c.IsHouseHold)
+	if err != nil {
+		return &FieldMismatch{Field: "expression", Expected: c.Expression, Actual: err.Error()}
+	}
+	if !program.Eval(env()) {
+		return &FieldMismatch{Field: "expression", Expected: c.Expression, Actual: "false"}
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+package models
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestExpressionCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	program, err := CompileCriteriaExpression("age > 1", false)
+	if err != nil {
+		t.Fatalf("CompileCriteriaExpression error = %v", err)
+	}
+	first := compiledExpressionKey{source: "age > 1"}
+	second := compiledExpressionKey{source: "age > 2"}
+	third := compiledExpressionKey{isHouseHold: true, source: "age > 1"}
+
+	cache := newExpressionCache(2)
+	cache.store(first, program)
+	cache.store(second, program)
+	// first is used again, second is now the least recently used
+	if _, ok := cache.load(first); !ok {
+		t.Fatalf("%v is not cached", first)
+	}
+	cache.store(third, program)
+
+	if cache.len() != 2 {
+		t.Fatalf("len() = %d, want 2", cache.len())
+	}
+	if _, ok := cache.load(second); ok {
+		t.Fatalf("%v is still cached, want it evicted", second)
+	}
+	for _, key := range []compiledExpressionKey{first, third} {
+		if _, ok := cache.load(key); !ok {
+			t.Fatalf("%v is not cached", key)
+		}
+	}
+}
+
+func TestCompileCriteriaExpressionIsBounded(t *testing.T) {
+	for i := 0; i < maxCompiledExpressions+10; i++ {
+		if _, err := CompileCriteriaExpression(fmt.Sprintf("age > %d", i), false); err != nil {
+			t.Fatalf("CompileCriteriaExpression error = %v", err)
+		}
+	}
+	if compiledExpressions.len() != maxCompiledExpressions {
+		t.Fatalf("len() = %d, want %d", compiledExpressions.len(), maxCompiledExpressions)
+	}
+	if _, err := CompileCriteriaExpression("relation == 1", false); err == nil {
+		t.Fatalf("relation is not an applicant attribute, want error")
+	}
+}
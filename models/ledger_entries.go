@@ -0,0 +1,102 @@
+package models
+
+import (
+	"FASMS/utils"
+	"time"
+)
+
+// the ledger accounts, an approval books the expense against the amount payable to the applicant,
+// a payment settles the payable from cash
+const (
+	LedgerAccountBenefitExpense = "benefit_expense"
+	LedgerAccountBenefitPayable = "benefit_payable"
+	LedgerAccountCash           = "cash"
+)
+
+// the events posting to the ledger
+const (
+	LedgerEventAccrued   = "accrued"
+	LedgerEventPaid      = "paid"
+	LedgerEventReversed  = "reversed"
+	LedgerEventCancelled = "cancelled"
+)
+
+// one side of a journal, the debits and credits of a journal always balance. the rows are never updated or deleted,
+// a reversal posts a new journal
+type LedgerEntries struct {
+	ID             string      `json:"id" gorm:"primaryKey"`
+	JournalID      string      `json:"journal_id" gorm:"index;not null"`
+	DisbursementID string      `json:"disbursement_id" gorm:"index;not null"`
+	Event          string      `json:"event" gorm:"not null;comment:'accrued, paid, reversed or cancelled'"`
+	Account        string      `json:"account" gorm:"index;not null;comment:'benefit_expense, benefit_payable or cash'"`
+	Debit          utils.Money `json:"debit" gorm:"type:numeric(14,2);not null"`
+	Credit         utils.Money `json:"credit" gorm:"type:numeric(14,2);not null"`
+	Currency       string      `json:"currency" gorm:"size:3;not null"`
+	CreatedAt      time.Time   `json:"created_at"`
+}
+
+type LedgerEntriesResponse struct {
+	ID        string      `json:"id"`
+	JournalID string      `json:"journal_id"`
+	Event     string      `json:"event"`
+	Account   string      `json:"account"`
+	Debit     utils.Money `json:"debit"`
+	Credit    utils.Money `json:"credit"`
+	Currency  string      `json:"currency"`
+	CreatedAt time.Time   `json:"created_at"`
+}
+
+// a balanced journal moving the amount of the disbursement from the credited account to the debited account
+func NewLedgerJournal(disbursement Disbursements, event string, debitAccount string, creditAccount string) []LedgerEntries {
+	journalID := utils.GenerateUUID()
+	return []LedgerEntries{
+		{
+			ID:             utils.GenerateUUID(),
+			JournalID:      journalID,
+			DisbursementID: disbursement.ID,
+			Event:          event,
+			Account:        debitAccount,
+			Debit:          disbursement.Amount,
+			Currency:       disbursement.Currency,
+		},
+		{
+			ID:             utils.GenerateUUID(),
+			JournalID:      journalID,
+			DisbursementID: disbursement.ID,
+			Event:          event,
+			Account:        creditAccount,
+			Credit:         disbursement.Amount,
+			Currency:       disbursement.Currency,
+		},
+	}
+}
+
+// the journals a disbursement event posts, a reversal undoes both the payment and the accrual,
+// a cancellation only the accrual as nothing was paid
+func LedgerJournalsFor(disbursement Disbursements, event string) []LedgerEntries {
+	switch event {
+	case LedgerEventAccrued:
+		return NewLedgerJournal(disbursement, event, LedgerAccountBenefitExpense, LedgerAccountBenefitPayable)
+	case LedgerEventPaid:
+		return NewLedgerJournal(disbursement, event, LedgerAccountBenefitPayable, LedgerAccountCash)
+	case LedgerEventReversed:
+		entries := NewLedgerJournal(disbursement, event, LedgerAccountCash, LedgerAccountBenefitPayable)
+		return append(entries, NewLedgerJournal(disbursement, event, LedgerAccountBenefitPayable, LedgerAccountBenefitExpense)...)
+	case LedgerEventCancelled:
+		return NewLedgerJournal(disbursement, event, LedgerAccountBenefitPayable, LedgerAccountBenefitExpense)
+	}
+	return []LedgerEntries{}
+}
+
+func (l *LedgerEntries) ConvertToResponse() LedgerEntriesResponse {
+	return LedgerEntriesResponse{
+		ID:        l.ID,
+		JournalID: l.JournalID,
+		Event:     l.Event,
+		Account:   l.Account,
+		Debit:     l.Debit,
+		Credit:    l.Credit,
+		Currency:  l.Currency,
+		CreatedAt: l.CreatedAt,
+	}
+}
@@ -0,0 +1,98 @@
+package models
+
+import (
+	"FASMS/utils"
+	"fmt"
+	"sort"
+	"testing"
+)
+
+func ledgerDisbursement() Disbursements {
+	return Disbursements{ID: "disbursement", Amount: 123_45, Currency: "SGD", Status: DisbursementStatusPaid}
+}
+
+// the debit and credit of each posting, with the debit and credit swapped when mirrored
+func ledgerPostings(entries []LedgerEntries, mirrored bool) []string {
+	postings := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		debit, credit := entry.Debit, entry.Credit
+		if mirrored {
+			debit, credit = credit, debit
+		}
+		postings = append(postings, fmt.Sprintf("%s %s/%s", entry.Account, debit, credit))
+	}
+	sort.Strings(postings)
+	return postings
+}
+
+func TestLedgerJournalsBalance(t *testing.T) {
+	disbursement := ledgerDisbursement()
+	tests := []struct {
+		event        string
+		wantJournals int
+	}{
+		{event: LedgerEventAccrued, wantJournals: 1},
+		{event: LedgerEventPaid, wantJournals: 1},
+		{event: LedgerEventReversed, wantJournals: 2},
+		{event: LedgerEventCancelled, wantJournals: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.event, func(t *testing.T) {
+			entries := LedgerJournalsFor(disbursement, tt.event)
+			debits := make(map[string]utils.Money)
+			credits := make(map[string]utils.Money)
+			for _, entry := range entries {
+				if entry.DisbursementID != disbursement.ID || entry.Event != tt.event || entry.Currency != disbursement.Currency || entry.JournalID == "" || entry.ID == "" {
+					t.Fatalf("entry = %+v, want an entry of the %s event of the disbursement", entry, tt.event)
+				}
+				// an entry is on one side only
+				if (entry.Debit == 0) == (entry.Credit == 0) {
+					t.Fatalf("entry = %+v, want either a debit or a credit", entry)
+				}
+				debits[entry.JournalID] += entry.Debit
+				credits[entry.JournalID] += entry.Credit
+			}
+			if len(debits) != tt.wantJournals {
+				t.Fatalf("LedgerJournalsFor(%s) posted %d journals, want %d", tt.event, len(debits), tt.wantJournals)
+			}
+			for journalID, debit := range debits {
+				if debit != credits[journalID] || debit != disbursement.Amount {
+					t.Fatalf("journal debits %s and credits %s, want both %s", debit, credits[journalID], disbursement.Amount)
+				}
+			}
+		})
+	}
+
+	if entries := LedgerJournalsFor(disbursement, "failed"); len(entries) != 0 {
+		t.Fatalf("LedgerJournalsFor(failed) = %+v, want no entries", entries)
+	}
+}
+
+func TestLedgerReversalMirrorsSettlement(t *testing.T) {
+	disbursement := ledgerDisbursement()
+	settled := append(LedgerJournalsFor(disbursement, LedgerEventAccrued), LedgerJournalsFor(disbursement, LedgerEventPaid)...)
+	reversed := LedgerJournalsFor(disbursement, LedgerEventReversed)
+
+	want, got := ledgerPostings(settled, true), ledgerPostings(reversed, false)
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Fatalf("reversal postings = %v, want the mirror of the settlement %v", got, want)
+	}
+
+	// every account is back to 0 once the settled disbursement is reversed
+	balances := make(map[string]utils.Money)
+	for _, entry := range append(settled, reversed...) {
+		balances[entry.Account] += entry.Debit - entry.Credit
+	}
+	for account, balance := range balances {
+		if balance != 0 {
+			t.Fatalf("balance of %s = %s after the reversal, want 0", account, balance)
+		}
+	}
+
+	// a cancellation only mirrors the accrual, nothing was paid
+	accrued := LedgerJournalsFor(disbursement, LedgerEventAccrued)
+	cancelled := LedgerJournalsFor(disbursement, LedgerEventCancelled)
+	if got, want := ledgerPostings(cancelled, false), ledgerPostings(accrued, true); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Fatalf("cancellation postings = %v, want the mirror of the accrual %v", got, want)
+	}
+}
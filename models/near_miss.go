@@ -0,0 +1,348 @@
+package models
+
+import (
+	"FASMS/utils"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// the near-miss analysis of an applicant failing a scheme, the smallest changes that would qualify them.
+// each criteria group failing the scheme's rule reports its closest criterias with the attributes that differ
+type NearMissAnalysis struct {
+	ApplicantID    string                  `json:"applicant_id"`
+	SchemeID       string                  `json:"scheme_id"`
+	Eligible       bool                    `json:"eligible"`
+	ReferenceDate  utils.Date              `json:"reference_date"`
+	CriteriaGroups []CriteriaGroupNearMiss `json:"criteria_groups"`
+	Relations      []SchemeRelationTrace   `json:"relations"`
+}
+
+// the criterias of the group needing the fewest changes, several when they are as close
+type CriteriaGroupNearMiss struct {
+	CriteriaGroupID string             `json:"criteria_group_id"`
+	IsHouseHold     bool               `json:"is_household"`
+	Closest         []CriteriaNearMiss `json:"closest"`
+}
+
+// how far a criteria is from being met by the applicant, by a household member or by the household as a whole.
+// changes counts the differing attributes, or the household members missing or in excess. eligible_on is the day
+// the criteria is met when only ages differ and time alone fixes them
+type CriteriaNearMiss struct {
+	CriteriaID  string          `json:"criteria_id"`
+	HouseholdID string          `json:"household_id,omitempty"`
+	Changes     int             `json:"changes"`
+	Differences []FieldMismatch `json:"differences"`
+	EligibleOn  *utils.Date     `json:"eligible_on,omitempty"`
+	Suggestion  string          `json:"suggestion"`
+}
+
+var relationNames = map[uint]string{
+	1: "child",
+	2: "spouse",
+	3: "parent",
+}
+
+var relationPluralNames = map[uint]string{
+	1: "children",
+	2: "spouses",
+	3: "parents",
+}
+
+type nearMissAnalyser struct {
+	applicant     Applicants
+	scheme        Schemes
+	ctx           EligibilityContext
+	referenceDate time.Time
+}
+
+// analyses the criteria groups the applicant fails the scheme on, an eligible applicant has nothing to analyse.
+// the failing relations are reported as they are, the applicant's applications decide them
+func AnalyseNearMiss(applicant Applicants, scheme Schemes, ctx EligibilityContext) NearMissAnalysis {
+	trace := ExplainEligibility(applicant, scheme, ctx)
+	analysis := NearMissAnalysis{
+		ApplicantID:    applicant.ID,
+		SchemeID:       scheme.ID,
+		Eligible:       trace.Eligible,
+		ReferenceDate:  trace.ReferenceDate,
+		CriteriaGroups: []CriteriaGroupNearMiss{},
+		Relations:      []SchemeRelationTrace{},
+	}
+	for _, relation := range trace.Relations {
+		if !relation.Satisfied {
+			analysis.Relations = append(analysis.Relations, relation)
+		}
+	}
+	if trace.Eligible || trace.Rule == nil {
+		return analysis
+	}
+
+	analyser := nearMissAnalyser{applicant: applicant, scheme: scheme, ctx: ctx, referenceDate: time.Time(trace.ReferenceDate)}
+	for _, groupID := range failingCriteriaGroups(*trace.Rule, []string{}) {
+		for _, group := range scheme.CriteriaGroups {
+			if group.ID == groupID {
+				analysis.CriteriaGroups = append(analysis.CriteriaGroups, analyser.analyseGroup(group))
+			}
+		}
+	}
+	return analysis
+}
+
+// the groups whose failure makes the rule fail. a NOT node fails on a group being satisfied,
+// no change is proposed for it
+func failingCriteriaGroups(node CriteriaNodeTrace, groupIDs []string) []string {
+	if node.Satisfied {
+		return groupIDs
+	}
+	switch node.Operator {
+	case CriteriaNodeGroup:
+		if node.CriteriaGroupID != nil && !slices.Contains(groupIDs, *node.CriteriaGroupID) {
+			groupIDs = append(groupIDs, *node.CriteriaGroupID)
+		}
+	case CriteriaNodeAnd, CriteriaNodeOr:
+		for _, child := range node.Children {
+			groupIDs = failingCriteriaGroups(child, groupIDs)
+		}
+	}
+	return groupIDs
+}
+
+func (n *nearMissAnalyser) analyseGroup(group CriteriaGroup) CriteriaGroupNearMiss {
+	groupNearMiss := CriteriaGroupNearMiss{CriteriaGroupID: group.ID, IsHouseHold: group.IsHouseHold(), Closest: []CriteriaNearMiss{}}
+	var nearMisses []CriteriaNearMiss
+	for _, criteria := range group.Criterias {
+		switch {
+		case !groupNearMiss.IsHouseHold:
+			nearMisses = append(nearMisses, n.analyseApplicantCriteria(criteria))
+		case criteria.GetCriteriaType() == CriteriaTypeMember:
+			nearMisses = append(nearMisses, n.analyseMemberCriteria(criteria))
+		default:
+			nearMisses = append(nearMisses, n.analyseCompositionCriteria(criteria))
+		}
+	}
+	sort.SliceStable(nearMisses, func(i, j int) bool { return nearMisses[i].closerThan(nearMisses[j]) })
+	for _, nearMiss := range nearMisses {
+		if nearMiss.Changes == nearMisses[0].Changes {
+			groupNearMiss.Closest = append(groupNearMiss.Closest, nearMiss)
+		}
+	}
+	return groupNearMiss
+}
+
+func (n *nearMissAnalyser) analyseApplicantCriteria(criteria Criterias) CriteriaNearMiss {
+	mismatches := matchApplicantCriteria(n.applicant, criteria, n.referenceDate)
+	nearMiss := CriteriaNearMiss{CriteriaID: criteria.ID, Changes: len(mismatches), Differences: mismatches}
+	if onlyTooYoung(mismatches, n.applicant.GetAgeAt(n.referenceDate), criteria) {
+		nearMiss.EligibleOn = n.ageReachedOn(n.applicant.DOB, criteria.AgeLowerLimit, func(referenceDate time.Time) bool {
+			return len(matchApplicantCriteria(n.applicant, criteria, referenceDate)) == 0
+		})
+	}
+	nearMiss.Suggestion = n.suggest("the applicant", n.applicant.DOB, criteria, nearMiss)
+	return nearMiss
+}
+
+// the closest household member to the criteria, a member already matching it is taken by another group.
+// adding a member matching the criteria is always one change away, an existing member is only closer
+// with fewer changes or when time alone fixes it
+func (n *nearMissAnalyser) analyseMemberCriteria(criteria Criterias) CriteriaNearMiss {
+	closest := CriteriaNearMiss{
+		CriteriaID:  criteria.ID,
+		Changes:     1,
+		Differences: []FieldMismatch{{Field: "household_member", Expected: "1 more" + describeMember(criteria, 1), Actual: "0"}},
+		Suggestion:  "needs one more household member" + describeMember(criteria, 1),
+	}
+	for _, household := range n.applicant.Households {
+		mismatches := matchHouseholdCriteria(household, criteria, n.referenceDate)
+		if len(mismatches) == 0 || len(mismatches) > closest.Changes {
+			continue
+		}
+		nearMiss := CriteriaNearMiss{CriteriaID: criteria.ID, HouseholdID: household.ID, Changes: len(mismatches), Differences: mismatches}
+		if onlyTooYoung(mismatches, household.GetAgeAt(n.referenceDate), criteria) {
+			nearMiss.EligibleOn = n.ageReachedOn(household.DOB, criteria.AgeLowerLimit, func(referenceDate time.Time) bool {
+				return len(matchHouseholdCriteria(household, criteria, referenceDate)) == 0
+			})
+		}
+		nearMiss.Suggestion = n.suggest(describeHousehold(household), household.DOB, criteria, nearMiss)
+		if nearMiss.closerThan(closest) {
+			closest = nearMiss
+		}
+	}
+	return closest
+}
+
+func (n *nearMissAnalyser) analyseCompositionCriteria(criteria Criterias) CriteriaNearMiss {
+	mismatches, matchedHouseholdIDs := matchCompositionCriteria(n.applicant, criteria, n.referenceDate)
+	nearMiss := CriteriaNearMiss{CriteriaID: criteria.ID, Changes: len(mismatches), Differences: mismatches}
+	if len(mismatches) == 0 {
+		return nearMiss
+	}
+
+	switch criteria.GetCriteriaType() {
+	case CriteriaTypeMemberCount:
+		count := uint32(len(matchedHouseholdIDs))
+		if count < criteria.MinCount {
+			nearMiss.Changes = int(criteria.MinCount - count)
+			nearMiss.EligibleOn = n.memberCountReachedOn(criteria)
+			nearMiss.Suggestion = fmt.Sprintf("needs %s%s", countMembers(nearMiss.Changes, "more"), describeMember(criteria, nearMiss.Changes))
+		} else {
+			nearMiss.Changes = int(count - *criteria.MaxCount)
+			nearMiss.Suggestion = fmt.Sprintf("needs %s%s", countMembers(nearMiss.Changes, "fewer"), describeMember(criteria, nearMiss.Changes))
+		}
+		if nearMiss.EligibleOn != nil {
+			nearMiss.Suggestion = fmt.Sprintf("eligible on %s once enough household members are old enough, or %s", time.Time(*nearMiss.EligibleOn).Format(utils.DateFormat), nearMiss.Suggestion)
+		}
+	case CriteriaTypeHouseholdSize:
+		size := uint32(len(n.applicant.Households) + 1)
+		if size < criteria.MinCount {
+			nearMiss.Changes = int(criteria.MinCount - size)
+			nearMiss.Suggestion = "needs " + countMembers(nearMiss.Changes, "more")
+		} else {
+			nearMiss.Changes = int(size - *criteria.MaxCount)
+			nearMiss.Suggestion = "needs " + countMembers(nearMiss.Changes, "fewer")
+		}
+	case CriteriaTypeRelationPresent:
+		nearMiss.Suggestion = "needs one more household member" + describeRelation(criteria.Relation, 1)
+	case CriteriaTypeRelationAbsent:
+		nearMiss.Changes = len(matchedHouseholdIDs)
+		nearMiss.Suggestion = "needs no household member" + describeRelation(criteria.Relation, 1)
+	default:
+		nearMiss.Suggestion = "the household needs " + describeDifferences(mismatches)
+	}
+	return nearMiss
+}
+
+// the first day the household has enough members matching the criteria, the members only too young for it
+// are tried in the order they become old enough
+func (n *nearMissAnalyser) memberCountReachedOn(criteria Criterias) *utils.Date {
+	var birthdays []time.Time
+	for _, household := range n.applicant.Households {
+		mismatches := matchHouseholdCriteria(household, criteria, n.referenceDate)
+		if onlyTooYoung(mismatches, household.GetAgeAt(n.referenceDate), criteria) {
+			birthdays = append(birthdays, birthday(household.DOB, criteria.AgeLowerLimit))
+		}
+	}
+	sort.Slice(birthdays, func(i, j int) bool { return birthdays[i].Before(birthdays[j]) })
+	for _, day := range birthdays {
+		if eligibleOn := n.referenceReachedOn(day, func(referenceDate time.Time) bool {
+			mismatches, _ := matchCompositionCriteria(n.applicant, criteria, referenceDate)
+			return len(mismatches) == 0
+		}); eligibleOn != nil {
+			return eligibleOn
+		}
+	}
+	return nil
+}
+
+// the first day someone born on dob is old enough for the criteria, nil when that is not enough to meet it
+func (n *nearMissAnalyser) ageReachedOn(dob time.Time, age uint32, matches func(referenceDate time.Time) bool) *utils.Date {
+	return n.referenceReachedOn(birthday(dob, age), matches)
+}
+
+// the first day the scheme's reference date reaches day, if the criteria matches then. nil when the reference
+// date does not move with time, a fixed date or the date of an application already made
+func (n *nearMissAnalyser) referenceReachedOn(day time.Time, matches func(referenceDate time.Time) bool) *utils.Date {
+	switch n.scheme.GetAgeReferenceType() {
+	case AgeReferenceFixedDate:
+		if n.scheme.AgeReferenceDate != nil {
+			return nil
+		}
+	case AgeReferenceStartOfYear:
+		if !day.Equal(utils.StartOfYear(day)) {
+			day = utils.StartOfYear(day).AddDate(1, 0, 0)
+		}
+	case AgeReferenceApplicationDate:
+		if n.ctx.ApplicationDate != nil {
+			return nil
+		}
+	}
+	if !matches(n.scheme.GetReferenceDate(EligibilityContext{Now: day})) {
+		return nil
+	}
+	eligibleOn := utils.Date(day)
+	return &eligibleOn
+}
+
+func (n *nearMissAnalyser) suggest(subject string, dob time.Time, criteria Criterias, nearMiss CriteriaNearMiss) string {
+	if nearMiss.EligibleOn == nil {
+		return fmt.Sprintf("%s needs %s", subject, describeDifferences(nearMiss.Differences))
+	}
+	turns := birthday(dob, criteria.AgeLowerLimit)
+	eligibleOn := time.Time(*nearMiss.EligibleOn)
+	if eligibleOn.Equal(turns) {
+		return fmt.Sprintf("eligible once %s turns %d on %s", subject, criteria.AgeLowerLimit, turns.Format(utils.DateFormat))
+	}
+	return fmt.Sprintf("eligible on %s, %s turns %d on %s", eligibleOn.Format(utils.DateFormat), subject, criteria.AgeLowerLimit, turns.Format(utils.DateFormat))
+}
+
+// fewer changes first, then the ones time alone fixes, the earliest first
+func (m *CriteriaNearMiss) closerThan(other CriteriaNearMiss) bool {
+	if m.Changes != other.Changes {
+		return m.Changes < other.Changes
+	}
+	if m.EligibleOn == nil || other.EligibleOn == nil {
+		return m.EligibleOn != nil
+	}
+	return time.Time(*m.EligibleOn).Before(time.Time(*other.EligibleOn))
+}
+
+// the age is the only difference and the person is younger than the criteria's lower limit
+func onlyTooYoung(mismatches []FieldMismatch, age uint32, criteria Criterias) bool {
+	return len(mismatches) == 1 && mismatches[0].Field == "age" && age < criteria.AgeLowerLimit
+}
+
+// the day someone born on dob turns age, someone born on 29 Feb turns a year older on 1 Mar in non leap years
+func birthday(dob time.Time, age uint32) time.Time {
+	return utils.StartOfDay(dob.AddDate(int(age), 0, 0))
+}
+
+func describeHousehold(household Households) string {
+	if name, ok := relationNames[household.Relation]; ok {
+		return "the " + name
+	}
+	return "the household member"
+}
+
+// the relation of count household members
+func describeRelation(relation uint, count int) string {
+	if count != 1 {
+		if name, ok := relationPluralNames[relation]; ok {
+			return " who are " + name
+		}
+		return ""
+	}
+	if name, ok := relationNames[relation]; ok {
+		return " who is a " + name
+	}
+	return ""
+}
+
+// the relation and the age of the count household members the criteria asks for
+func describeMember(criteria Criterias, count int) string {
+	description := describeRelation(criteria.Relation, count)
+	switch {
+	case criteria.AgeLowerLimit > 0 && criteria.AgeUpperLimit < 999:
+		description += fmt.Sprintf(" aged %d to %d", criteria.AgeLowerLimit, criteria.AgeUpperLimit)
+	case criteria.AgeLowerLimit > 0:
+		description += fmt.Sprintf(" aged %d or above", criteria.AgeLowerLimit)
+	case criteria.AgeUpperLimit < 999:
+		description += fmt.Sprintf(" aged %d or below", criteria.AgeUpperLimit)
+	}
+	return description
+}
+
+func countMembers(count int, comparison string) string {
+	if count == 1 {
+		return fmt.Sprintf("1 %s household member", comparison)
+	}
+	return fmt.Sprintf("%d %s household members", count, comparison)
+}
+
+func describeDifferences(mismatches []FieldMismatch) string {
+	var differences []string
+	for _, mismatch := range mismatches {
+		differences = append(differences, fmt.Sprintf("%s %s instead of %s", mismatch.Field, mismatch.Expected, mismatch.Actual))
+	}
+	return strings.Join(differences, ", ")
+}
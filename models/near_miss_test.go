@@ -0,0 +1,286 @@
+package models
+
+import (
+	"FASMS/utils"
+	"testing"
+	"time"
+)
+
+// a household member who is a child aged 7 or above
+func olderChildCriteria(id string) Criterias {
+	criteria := anyCriteria(id)
+	criteria.IsHouseHold = true
+	criteria.Relation = 1
+	criteria.AgeLowerLimit = 7
+	return criteria
+}
+
+// the closest criteria of the only failing group of the scheme
+func closestNearMiss(t *testing.T, applicant Applicants, scheme Schemes, ctx EligibilityContext) CriteriaNearMiss {
+	t.Helper()
+	analysis := AnalyseNearMiss(applicant, scheme, ctx)
+	if analysis.Eligible {
+		t.Fatalf("AnalyseNearMiss eligible, want not eligible")
+	}
+	if len(analysis.CriteriaGroups) != 1 || len(analysis.CriteriaGroups[0].Closest) == 0 {
+		t.Fatalf("AnalyseNearMiss criteria groups = %+v, want one group with a closest criteria", analysis.CriteriaGroups)
+	}
+	return analysis.CriteriaGroups[0].Closest[0]
+}
+
+func assertEligibleOn(t *testing.T, got *utils.Date, want *time.Time) {
+	t.Helper()
+	switch {
+	case want == nil && got != nil:
+		t.Fatalf("EligibleOn = %s, want nil", time.Time(*got).Format(utils.DateFormat))
+	case want != nil && got == nil:
+		t.Fatalf("EligibleOn = nil, want %s", want.Format(utils.DateFormat))
+	case want != nil && !time.Time(*got).Equal(*want):
+		t.Fatalf("EligibleOn = %s, want %s", time.Time(*got).Format(utils.DateFormat), want.Format(utils.DateFormat))
+	}
+}
+
+func TestNearMissEligibleOn(t *testing.T) {
+	pinNow(t, eligibilityNow)
+	fixedDate := date(2026, time.January, 1)
+	eligibleOn := func(year int, month time.Month, day int) *time.Time {
+		eligibleOn := date(year, month, day)
+		return &eligibleOn
+	}
+	// the child turns 7 on 2 Mar 2027
+	sixYearOld := child("child", date(2020, time.March, 2))
+
+	tests := []struct {
+		name           string
+		referenceType  uint
+		referenceDate  *time.Time
+		ctx            EligibilityContext
+		criteria       Criterias
+		households     []Households
+		wantEligibleOn *time.Time
+		wantSuggestion string
+	}{
+		{
+			name:           "evaluation date",
+			referenceType:  AgeReferenceEvaluationDate,
+			ctx:            NewEligibilityContext(),
+			criteria:       olderChildCriteria("child"),
+			households:     []Households{sixYearOld},
+			wantEligibleOn: eligibleOn(2027, time.March, 2),
+			wantSuggestion: "eligible once the child turns 7 on 2027-03-02",
+		},
+		{
+			name:           "no reference type is the evaluation date",
+			ctx:            NewEligibilityContext(),
+			criteria:       olderChildCriteria("child"),
+			households:     []Households{sixYearOld},
+			wantEligibleOn: eligibleOn(2027, time.March, 2),
+			wantSuggestion: "eligible once the child turns 7 on 2027-03-02",
+		},
+		{
+			name:           "fixed date does not move with time",
+			referenceType:  AgeReferenceFixedDate,
+			referenceDate:  &fixedDate,
+			ctx:            NewEligibilityContext(),
+			criteria:       olderChildCriteria("child"),
+			households:     []Households{sixYearOld},
+			wantSuggestion: "needs one more household member who is a child aged 7 or above",
+		},
+		{
+			name:           "fixed date without a date is the evaluation date",
+			referenceType:  AgeReferenceFixedDate,
+			ctx:            NewEligibilityContext(),
+			criteria:       olderChildCriteria("child"),
+			households:     []Households{sixYearOld},
+			wantEligibleOn: eligibleOn(2027, time.March, 2),
+			wantSuggestion: "eligible once the child turns 7 on 2027-03-02",
+		},
+		{
+			name:           "start of year rounds up to the next year",
+			referenceType:  AgeReferenceStartOfYear,
+			ctx:            NewEligibilityContext(),
+			criteria:       olderChildCriteria("child"),
+			households:     []Households{sixYearOld},
+			wantEligibleOn: eligibleOn(2028, time.January, 1),
+			wantSuggestion: "eligible on 2028-01-01, the child turns 7 on 2027-03-02",
+		},
+		{
+			name:           "start of year on a birthday on 1 Jan",
+			referenceType:  AgeReferenceStartOfYear,
+			ctx:            NewEligibilityContext(),
+			criteria:       olderChildCriteria("child"),
+			households:     []Households{child("child", date(2020, time.January, 1))},
+			wantEligibleOn: eligibleOn(2027, time.January, 1),
+			wantSuggestion: "eligible once the child turns 7 on 2027-01-01",
+		},
+		{
+			name:           "application date of an application already made",
+			referenceType:  AgeReferenceApplicationDate,
+			ctx:            NewApplicationEligibilityContext(date(2026, time.October, 1)),
+			criteria:       olderChildCriteria("child"),
+			households:     []Households{sixYearOld},
+			wantSuggestion: "needs one more household member who is a child aged 7 or above",
+		},
+		{
+			name:           "application date outside of an application is the evaluation date",
+			referenceType:  AgeReferenceApplicationDate,
+			ctx:            NewEligibilityContext(),
+			criteria:       olderChildCriteria("child"),
+			households:     []Households{sixYearOld},
+			wantEligibleOn: eligibleOn(2027, time.March, 2),
+			wantSuggestion: "eligible once the child turns 7 on 2027-03-02",
+		},
+		{
+			name:           "born on 29 Feb turns 7 on 1 Mar of a non leap year",
+			referenceType:  AgeReferenceEvaluationDate,
+			ctx:            NewEligibilityContext(),
+			criteria:       olderChildCriteria("child"),
+			households:     []Households{child("child", date(2020, time.February, 29))},
+			wantEligibleOn: eligibleOn(2027, time.March, 1),
+			wantSuggestion: "eligible once the child turns 7 on 2027-03-01",
+		},
+		{
+			name:           "a member too old is not fixed by time",
+			referenceType:  AgeReferenceEvaluationDate,
+			ctx:            NewEligibilityContext(),
+			criteria:       childCriteria("child"),
+			households:     []Households{child("teenager", date(2010, time.January, 1))},
+			wantSuggestion: "needs one more household member who is a child aged 11 or below",
+		},
+		{
+			name:           "the applicant",
+			referenceType:  AgeReferenceEvaluationDate,
+			ctx:            NewEligibilityContext(),
+			criteria:       seniorCriteria(),
+			wantEligibleOn: eligibleOn(2051, time.January, 1),
+			wantSuggestion: "eligible once the applicant turns 65 on 2051-01-01",
+		},
+		{
+			name:           "the applicant at a fixed date",
+			referenceType:  AgeReferenceFixedDate,
+			referenceDate:  &fixedDate,
+			ctx:            NewEligibilityContext(),
+			criteria:       seniorCriteria(),
+			wantSuggestion: "the applicant needs age 65-999 instead of 40",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scheme := Schemes{
+				ID:               "scheme",
+				AgeReferenceType: tt.referenceType,
+				AgeReferenceDate: tt.referenceDate,
+				CriteriaGroups:   []CriteriaGroup{group("group", tt.criteria)},
+			}
+			nearMiss := closestNearMiss(t, testApplicant(tt.households...), scheme, tt.ctx)
+			assertEligibleOn(t, nearMiss.EligibleOn, tt.wantEligibleOn)
+			if nearMiss.Suggestion != tt.wantSuggestion {
+				t.Fatalf("Suggestion = %q, want %q", nearMiss.Suggestion, tt.wantSuggestion)
+			}
+		})
+	}
+}
+
+func TestNearMissMemberCount(t *testing.T) {
+	pinNow(t, eligibilityNow)
+	fixedDate := date(2026, time.January, 1)
+	count := func(n uint32) *uint32 { return &n }
+	eligibleOn := func(year int, month time.Month, day int) *time.Time {
+		eligibleOn := date(year, month, day)
+		return &eligibleOn
+	}
+	memberCount := func(minCount uint32, maxCount *uint32) Criterias {
+		criteria := olderChildCriteria("children")
+		criteria.CriteriaType = CriteriaTypeMemberCount
+		criteria.MinCount = minCount
+		criteria.MaxCount = maxCount
+		return criteria
+	}
+	// turning 7 on 2 Mar 2027 and on 10 May 2028
+	sixYearOld := child("six", date(2020, time.March, 2))
+	fiveYearOld := child("five", date(2021, time.May, 10))
+	tenYearOld := child("ten", date(2016, time.April, 4))
+	elevenYearOld := child("eleven", date(2015, time.August, 8))
+
+	tests := []struct {
+		name           string
+		referenceType  uint
+		referenceDate  *time.Time
+		criteria       Criterias
+		households     []Households
+		wantChanges    int
+		wantEligibleOn *time.Time
+		wantSuggestion string
+	}{
+		{
+			name:           "eligible once the last child needed is old enough",
+			referenceType:  AgeReferenceEvaluationDate,
+			criteria:       memberCount(2, nil),
+			households:     []Households{fiveYearOld, sixYearOld},
+			wantChanges:    2,
+			wantEligibleOn: eligibleOn(2028, time.May, 10),
+			wantSuggestion: "eligible on 2028-05-10 once enough household members are old enough, or needs 2 more household members who are children aged 7 or above",
+		},
+		{
+			name:           "eligible once the first child is old enough",
+			referenceType:  AgeReferenceEvaluationDate,
+			criteria:       memberCount(2, nil),
+			households:     []Households{tenYearOld, sixYearOld},
+			wantChanges:    1,
+			wantEligibleOn: eligibleOn(2027, time.March, 2),
+			wantSuggestion: "eligible on 2027-03-02 once enough household members are old enough, or needs 1 more household member who is a child aged 7 or above",
+		},
+		{
+			name:           "start of year rounds up each birthday",
+			referenceType:  AgeReferenceStartOfYear,
+			criteria:       memberCount(2, nil),
+			households:     []Households{fiveYearOld, sixYearOld},
+			wantChanges:    2,
+			wantEligibleOn: eligibleOn(2029, time.January, 1),
+			wantSuggestion: "eligible on 2029-01-01 once enough household members are old enough, or needs 2 more household members who are children aged 7 or above",
+		},
+		{
+			name:           "fixed date does not move with time",
+			referenceType:  AgeReferenceFixedDate,
+			referenceDate:  &fixedDate,
+			criteria:       memberCount(2, nil),
+			households:     []Households{fiveYearOld, sixYearOld},
+			wantChanges:    2,
+			wantSuggestion: "needs 2 more household members who are children aged 7 or above",
+		},
+		{
+			name:           "not enough children even once old enough",
+			referenceType:  AgeReferenceEvaluationDate,
+			criteria:       memberCount(2, nil),
+			households:     []Households{sixYearOld},
+			wantChanges:    2,
+			wantSuggestion: "needs 2 more household members who are children aged 7 or above",
+		},
+		{
+			name:           "too many children",
+			referenceType:  AgeReferenceEvaluationDate,
+			criteria:       memberCount(0, count(1)),
+			households:     []Households{tenYearOld, elevenYearOld, sixYearOld},
+			wantChanges:    1,
+			wantSuggestion: "needs 1 fewer household member who is a child aged 7 or above",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scheme := Schemes{
+				ID:               "scheme",
+				AgeReferenceType: tt.referenceType,
+				AgeReferenceDate: tt.referenceDate,
+				CriteriaGroups:   []CriteriaGroup{group("group", tt.criteria)},
+			}
+			nearMiss := closestNearMiss(t, testApplicant(tt.households...), scheme, NewEligibilityContext())
+			if nearMiss.Changes != tt.wantChanges {
+				t.Fatalf("Changes = %d, want %d", nearMiss.Changes, tt.wantChanges)
+			}
+			assertEligibleOn(t, nearMiss.EligibleOn, tt.wantEligibleOn)
+			if nearMiss.Suggestion != tt.wantSuggestion {
+				t.Fatalf("Suggestion = %q, want %q", nearMiss.Suggestion, tt.wantSuggestion)
+			}
+		})
+	}
+}
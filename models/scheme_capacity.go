@@ -0,0 +1,66 @@
+package models
+
+import (
+	"FASMS/utils"
+	"time"
+)
+
+// the budget the schedules commit, every planned instalment. a schedule without planned instalments commits nothing,
+// the schemes with a budget only have schedules with planned instalments
+func ScheduleCommitment(schedules []BenefitSchedules) utils.Money {
+	var commitment utils.Money
+	for _, schedule := range schedules {
+		commitment += schedule.Amount.Mul(int64(schedule.Instalments))
+	}
+	return commitment
+}
+
+// the budget an application of the applicant would commit if it was approved at approvedAt
+func EstimateCommitment(applicant Applicants, scheme Schemes, ctx EligibilityContext, approvedAt time.Time) (utils.Money, error) {
+	eligibility := ExplainEligibility(applicant, scheme, ctx)
+	entitlement, err := ComputeEntitlement(applicant, scheme, eligibility, ctx)
+	if err != nil {
+		return 0, err
+	}
+	return ScheduleCommitment(NewBenefitSchedules(Applications{}, scheme, entitlement, approvedAt)), nil
+}
+
+// the budget committed by the instalments the schedule has not materialised yet
+func (s *BenefitSchedules) RemainingCommitment() utils.Money {
+	if s.Status == BenefitScheduleStatusCompleted || s.Instalments < s.NextInstalment {
+		return 0
+	}
+	return s.Amount.Mul(int64(s.Instalments - s.NextInstalment + 1))
+}
+
+// whether the scheme can take one more beneficiary, if newBeneficiary, committing the amount
+func (s *Schemes) HasCapacity(commitment utils.Money, newBeneficiary bool) bool {
+	if newBeneficiary && s.MaxBeneficiaries != nil && s.Beneficiaries >= *s.MaxBeneficiaries {
+		return false
+	}
+	return s.Budget == nil || s.BudgetCommitted+commitment <= *s.Budget
+}
+
+// nil when the scheme has no budget
+func (s *Schemes) RemainingBudget() *utils.Money {
+	if s.Budget == nil {
+		return nil
+	}
+	remaining := *s.Budget - s.BudgetCommitted
+	if remaining < 0 {
+		remaining = 0
+	}
+	return &remaining
+}
+
+// nil when the scheme has no maximum number of beneficiaries
+func (s *Schemes) RemainingBeneficiaries() *uint {
+	if s.MaxBeneficiaries == nil {
+		return nil
+	}
+	var remaining uint
+	if *s.MaxBeneficiaries > s.Beneficiaries {
+		remaining = *s.MaxBeneficiaries - s.Beneficiaries
+	}
+	return &remaining
+}
@@ -0,0 +1,79 @@
+package models
+
+import (
+	"FASMS/utils"
+	"testing"
+)
+
+func TestHasCapacity(t *testing.T) {
+	budget := func(amount utils.Money) *utils.Money { return &amount }
+	quota := func(max uint) *uint { return &max }
+
+	tests := []struct {
+		name           string
+		scheme         Schemes
+		commitment     utils.Money
+		newBeneficiary bool
+		want           bool
+	}{
+		{name: "no budget and no quota", scheme: Schemes{BudgetCommitted: 1000000_00, Beneficiaries: 1000}, commitment: 1000_00, newBeneficiary: true, want: true},
+		{name: "budget fits exactly", scheme: Schemes{Budget: budget(1000_00), BudgetCommitted: 600_00}, commitment: 400_00, want: true},
+		{name: "budget a cent short", scheme: Schemes{Budget: budget(1000_00), BudgetCommitted: 600_01}, commitment: 400_00, want: false},
+		{name: "nothing committed on a full budget", scheme: Schemes{Budget: budget(1000_00), BudgetCommitted: 1000_00}, commitment: 0, want: true},
+		{name: "quota with a place left", scheme: Schemes{MaxBeneficiaries: quota(3), Beneficiaries: 2}, newBeneficiary: true, want: true},
+		{name: "quota reached", scheme: Schemes{MaxBeneficiaries: quota(3), Beneficiaries: 3}, newBeneficiary: true, want: false},
+		{name: "quota reached without a new beneficiary", scheme: Schemes{MaxBeneficiaries: quota(3), Beneficiaries: 3}, commitment: 100_00, want: true},
+		{name: "quota of 0", scheme: Schemes{MaxBeneficiaries: quota(0)}, newBeneficiary: true, want: false},
+		{name: "quota left but budget short", scheme: Schemes{Budget: budget(1000_00), BudgetCommitted: 900_00, MaxBeneficiaries: quota(3), Beneficiaries: 1}, commitment: 200_00, newBeneficiary: true, want: false},
+		{name: "budget left but quota reached", scheme: Schemes{Budget: budget(1000_00), MaxBeneficiaries: quota(1), Beneficiaries: 1}, commitment: 200_00, newBeneficiary: true, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.scheme.HasCapacity(tt.commitment, tt.newBeneficiary); got != tt.want {
+				t.Fatalf("HasCapacity(%s, %t) = %t, want %t", tt.commitment, tt.newBeneficiary, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRemainingCommitment(t *testing.T) {
+	tests := []struct {
+		name     string
+		schedule BenefitSchedules
+		want     utils.Money
+	}{
+		{name: "nothing materialised", schedule: BenefitSchedules{Amount: 100_00, Instalments: 6, NextInstalment: 1, Status: BenefitScheduleStatusActive}, want: 600_00},
+		{name: "some instalments materialised", schedule: BenefitSchedules{Amount: 100_00, Instalments: 6, NextInstalment: 5, Status: BenefitScheduleStatusActive}, want: 200_00},
+		{name: "last instalment left", schedule: BenefitSchedules{Amount: 100_00, Instalments: 6, NextInstalment: 6, Status: BenefitScheduleStatusActive}, want: 100_00},
+		{name: "every instalment materialised", schedule: BenefitSchedules{Amount: 100_00, Instalments: 6, NextInstalment: 7, Status: BenefitScheduleStatusActive}, want: 0},
+		{name: "completed", schedule: BenefitSchedules{Amount: 100_00, Instalments: 6, NextInstalment: 3, Status: BenefitScheduleStatusCompleted}, want: 0},
+		{name: "no planned instalments", schedule: BenefitSchedules{Amount: 100_00, NextInstalment: 1, Status: BenefitScheduleStatusActive}, want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.schedule.RemainingCommitment(); got != tt.want {
+				t.Fatalf("RemainingCommitment = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScheduleCommitment(t *testing.T) {
+	tests := []struct {
+		name      string
+		schedules []BenefitSchedules
+		want      utils.Money
+	}{
+		{name: "no schedules", want: 0},
+		{name: "one-off", schedules: []BenefitSchedules{{Amount: 250_00, Instalments: 1}}, want: 250_00},
+		{name: "one-off and monthly", schedules: []BenefitSchedules{{Amount: 250_00, Instalments: 1}, {Amount: 100_00, Instalments: 12}}, want: 1450_00},
+		{name: "no planned instalments commits nothing", schedules: []BenefitSchedules{{Amount: 100_00}}, want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ScheduleCommitment(tt.schedules); got != tt.want {
+				t.Fatalf("ScheduleCommitment = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
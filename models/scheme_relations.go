@@ -0,0 +1,173 @@
+package models
+
+import (
+	"FASMS/utils"
+	"fmt"
+	"time"
+)
+
+// relation types between two schemes, checked against the applicant's applications
+const (
+	SchemeRelationExcludes         uint = 1
+	SchemeRelationRequiresApproved uint = 2
+	SchemeRelationNotAppliedWithin uint = 3
+)
+
+// a rule of a scheme on another scheme, e.g. a top-up scheme requiring the base scheme, evaluated with the criteria.
+// an exclusion is declared by the scheme it applies to, mutually exclusive schemes declare it on both
+type SchemeRelations struct {
+	ID              string  `json:"id" gorm:"primaryKey"`
+	SchemeID        string  `json:"scheme_id" gorm:"index;not null"`
+	Scheme          Schemes `json:"-" gorm:"foreignKey:SchemeID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE"`
+	RelatedSchemeID string  `json:"related_scheme_id" gorm:"index;not null"`
+	RelationType    uint    `json:"relation_type" gorm:"not null;comment:'1: excludes, 2: requires approved, 3: requires not applied within months'"`
+	Months          uint    `json:"months" gorm:"comment:'the period of a requires not applied within relation'"`
+	CommonTime
+}
+
+type CreateSchemeRelationRequest struct {
+	RelatedSchemeID string `json:"related_scheme_id" binding:"required"`
+	RelationType    uint   `json:"relation_type" binding:"required,oneof=1 2 3"`
+	Months          uint   `json:"months"`
+}
+
+type SchemeRelationsResponse struct {
+	RelatedSchemeID string `json:"related_scheme_id"`
+	RelationType    uint   `json:"relation_type"`
+	Months          uint   `json:"months"`
+}
+
+// the outcome of one relation, with the applications it was decided by
+type SchemeRelationTrace struct {
+	RelatedSchemeID string   `json:"related_scheme_id"`
+	RelationType    uint     `json:"relation_type"`
+	Satisfied       bool     `json:"satisfied"`
+	Reason          string   `json:"reason"`
+	ApplicationIDs  []string `json:"application_ids,omitempty"`
+}
+
+// an application the applicant holds or may still be granted
+func (a *Applications) isActive() bool {
+	switch a.ApplicationStatus {
+	case ApplicationStatusRejected, ApplicationStatusWithdrawn, ApplicationStatusClosed:
+		return false
+	}
+	return true
+}
+
+func (a *Applications) isApproved() bool {
+	return a.ApplicationStatus == ApplicationStatusApproved || a.ApplicationStatus == ApplicationStatusDisbursed
+}
+
+// checks the relation against the applicant's applications as of at, the applications made after at are ignored
+func (r *SchemeRelations) check(applications []Applications, at time.Time) SchemeRelationTrace {
+	trace := SchemeRelationTrace{RelatedSchemeID: r.RelatedSchemeID, RelationType: r.RelationType}
+	related := []Applications{}
+	for _, application := range applications {
+		if application.SchemeID == r.RelatedSchemeID && !application.CreatedAt.After(at) {
+			related = append(related, application)
+		}
+	}
+
+	switch r.RelationType {
+	case SchemeRelationExcludes:
+		for _, application := range related {
+			if application.isActive() {
+				trace.ApplicationIDs = append(trace.ApplicationIDs, application.ID)
+			}
+		}
+		trace.Satisfied = len(trace.ApplicationIDs) == 0
+		if trace.Satisfied {
+			trace.Reason = fmt.Sprintf("no active application for the excluded scheme %s", r.RelatedSchemeID)
+		} else {
+			trace.Reason = fmt.Sprintf("the scheme can not be held together with scheme %s, the applicant has an active application for it", r.RelatedSchemeID)
+		}
+	case SchemeRelationRequiresApproved:
+		for _, application := range related {
+			if application.isApproved() {
+				trace.ApplicationIDs = append(trace.ApplicationIDs, application.ID)
+			}
+		}
+		trace.Satisfied = len(trace.ApplicationIDs) > 0
+		if trace.Satisfied {
+			trace.Reason = fmt.Sprintf("the applicant holds the required scheme %s", r.RelatedSchemeID)
+		} else {
+			trace.Reason = fmt.Sprintf("the scheme requires an approved application for scheme %s", r.RelatedSchemeID)
+		}
+	case SchemeRelationNotAppliedWithin:
+		since := utils.AddMonths(at, -int(r.Months))
+		for _, application := range related {
+			if application.CreatedAt.After(since) {
+				trace.ApplicationIDs = append(trace.ApplicationIDs, application.ID)
+			}
+		}
+		trace.Satisfied = len(trace.ApplicationIDs) == 0
+		if trace.Satisfied {
+			trace.Reason = fmt.Sprintf("no application for scheme %s since %s", r.RelatedSchemeID, since.Format(utils.DateFormat))
+		} else {
+			trace.Reason = fmt.Sprintf("the scheme requires no application for scheme %s within %d months, the applicant applied for it after %s", r.RelatedSchemeID, r.Months, since.Format(utils.DateFormat))
+		}
+	}
+	return trace
+}
+
+// checks the relations of the scheme against the applicant's applications as of at, without the criteria.
+// the applicant's applications have to be loaded with the applicant
+func CheckSchemeRelations(applicant Applicants, scheme Schemes, at time.Time) (bool, []SchemeRelationTrace) {
+	satisfied := true
+	traces := []SchemeRelationTrace{}
+	for _, relation := range scheme.Relations {
+		trace := relation.check(applicant.Applications, at)
+		traces = append(traces, trace)
+		satisfied = satisfied && trace.Satisfied
+	}
+	return satisfied, traces
+}
+
+func (r *CreateSchemeRelationRequest) ConvertToModel(schemeID string) SchemeRelations {
+	return SchemeRelations{
+		ID:              utils.GenerateUUID(),
+		SchemeID:        schemeID,
+		RelatedSchemeID: r.RelatedSchemeID,
+		RelationType:    r.RelationType,
+		Months:          r.Months,
+	}
+}
+
+func (r *SchemeRelations) ConvertToResponse() SchemeRelationsResponse {
+	return SchemeRelationsResponse{
+		RelatedSchemeID: r.RelatedSchemeID,
+		RelationType:    r.RelationType,
+		Months:          r.Months,
+	}
+}
+
+func (r *SchemeRelations) toRequest() CreateSchemeRelationRequest {
+	return CreateSchemeRelationRequest{
+		RelatedSchemeID: r.RelatedSchemeID,
+		RelationType:    r.RelationType,
+		Months:          r.Months,
+	}
+}
+
+// a scheme has at most one relation of each type to another scheme, and can not both exclude and require it
+func (s *CreateSchemesRequest) validateRelations() error {
+	relationTypes := make(map[string]map[uint]bool)
+	for _, relation := range s.Relations {
+		if relation.RelationType == SchemeRelationNotAppliedWithin && relation.Months == 0 {
+			return fmt.Errorf("relation to scheme %s: a requires not applied within relation must have months", relation.RelatedSchemeID)
+		}
+		if relationTypes[relation.RelatedSchemeID] == nil {
+			relationTypes[relation.RelatedSchemeID] = make(map[uint]bool)
+		}
+		types := relationTypes[relation.RelatedSchemeID]
+		if types[relation.RelationType] {
+			return fmt.Errorf("relation to scheme %s: the relation is defined more than once", relation.RelatedSchemeID)
+		}
+		types[relation.RelationType] = true
+		if types[SchemeRelationExcludes] && types[SchemeRelationRequiresApproved] {
+			return fmt.Errorf("relation to scheme %s: a scheme can not both exclude and require another scheme", relation.RelatedSchemeID)
+		}
+	}
+	return nil
+}
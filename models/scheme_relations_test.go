@@ -0,0 +1,170 @@
+package models
+
+import (
+	"FASMS/utils"
+	"strings"
+	"testing"
+	"time"
+)
+
+func relatedApplication(id string, status uint, createdAt time.Time) Applications {
+	return Applications{ID: id, SchemeID: "related", ApplicationStatus: status, CommonTime: CommonTime{CreatedAt: createdAt}}
+}
+
+func TestSchemeRelationCheck(t *testing.T) {
+	// checked as of 31 Aug 2026, 6 months back is 28 Feb 2026 as February has no 31st
+	at := time.Date(2026, time.August, 31, 12, 0, 0, 0, time.UTC)
+	since := time.Date(2026, time.February, 28, 12, 0, 0, 0, time.UTC)
+	before := at.AddDate(0, -1, 0)
+	excludes := SchemeRelations{RelatedSchemeID: "related", RelationType: SchemeRelationExcludes}
+	requiresApproved := SchemeRelations{RelatedSchemeID: "related", RelationType: SchemeRelationRequiresApproved}
+	notAppliedWithin := SchemeRelations{RelatedSchemeID: "related", RelationType: SchemeRelationNotAppliedWithin, Months: 6}
+
+	tests := []struct {
+		name               string
+		relation           SchemeRelations
+		applications       []Applications
+		want               bool
+		wantApplicationIDs []string
+	}{
+		{name: "excludes without application", relation: excludes, want: true},
+		{name: "excludes a submitted application", relation: excludes, applications: []Applications{relatedApplication("submitted", ApplicationStatusSubmitted, before)}, want: false, wantApplicationIDs: []string{"submitted"}},
+		{name: "excludes a waitlisted application", relation: excludes, applications: []Applications{relatedApplication("waitlisted", ApplicationStatusWaitlisted, before)}, want: false, wantApplicationIDs: []string{"waitlisted"}},
+		{name: "excludes an approved application", relation: excludes, applications: []Applications{relatedApplication("approved", ApplicationStatusApproved, before)}, want: false, wantApplicationIDs: []string{"approved"}},
+		{name: "excludes a disbursed application", relation: excludes, applications: []Applications{relatedApplication("disbursed", ApplicationStatusDisbursed, before)}, want: false, wantApplicationIDs: []string{"disbursed"}},
+		{name: "excludes ignores rejected, withdrawn and closed applications", relation: excludes, applications: []Applications{
+			relatedApplication("rejected", ApplicationStatusRejected, before),
+			relatedApplication("withdrawn", ApplicationStatusWithdrawn, before),
+			relatedApplication("closed", ApplicationStatusClosed, before),
+		}, want: true},
+		{name: "excludes ignores the applications of other schemes", relation: excludes, applications: []Applications{{ID: "other", SchemeID: "other", ApplicationStatus: ApplicationStatusApproved, CommonTime: CommonTime{CreatedAt: before}}}, want: true},
+		{name: "excludes ignores the applications made after at", relation: excludes, applications: []Applications{relatedApplication("later", ApplicationStatusSubmitted, at.Add(time.Second))}, want: true},
+		{name: "excludes an application made at at", relation: excludes, applications: []Applications{relatedApplication("same-time", ApplicationStatusSubmitted, at)}, want: false, wantApplicationIDs: []string{"same-time"}},
+		{name: "requires approved without application", relation: requiresApproved, want: false},
+		{name: "requires approved with an approved application", relation: requiresApproved, applications: []Applications{relatedApplication("approved", ApplicationStatusApproved, before)}, want: true, wantApplicationIDs: []string{"approved"}},
+		{name: "requires approved with a disbursed application", relation: requiresApproved, applications: []Applications{relatedApplication("disbursed", ApplicationStatusDisbursed, before)}, want: true, wantApplicationIDs: []string{"disbursed"}},
+		{name: "requires approved with a submitted application", relation: requiresApproved, applications: []Applications{relatedApplication("submitted", ApplicationStatusSubmitted, before)}, want: false},
+		{name: "requires approved with rejected, withdrawn and closed applications", relation: requiresApproved, applications: []Applications{
+			relatedApplication("rejected", ApplicationStatusRejected, before),
+			relatedApplication("withdrawn", ApplicationStatusWithdrawn, before),
+			relatedApplication("closed", ApplicationStatusClosed, before),
+		}, want: false},
+		{name: "requires approved ignores an approval made after at", relation: requiresApproved, applications: []Applications{relatedApplication("later", ApplicationStatusApproved, at.Add(time.Second))}, want: false},
+		{name: "not applied within without application", relation: notAppliedWithin, want: true},
+		{name: "not applied within, applied on the first day of the period", relation: notAppliedWithin, applications: []Applications{relatedApplication("on-since", ApplicationStatusRejected, since)}, want: true},
+		{name: "not applied within, applied just after the start of the period", relation: notAppliedWithin, applications: []Applications{relatedApplication("after-since", ApplicationStatusRejected, since.Add(time.Second))}, want: false, wantApplicationIDs: []string{"after-since"}},
+		{name: "not applied within, applied before the period", relation: notAppliedWithin, applications: []Applications{relatedApplication("long-ago", ApplicationStatusApproved, since.AddDate(0, 0, -1))}, want: true},
+		{name: "not applied within counts every status", relation: notAppliedWithin, applications: []Applications{relatedApplication("withdrawn", ApplicationStatusWithdrawn, before)}, want: false, wantApplicationIDs: []string{"withdrawn"}},
+		{name: "not applied within ignores the applications made after at", relation: notAppliedWithin, applications: []Applications{relatedApplication("later", ApplicationStatusSubmitted, at.Add(time.Second))}, want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			trace := tt.relation.check(tt.applications, at)
+			if trace.Satisfied != tt.want {
+				t.Fatalf("check satisfied = %t, want %t: %s", trace.Satisfied, tt.want, trace.Reason)
+			}
+			if strings.Join(trace.ApplicationIDs, ",") != strings.Join(tt.wantApplicationIDs, ",") {
+				t.Fatalf("check application ids = %v, want %v", trace.ApplicationIDs, tt.wantApplicationIDs)
+			}
+			if trace.RelatedSchemeID != "related" || trace.RelationType != tt.relation.RelationType || trace.Reason == "" {
+				t.Fatalf("check trace = %+v", trace)
+			}
+		})
+	}
+}
+
+func TestCheckSchemeRelations(t *testing.T) {
+	at := time.Date(2026, time.August, 31, 12, 0, 0, 0, time.UTC)
+	base := Applications{ID: "base", SchemeID: "base", ApplicationStatus: ApplicationStatusApproved, CommonTime: CommonTime{CreatedAt: at.AddDate(0, -2, 0)}}
+	exclusive := Applications{ID: "exclusive", SchemeID: "exclusive", ApplicationStatus: ApplicationStatusWithdrawn, CommonTime: CommonTime{CreatedAt: at.AddDate(0, -1, 0)}}
+	scheme := Schemes{ID: "top-up", Relations: []SchemeRelations{
+		{RelatedSchemeID: "base", RelationType: SchemeRelationRequiresApproved},
+		{RelatedSchemeID: "exclusive", RelationType: SchemeRelationExcludes},
+	}}
+
+	satisfied, traces := CheckSchemeRelations(Applicants{Applications: []Applications{base, exclusive}}, scheme, at)
+	if !satisfied || len(traces) != 2 {
+		t.Fatalf("CheckSchemeRelations = %t, %+v, want satisfied with 2 traces", satisfied, traces)
+	}
+
+	// the base application is withdrawn and the exclusive one reopened since
+	base.ApplicationStatus = ApplicationStatusWithdrawn
+	exclusive.ApplicationStatus = ApplicationStatusSubmitted
+	satisfied, traces = CheckSchemeRelations(Applicants{Applications: []Applications{base, exclusive}}, scheme, at)
+	if satisfied || len(traces) != 2 || traces[0].Satisfied || traces[1].Satisfied {
+		t.Fatalf("CheckSchemeRelations = %t, %+v, want both relations unsatisfied", satisfied, traces)
+	}
+}
+
+func TestValidateRelations(t *testing.T) {
+	tests := []struct {
+		name      string
+		relations []CreateSchemeRelationRequest
+		wantErr   string
+	}{
+		{name: "no relations"},
+		{name: "one relation of each type to different schemes", relations: []CreateSchemeRelationRequest{
+			{RelatedSchemeID: "a", RelationType: SchemeRelationExcludes},
+			{RelatedSchemeID: "b", RelationType: SchemeRelationRequiresApproved},
+			{RelatedSchemeID: "c", RelationType: SchemeRelationNotAppliedWithin, Months: 12},
+		}},
+		{name: "requires approved and not applied within the same scheme", relations: []CreateSchemeRelationRequest{
+			{RelatedSchemeID: "a", RelationType: SchemeRelationRequiresApproved},
+			{RelatedSchemeID: "a", RelationType: SchemeRelationNotAppliedWithin, Months: 12},
+		}},
+		{name: "not applied within without months", relations: []CreateSchemeRelationRequest{
+			{RelatedSchemeID: "a", RelationType: SchemeRelationNotAppliedWithin},
+		}, wantErr: "must have months"},
+		{name: "the same relation twice", relations: []CreateSchemeRelationRequest{
+			{RelatedSchemeID: "a", RelationType: SchemeRelationExcludes},
+			{RelatedSchemeID: "a", RelationType: SchemeRelationExcludes},
+		}, wantErr: "more than once"},
+		{name: "excludes and requires the same scheme", relations: []CreateSchemeRelationRequest{
+			{RelatedSchemeID: "a", RelationType: SchemeRelationRequiresApproved},
+			{RelatedSchemeID: "a", RelationType: SchemeRelationExcludes},
+		}, wantErr: "both exclude and require"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scheme := CreateSchemesRequest{Relations: tt.relations}
+			err := scheme.validateRelations()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("validateRelations error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("validateRelations error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCheckEligibilityRequestRelations(t *testing.T) {
+	pinNow(t, eligibilityNow)
+	// applied in the top-up scheme 3 months ago, the base scheme's application is dated today
+	threeMonthsAgo := utils.Date(eligibilityNow.AddDate(0, -3, 0))
+	request := CheckEligibilityRequest{Applications: []CheckEligibilityApplication{
+		{SchemeID: "base", ApplicationStatus: ApplicationStatusApproved},
+		{SchemeID: "top-up", ApplicationStatus: ApplicationStatusRejected, AppliedAt: &threeMonthsAgo},
+	}}
+	applicant := request.ConvertToModel()
+	if len(applicant.Applications) != 2 || applicant.Applications[0].ApplicantID != applicant.ID || !applicant.Applications[0].CreatedAt.Equal(eligibilityNow) {
+		t.Fatalf("ConvertToModel applications = %+v, want the 2 held applications of the applicant", applicant.Applications)
+	}
+
+	scheme := Schemes{ID: "top-up", Relations: []SchemeRelations{{RelatedSchemeID: "base", RelationType: SchemeRelationRequiresApproved}}}
+	if satisfied, traces := CheckSchemeRelations(applicant, scheme, eligibilityNow); !satisfied {
+		t.Fatalf("CheckSchemeRelations = %+v, want the approved base application to satisfy the relation", traces)
+	}
+	scheme.Relations = append(scheme.Relations, SchemeRelations{RelatedSchemeID: "top-up", RelationType: SchemeRelationNotAppliedWithin, Months: 6})
+	if satisfied, _ := CheckSchemeRelations(applicant, scheme, eligibilityNow); satisfied {
+		t.Fatalf("CheckSchemeRelations satisfied, want the top-up application of 3 months ago to fail the 6 months relation")
+	}
+
+	// without held applications a relation requiring another scheme is not satisfied
+	if satisfied, _ := CheckSchemeRelations((&CheckEligibilityRequest{}).ConvertToModel(), scheme, eligibilityNow); satisfied {
+		t.Fatalf("CheckSchemeRelations satisfied without applications, want not satisfied")
+	}
+}
@@ -0,0 +1,143 @@
+package models
+
+import (
+	"FASMS/utils"
+	"time"
+)
+
+// the consequences of replacing a scheme's definition, computed without writing anything
+type SchemeSimulationResponse struct {
+	SchemeID     string             `json:"scheme_id"`
+	EvaluatedAt  time.Time          `json:"evaluated_at"`
+	Applicants   ApplicantsImpact   `json:"applicants"`
+	Applications ApplicationsImpact `json:"applications"`
+	Budget       BudgetImpact       `json:"budget"`
+}
+
+// every registered applicant evaluated today against the current and the proposed definition
+type ApplicantsImpact struct {
+	Evaluated      int `json:"evaluated"`
+	EligibleBefore int `json:"eligible_before"`
+	EligibleAfter  int `json:"eligible_after"`
+	Gained         int `json:"gained"`
+	Lost           int `json:"lost"`
+}
+
+// the active applications of the scheme evaluated as of their application date
+type ApplicationsImpact struct {
+	Evaluated                  int                 `json:"evaluated"`
+	Gained                     int                 `json:"gained"`
+	Lost                       int                 `json:"lost"`
+	ApprovedBecomingIneligible []ApplicationImpact `json:"approved_becoming_ineligible"`
+}
+
+type ApplicationImpact struct {
+	ApplicationID     string `json:"application_id"`
+	ApplicantID       string `json:"applicant_id"`
+	ApplicationStatus uint   `json:"application_status"`
+}
+
+// what the active applications would commit if they were approved today under each definition,
+// an application not eligible under a definition commits nothing
+type BudgetImpact struct {
+	Currency           string       `json:"currency"`
+	CurrentBudget      *utils.Money `json:"current_budget"`
+	ProposedBudget     *utils.Money `json:"proposed_budget"`
+	CurrentCommitment  utils.Money  `json:"current_commitment"`
+	ProposedCommitment utils.Money  `json:"proposed_commitment"`
+	Delta              utils.Money  `json:"delta"`
+}
+
+// the scheme the definition would make, keeping the identity and status of the existing scheme
+// so that its relations and applications are evaluated as if the definition was published
+func (s *CreateSchemesRequest) ConvertToSimulation(existing Schemes) Schemes {
+	proposed := s.ConvertToModel()
+	proposed.ID = existing.ID
+	proposed.Status = existing.Status
+	proposed.CurrentVersion = existing.CurrentVersion
+	proposed.BudgetCommitted = existing.BudgetCommitted
+	proposed.Beneficiaries = existing.Beneficiaries
+	return proposed
+}
+
+func NewSchemeSimulation(current Schemes, proposed Schemes, evaluatedAt time.Time) SchemeSimulationResponse {
+	return SchemeSimulationResponse{
+		SchemeID:    current.ID,
+		EvaluatedAt: evaluatedAt,
+		Applications: ApplicationsImpact{
+			ApprovedBecomingIneligible: []ApplicationImpact{},
+		},
+		Budget: BudgetImpact{
+			Currency:       proposed.currency(),
+			CurrentBudget:  current.Budget,
+			ProposedBudget: proposed.Budget,
+		},
+	}
+}
+
+// records an applicant eligible before and after the change or not
+func (i *ApplicantsImpact) Add(before bool, after bool) {
+	i.Evaluated++
+	if before {
+		i.EligibleBefore++
+	}
+	if after {
+		i.EligibleAfter++
+	}
+	if !before && after {
+		i.Gained++
+	}
+	if before && !after {
+		i.Lost++
+	}
+}
+
+// evaluates an application of the scheme under both definitions, the applications no longer active are skipped
+func (r *SchemeSimulationResponse) AddApplication(application Applications, current Schemes, proposed Schemes) error {
+	if !application.isActive() {
+		return nil
+	}
+	ctx := NewApplicationEligibilityContext(application.CreatedAt)
+	before := CheckEligiblity(application.Applicant, current, ctx)
+	after := CheckEligiblity(application.Applicant, proposed, ctx)
+
+	r.Applications.Evaluated++
+	if !before && after {
+		r.Applications.Gained++
+	}
+	if before && !after {
+		r.Applications.Lost++
+		if application.isApproved() {
+			r.Applications.ApprovedBecomingIneligible = append(r.Applications.ApprovedBecomingIneligible, ApplicationImpact{
+				ApplicationID:     application.ID,
+				ApplicantID:       application.ApplicantID,
+				ApplicationStatus: application.ApplicationStatus,
+			})
+		}
+	}
+
+	if before {
+		commitment, err := EstimateCommitment(application.Applicant, current, ctx, r.EvaluatedAt)
+		if err != nil {
+			return err
+		}
+		r.Budget.CurrentCommitment += commitment
+	}
+	if after {
+		commitment, err := EstimateCommitment(application.Applicant, proposed, ctx, r.EvaluatedAt)
+		if err != nil {
+			return err
+		}
+		r.Budget.ProposedCommitment += commitment
+	}
+	r.Budget.Delta = r.Budget.ProposedCommitment - r.Budget.CurrentCommitment
+	return nil
+}
+
+// the currency of the scheme's benefits, they all share one
+func (s *Schemes) currency() string {
+	for _, benefit := range s.Benefits {
+		return benefit.GetCurrency()
+	}
+	return utils.DefaultCurrency
+}
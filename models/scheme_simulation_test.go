@@ -0,0 +1,127 @@
+package models
+
+import (
+	"FASMS/utils"
+	"testing"
+	"time"
+)
+
+func TestApplicantsImpactAdd(t *testing.T) {
+	var impact ApplicantsImpact
+	for _, outcome := range []struct{ before, after bool }{
+		{before: true, after: true},
+		{before: true, after: false},
+		{before: false, after: true},
+		{before: false, after: true},
+		{before: false, after: false},
+	} {
+		impact.Add(outcome.before, outcome.after)
+	}
+	want := ApplicantsImpact{Evaluated: 5, EligibleBefore: 2, EligibleAfter: 3, Gained: 2, Lost: 1}
+	if impact != want {
+		t.Fatalf("ApplicantsImpact = %+v, want %+v", impact, want)
+	}
+}
+
+func TestSchemeSimulationAddApplication(t *testing.T) {
+	pinNow(t, eligibilityNow)
+	appliedAt := eligibilityNow.AddDate(0, -1, 0)
+	// the current definition pays 500.00 once to unemployed applicants,
+	// the proposed one pays 200.00 a month for 3 months for each child under 12, whatever the employment
+	current := Schemes{
+		ID:             "scheme",
+		CriteriaGroups: []CriteriaGroup{group("unemployed", unemployedCriteria())},
+		Benefits:       []Benefits{{ID: "one-off", Name: "one-off", Amount: 500_00, Frequency: BenefitFrequencyOneOff}},
+	}
+	proposed := Schemes{
+		ID:             "scheme",
+		CriteriaGroups: []CriteriaGroup{group("children", childCriteria("child"))},
+		Benefits:       []Benefits{{ID: "per-child", Name: "per child", Amount: 200_00, AmountType: BenefitAmountPerMatchedMember, Frequency: BenefitFrequencyMonthly, Instalments: 3}},
+	}
+	application := func(id string, status uint, applicant Applicants) Applications {
+		applicant.ID = id + "-applicant"
+		return Applications{ID: id, ApplicantID: applicant.ID, Applicant: applicant, SchemeID: "scheme", ApplicationStatus: status, CommonTime: CommonTime{CreatedAt: appliedAt}}
+	}
+	employed := func(applicant Applicants) Applicants {
+		applicant.EmploymentStatus = 2
+		return applicant
+	}
+	youngChild := func(id string) Households { return child(id, date(2018, time.May, 1)) }
+
+	applications := []Applications{
+		// eligible before only, approved
+		application("childless", ApplicationStatusApproved, testApplicant()),
+		// eligible after only
+		application("employed-parent", ApplicationStatusSubmitted, employed(testApplicant(youngChild("child")))),
+		// eligible under both, for 2 children after
+		application("parent", ApplicationStatusDisbursed, testApplicant(youngChild("first"), youngChild("second"))),
+		// eligible before only, not approved
+		application("waiting", ApplicationStatusWaitlisted, testApplicant()),
+		// no longer active, skipped
+		application("rejected", ApplicationStatusRejected, testApplicant()),
+	}
+
+	simulation := NewSchemeSimulation(current, proposed, eligibilityNow)
+	for _, application := range applications {
+		if err := simulation.AddApplication(application, current, proposed); err != nil {
+			t.Fatalf("AddApplication(%s) error = %v", application.ID, err)
+		}
+	}
+
+	impact := simulation.Applications
+	if impact.Evaluated != 4 || impact.Gained != 1 || impact.Lost != 2 {
+		t.Fatalf("applications impact = %+v, want 4 evaluated, 1 gained and 2 lost", impact)
+	}
+	if len(impact.ApprovedBecomingIneligible) != 1 || impact.ApprovedBecomingIneligible[0] != (ApplicationImpact{ApplicationID: "childless", ApplicantID: "childless-applicant", ApplicationStatus: ApplicationStatusApproved}) {
+		t.Fatalf("approved becoming ineligible = %+v, want the childless application", impact.ApprovedBecomingIneligible)
+	}
+
+	// before: 3 applications eligible for 500.00, after: 1 child and 2 children for 3 months of 200.00
+	budget := simulation.Budget
+	if budget.CurrentCommitment != 1500_00 || budget.ProposedCommitment != 1800_00 || budget.Delta != 300_00 {
+		t.Fatalf("budget impact = %+v, want 1500.00 committed before, 1800.00 after and a delta of 300.00", budget)
+	}
+	if budget.Currency != utils.DefaultCurrency {
+		t.Fatalf("budget currency = %s, want %s", budget.Currency, utils.DefaultCurrency)
+	}
+}
+
+func TestSchemeSimulationBudgetDeltaCanBeNegative(t *testing.T) {
+	pinNow(t, eligibilityNow)
+	current := Schemes{ID: "scheme", Benefits: []Benefits{{ID: "one-off", Amount: 500_00, Frequency: BenefitFrequencyOneOff}}}
+	proposed := Schemes{ID: "scheme", Benefits: []Benefits{{ID: "one-off", Amount: 300_00, Frequency: BenefitFrequencyOneOff}}}
+	application := Applications{ID: "application", Applicant: testApplicant(), ApplicationStatus: ApplicationStatusApproved, CommonTime: CommonTime{CreatedAt: eligibilityNow}}
+
+	simulation := NewSchemeSimulation(current, proposed, eligibilityNow)
+	if err := simulation.AddApplication(application, current, proposed); err != nil {
+		t.Fatalf("AddApplication error = %v", err)
+	}
+	if simulation.Applications.Gained != 0 || simulation.Applications.Lost != 0 || simulation.Budget.Delta != -200_00 {
+		t.Fatalf("simulation = %+v, want no change in eligibility and a delta of -200.00", simulation)
+	}
+}
+
+func TestConvertToSimulation(t *testing.T) {
+	budget := utils.Money(10000_00)
+	isHouseHold := false
+	request := CreateSchemesRequest{
+		Name:   "proposed",
+		Budget: &budget,
+		CriteriaGroups: []CreateCriteriaGroupsRequest{{Criterias: []CreateCriteriaRequest{
+			{EmploymentStatus: 1, MaritalStatus: 99, Sex: 99, Relation: 99, AgeUpperLimit: 999, IsHouseHold: &isHouseHold},
+		}}},
+		Benefits: []CreateBenefitRequest{{Name: "one-off", Amount: 500_00}},
+	}
+	existing := Schemes{ID: "scheme", Status: SchemeStatusPublished, CurrentVersion: 3, BudgetCommitted: 2500_00, Beneficiaries: 5}
+
+	proposed := request.ConvertToSimulation(existing)
+	if proposed.ID != "scheme" || proposed.Status != SchemeStatusPublished || proposed.CurrentVersion != 3 {
+		t.Fatalf("ConvertToSimulation = %+v, want the identity of the existing scheme", proposed)
+	}
+	if proposed.BudgetCommitted != 2500_00 || proposed.Beneficiaries != 5 {
+		t.Fatalf("ConvertToSimulation counters = %s, %d, want the counters of the existing scheme", proposed.BudgetCommitted, proposed.Beneficiaries)
+	}
+	if proposed.Budget == nil || *proposed.Budget != budget || proposed.Name != "proposed" || len(proposed.CriteriaGroups) != 1 || len(proposed.Benefits) != 1 {
+		t.Fatalf("ConvertToSimulation = %+v, want the proposed definition", proposed)
+	}
+}
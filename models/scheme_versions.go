@@ -0,0 +1,312 @@
+package models
+
+import (
+	"FASMS/utils"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"time"
+)
+
+const (
+	SchemeVersionDraft      uint = 1
+	SchemeVersionPublished  uint = 2
+	SchemeVersionSuperseded uint = 3
+	// the version waits for a policy owner to publish it, or to reject it back to draft
+	SchemeVersionPendingApproval uint = 4
+)
+
+// a numbered version of a scheme's definition, only the draft version can be edited,
+// it is submitted for approval and publishing applies the definition to the scheme and freezes the version
+type SchemeVersions struct {
+	ID          string     `json:"id" gorm:"primaryKey"`
+	SchemeID    string     `json:"scheme_id" gorm:"uniqueIndex:idx_scheme_versions_scheme_version;not null"`
+	Scheme      Schemes    `json:"-" gorm:"foreignKey:SchemeID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE"`
+	Version     uint       `json:"version" gorm:"uniqueIndex:idx_scheme_versions_scheme_version;not null"`
+	Status      uint       `json:"status" gorm:"comment:'1: draft, 2: published, 3: superseded, 4: pending approval'"`
+	Definition  string     `json:"definition" gorm:"type:jsonb;comment:'the scheme as a create scheme request, with the ids of its criteria groups, criterias and benefits'"`
+	PublishedAt *time.Time `json:"published_at"`
+	CommonTime
+}
+
+type GetSchemeVersionsDiffRequest struct {
+	From uint `form:"from" binding:"required"`
+	To   uint `form:"to" binding:"required"`
+}
+
+type SchemeVersionsResponse struct {
+	ID          string               `json:"id"`
+	SchemeID    string               `json:"scheme_id"`
+	Version     uint                 `json:"version"`
+	Status      uint                 `json:"status"`
+	Definition  CreateSchemesRequest `json:"definition"`
+	PublishedAt *time.Time           `json:"published_at"`
+	CreatedAt   time.Time            `json:"created_at"`
+}
+
+type SchemeVersionsDiffResponse struct {
+	SchemeID string                `json:"scheme_id"`
+	From     uint                  `json:"from"`
+	To       uint                  `json:"to"`
+	Changes  []SchemeVersionChange `json:"changes"`
+}
+
+// a single changed value, path is the json path of the value with list items keyed by their id,
+// relations by related_scheme_id:relation_type, and rule nodes referring to their criteria group by criteria_group_id
+type SchemeVersionChange struct {
+	Path   string      `json:"path"`
+	Change string      `json:"change"`
+	From   interface{} `json:"from"`
+	To     interface{} `json:"to"`
+}
+
+func NewSchemeVersion(schemeID string, version uint, status uint, definition CreateSchemesRequest) (SchemeVersions, error) {
+	schemeVersion := SchemeVersions{
+		ID:       utils.GenerateUUID(),
+		SchemeID: schemeID,
+		Version:  version,
+		Status:   status,
+	}
+	if err := schemeVersion.SetDefinition(definition); err != nil {
+		return SchemeVersions{}, err
+	}
+	if status == SchemeVersionPublished {
+		publishedAt := utils.Now()
+		schemeVersion.PublishedAt = &publishedAt
+	}
+	return schemeVersion, nil
+}
+
+func (v *SchemeVersions) SetDefinition(definition CreateSchemesRequest) error {
+	encoded, err := json.Marshal(definition)
+	if err != nil {
+		return err
+	}
+	v.Definition = string(encoded)
+	return nil
+}
+
+func (v *SchemeVersions) GetDefinition() (CreateSchemesRequest, error) {
+	var definition CreateSchemesRequest
+	err := json.Unmarshal([]byte(v.Definition), &definition)
+	return definition, err
+}
+
+func (v *SchemeVersions) ConvertToResponse() SchemeVersionsResponse {
+	// the definition was marshalled from a CreateSchemesRequest, so it always unmarshals
+	definition, _ := v.GetDefinition()
+	return SchemeVersionsResponse{
+		ID:          v.ID,
+		SchemeID:    v.SchemeID,
+		Version:     v.Version,
+		Status:      v.Status,
+		Definition:  definition,
+		PublishedAt: v.PublishedAt,
+		CreatedAt:   v.CreatedAt,
+	}
+}
+
+// the definition of the scheme as stored in its tables, with all the ids so that it can be applied back
+func (s *Schemes) ToDefinition() (CreateSchemesRequest, error) {
+	definition := CreateSchemesRequest{
+		Name:             s.Name,
+		AgeReferenceType: s.GetAgeReferenceType(),
+		AgeReferenceDate: utils.NewDatePtr(s.AgeReferenceDate),
+		ApplicationOpen:  utils.NewDatePtr(s.ApplicationOpen),
+		ApplicationClose: utils.NewDatePtr(s.ApplicationClose),
+		EffectiveFrom:    utils.NewDatePtr(s.EffectiveFrom),
+		EffectiveTo:      utils.NewDatePtr(s.EffectiveTo),
+		Budget:           s.Budget,
+		MaxBeneficiaries: s.MaxBeneficiaries,
+		CriteriaGroups:   []CreateCriteriaGroupsRequest{},
+		Benefits:         []CreateBenefitRequest{},
+		Relations:        []CreateSchemeRelationRequest{},
+	}
+
+	groupIndexes := make(map[string]int)
+	for i, group := range s.CriteriaGroups {
+		groupIndexes[group.ID] = i
+		groupRequest := CreateCriteriaGroupsRequest{ID: group.ID, Criterias: []CreateCriteriaRequest{}}
+		for _, criteria := range group.Criterias {
+			isHouseHold := criteria.IsHouseHold
+			groupRequest.Criterias = append(groupRequest.Criterias, CreateCriteriaRequest{
+				ID:               criteria.ID,
+				EmploymentStatus: criteria.EmploymentStatus,
+				MaritalStatus:    criteria.MaritalStatus,
+				Sex:              criteria.Sex,
+				AgeUpperLimit:    criteria.AgeUpperLimit,
+				AgeLowerLimit:    criteria.AgeLowerLimit,
+				Relation:         criteria.Relation,
+				IsHouseHold:      &isHouseHold,
+				CriteriaType:     criteria.GetCriteriaType(),
+				MinCount:         criteria.MinCount,
+				MaxCount:         criteria.MaxCount,
+				MaxMonthlyIncome: criteria.MaxMonthlyIncome,
+				MaxAssets:        criteria.MaxAssets,
+				Expression:       criteria.Expression,
+			})
+		}
+		definition.CriteriaGroups = append(definition.CriteriaGroups, groupRequest)
+	}
+
+	if len(s.CriteriaNodes) > 0 {
+		tree := s.GetCriteriaTree()
+		rule := tree.toRequest(groupIndexes)
+		definition.Rule = &rule
+	}
+
+	for _, benefit := range s.Benefits {
+		benefitRequest, err := benefit.toRequest()
+		if err != nil {
+			return CreateSchemesRequest{}, err
+		}
+		definition.Benefits = append(definition.Benefits, benefitRequest)
+	}
+	for _, relation := range s.Relations {
+		definition.Relations = append(definition.Relations, relation.toRequest())
+	}
+	return definition, nil
+}
+
+func (n *CriteriaNode) toRequest(groupIndexes map[string]int) CreateCriteriaNodeRequest {
+	nodeRequest := CreateCriteriaNodeRequest{Operator: n.Operator}
+	if n.CriteriaGroupID != nil {
+		groupIndex := groupIndexes[*n.CriteriaGroupID]
+		nodeRequest.GroupIndex = &groupIndex
+	}
+	for _, child := range n.Children {
+		nodeRequest.Children = append(nodeRequest.Children, child.toRequest(groupIndexes))
+	}
+	return nodeRequest
+}
+
+// gives every new criteria group, criteria and benefit of the definition an id,
+// so that the same ids are used when the version is published and can be compared between versions
+func (s *CreateSchemesRequest) AssignIDs() {
+	for i := range s.CriteriaGroups {
+		if s.CriteriaGroups[i].ID == "" {
+			s.CriteriaGroups[i].ID = utils.GenerateUUID()
+		}
+		for j := range s.CriteriaGroups[i].Criterias {
+			if s.CriteriaGroups[i].Criterias[j].ID == "" {
+				s.CriteriaGroups[i].Criterias[j].ID = utils.GenerateUUID()
+			}
+		}
+	}
+	for i := range s.Benefits {
+		if s.Benefits[i].ID == "" {
+			s.Benefits[i].ID = utils.GenerateUUID()
+		}
+	}
+}
+
+// compares the definitions of two versions value by value
+func DiffSchemeVersions(from SchemeVersions, to SchemeVersions) (SchemeVersionsDiffResponse, error) {
+	diff := SchemeVersionsDiffResponse{
+		SchemeID: from.SchemeID,
+		From:     from.Version,
+		To:       to.Version,
+		Changes:  []SchemeVersionChange{},
+	}
+	fromValues, err := flattenDefinition(from.Definition)
+	if err != nil {
+		return diff, err
+	}
+	toValues, err := flattenDefinition(to.Definition)
+	if err != nil {
+		return diff, err
+	}
+
+	for path, fromValue := range fromValues {
+		toValue, exists := toValues[path]
+		if !exists {
+			diff.Changes = append(diff.Changes, SchemeVersionChange{Path: path, Change: "removed", From: fromValue})
+		} else if !reflect.DeepEqual(fromValue, toValue) {
+			diff.Changes = append(diff.Changes, SchemeVersionChange{Path: path, Change: "changed", From: fromValue, To: toValue})
+		}
+	}
+	for path, toValue := range toValues {
+		if _, exists := fromValues[path]; !exists {
+			diff.Changes = append(diff.Changes, SchemeVersionChange{Path: path, Change: "added", To: toValue})
+		}
+	}
+	sort.Slice(diff.Changes, func(i, j int) bool { return diff.Changes[i].Path < diff.Changes[j].Path })
+	return diff, nil
+}
+
+func flattenDefinition(definition string) (map[string]interface{}, error) {
+	var decoded interface{}
+	if err := json.Unmarshal([]byte(definition), &decoded); err != nil {
+		return nil, err
+	}
+	if object, ok := decoded.(map[string]interface{}); ok {
+		resolveGroupIndexes(object["rule"], object["criteria_groups"])
+	}
+	values := make(map[string]interface{})
+	flattenValue("", decoded, values)
+	return values, nil
+}
+
+// the nodes of the rule refer to the criteria groups by their position, they are compared by the id of the group
+// instead so that reordering the groups is not reported as a change of every node
+func resolveGroupIndexes(node interface{}, groups interface{}) {
+	object, ok := node.(map[string]interface{})
+	if !ok {
+		return
+	}
+	groupList, _ := groups.([]interface{})
+	if index, ok := object["group_index"].(float64); ok && int(index) >= 0 && int(index) < len(groupList) {
+		if group, ok := groupList[int(index)].(map[string]interface{}); ok {
+			if id, ok := group["id"].(string); ok && id != "" {
+				delete(object, "group_index")
+				object["criteria_group_id"] = id
+			}
+		}
+	}
+	children, _ := object["children"].([]interface{})
+	for _, child := range children {
+		resolveGroupIndexes(child, groups)
+	}
+}
+
+// objects in a list are keyed by their id when they have one, so that reordering is not reported as a change
+func flattenValue(path string, value interface{}, values map[string]interface{}) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, child := range v {
+			if key == "id" {
+				continue
+			}
+			childPath := key
+			if path != "" {
+				childPath = path + "." + key
+			}
+			flattenValue(childPath, child, values)
+		}
+	case []interface{}:
+		for i, child := range v {
+			flattenValue(fmt.Sprintf("%s[%s]", path, listItemKey(i, child)), child, values)
+		}
+	default:
+		values[path] = v
+	}
+}
+
+// the key of a list item: its id, the related scheme and type of a relation, which has no id
+// and is unique by both, otherwise its position
+func listItemKey(position int, item interface{}) string {
+	object, ok := item.(map[string]interface{})
+	if !ok {
+		return fmt.Sprintf("%d", position)
+	}
+	if id, ok := object["id"].(string); ok && id != "" {
+		return id
+	}
+	relatedSchemeID, hasRelatedScheme := object["related_scheme_id"].(string)
+	relationType, hasRelationType := object["relation_type"].(float64)
+	if hasRelatedScheme && hasRelationType {
+		return fmt.Sprintf("%s:%d", relatedSchemeID, int(relationType))
+	}
+	return fmt.Sprintf("%d", position)
+}
@@ -0,0 +1,146 @@
+package models
+
+import (
+	"FASMS/utils"
+	"strings"
+	"testing"
+)
+
+func versionDefinition(groups []CreateCriteriaGroupsRequest, rule *CreateCriteriaNodeRequest, benefits []CreateBenefitRequest, relations []CreateSchemeRelationRequest) CreateSchemesRequest {
+	return CreateSchemesRequest{Name: "scheme", CriteriaGroups: groups, Rule: rule, Benefits: benefits, Relations: relations}
+}
+
+func groupNode(index int) CreateCriteriaNodeRequest {
+	return CreateCriteriaNodeRequest{Operator: CriteriaNodeGroup, GroupIndex: &index}
+}
+
+func TestDiffSchemeVersions(t *testing.T) {
+	isHouseHold := false
+	adult := CreateCriteriaRequest{ID: "adult", EmploymentStatus: 99, MaritalStatus: 99, Sex: 99, Relation: 99, AgeLowerLimit: 18, AgeUpperLimit: 65, IsHouseHold: &isHouseHold}
+	unemployed := CreateCriteriaRequest{ID: "unemployed", EmploymentStatus: 1, MaritalStatus: 99, Sex: 99, Relation: 99, AgeUpperLimit: 999, IsHouseHold: &isHouseHold}
+	olderAdult := adult
+	olderAdult.AgeUpperLimit = 70
+	adults := CreateCriteriaGroupsRequest{ID: "adults", Criterias: []CreateCriteriaRequest{adult}}
+	unemployedGroup := CreateCriteriaGroupsRequest{ID: "unemployed-group", Criterias: []CreateCriteriaRequest{unemployed}}
+	oneOff := CreateBenefitRequest{ID: "one-off", Name: "one-off", Amount: 500_00}
+	monthly := CreateBenefitRequest{ID: "monthly", Name: "monthly", Amount: 100_00, Frequency: BenefitFrequencyMonthly, Instalments: 6}
+	requiresBase := CreateSchemeRelationRequest{RelatedSchemeID: "base", RelationType: SchemeRelationRequiresApproved}
+	excludesOther := CreateSchemeRelationRequest{RelatedSchemeID: "other", RelationType: SchemeRelationExcludes}
+	notAppliedWithin := func(months uint) CreateSchemeRelationRequest {
+		return CreateSchemeRelationRequest{RelatedSchemeID: "base", RelationType: SchemeRelationNotAppliedWithin, Months: months}
+	}
+	and := func(children ...CreateCriteriaNodeRequest) *CreateCriteriaNodeRequest {
+		return &CreateCriteriaNodeRequest{Operator: CriteriaNodeAnd, Children: children}
+	}
+
+	from := versionDefinition(
+		[]CreateCriteriaGroupsRequest{adults, unemployedGroup},
+		and(groupNode(0), groupNode(1)),
+		[]CreateBenefitRequest{oneOff},
+		[]CreateSchemeRelationRequest{requiresBase, excludesOther, notAppliedWithin(6)},
+	)
+
+	tests := []struct {
+		name    string
+		to      CreateSchemesRequest
+		want    map[string]string
+		wantAll bool
+	}{
+		{name: "same definition", to: from, want: map[string]string{}, wantAll: true},
+		{
+			name: "reordered groups, benefits and relations",
+			to: versionDefinition(
+				[]CreateCriteriaGroupsRequest{unemployedGroup, adults},
+				and(groupNode(1), groupNode(0)),
+				[]CreateBenefitRequest{oneOff},
+				[]CreateSchemeRelationRequest{notAppliedWithin(6), excludesOther, requiresBase},
+			),
+			want:    map[string]string{},
+			wantAll: true,
+		},
+		{
+			name: "rule refers to other groups",
+			to: versionDefinition(
+				[]CreateCriteriaGroupsRequest{adults, unemployedGroup},
+				and(groupNode(1), groupNode(1)),
+				[]CreateBenefitRequest{oneOff},
+				[]CreateSchemeRelationRequest{requiresBase, excludesOther, notAppliedWithin(6)},
+			),
+			want:    map[string]string{"rule.children[0].criteria_group_id": "changed"},
+			wantAll: true,
+		},
+		{
+			name: "added, removed and changed values",
+			to: versionDefinition(
+				[]CreateCriteriaGroupsRequest{unemployedGroup, {ID: "adults", Criterias: []CreateCriteriaRequest{olderAdult}}},
+				and(groupNode(1), groupNode(0)),
+				[]CreateBenefitRequest{oneOff, monthly},
+				[]CreateSchemeRelationRequest{notAppliedWithin(12), requiresBase},
+			),
+			want: map[string]string{
+				"criteria_groups[adults].criterias[adult].age_upper_limit": "changed",
+				"benefits[monthly].amount":                                 "added",
+				"benefits[monthly].instalments":                            "added",
+				"relations[other:1].related_scheme_id":                     "removed",
+				"relations[other:1].relation_type":                         "removed",
+				"relations[base:3].months":                                 "changed",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fromVersion, err := NewSchemeVersion("scheme", 1, SchemeVersionSuperseded, from)
+			if err != nil {
+				t.Fatalf("NewSchemeVersion error = %v", err)
+			}
+			toVersion, err := NewSchemeVersion("scheme", 2, SchemeVersionDraft, tt.to)
+			if err != nil {
+				t.Fatalf("NewSchemeVersion error = %v", err)
+			}
+			diff, err := DiffSchemeVersions(fromVersion, toVersion)
+			if err != nil {
+				t.Fatalf("DiffSchemeVersions error = %v", err)
+			}
+
+			changes := make(map[string]string)
+			for _, change := range diff.Changes {
+				changes[change.Path] = change.Change
+				if strings.HasPrefix(change.Path, "rule") && !strings.HasSuffix(change.Path, "criteria_group_id") {
+					t.Errorf("change %s of a rule node, want the nodes compared by their criteria group", change.Path)
+				}
+				if strings.HasPrefix(change.Path, "relations[base:2]") || strings.HasPrefix(change.Path, "benefits[one-off]") {
+					t.Errorf("change %s of an item only moved, want none", change.Path)
+				}
+			}
+			for path, change := range tt.want {
+				if changes[path] != change {
+					t.Errorf("change of %s = %q, want %q", path, changes[path], change)
+				}
+			}
+			if tt.wantAll && len(changes) != len(tt.want) {
+				t.Errorf("changes = %+v, want only %v", diff.Changes, tt.want)
+			}
+		})
+	}
+}
+
+func TestDiffSchemeVersionsValues(t *testing.T) {
+	from, _ := NewSchemeVersion("scheme", 1, SchemeVersionSuperseded, CreateSchemesRequest{Name: "before", Budget: func() *utils.Money { budget := utils.Money(1000_00); return &budget }()})
+	to, _ := NewSchemeVersion("scheme", 2, SchemeVersionDraft, CreateSchemesRequest{Name: "after"})
+
+	diff, err := DiffSchemeVersions(from, to)
+	if err != nil {
+		t.Fatalf("DiffSchemeVersions error = %v", err)
+	}
+	if diff.SchemeID != "scheme" || diff.From != 1 || diff.To != 2 || len(diff.Changes) != 2 {
+		t.Fatalf("DiffSchemeVersions = %+v, want the budget and the name changed between versions 1 and 2", diff)
+	}
+	// sorted by path
+	budget, name := diff.Changes[0], diff.Changes[1]
+	if budget.Path != "budget" || budget.Change != "changed" || budget.From != 1000.0 || budget.To != nil {
+		t.Fatalf("budget change = %+v, want 1000 changed to null", budget)
+	}
+	if name.Path != "name" || name.Change != "changed" || name.From != "before" || name.To != "after" {
+		t.Fatalf("name change = %+v, want before changed to after", name)
+	}
+}
@@ -3,13 +3,36 @@ package models
 import (
 	"FASMS/utils"
 	"errors"
+	"fmt"
+	"time"
+)
+
+const (
+	SchemeStatusDraft           uint = 1
+	SchemeStatusPendingApproval uint = 2
+	SchemeStatusPublished       uint = 3
+	SchemeStatusRetired         uint = 4
 )
 
 type Schemes struct {
-	ID             string          `json:"id" gorm:"primaryKey"`
-	Name           string          `json:"name"`
-	CriteriaGroups []CriteriaGroup `json:"criteria_groups" gorm:"foreignKey:SchemeID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE"`
-	Benefits       []Benefits      `json:"benifits" gorm:"foreignKey:SchemeID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE"`
+	ID               string            `json:"id" gorm:"primaryKey"`
+	Name             string            `json:"name"`
+	AgeReferenceType uint              `json:"age_reference_type" gorm:"default:1;comment:'1: evaluation date, 2: fixed date, 3: start of year, 4: application date'"`
+	AgeReferenceDate *time.Time        `json:"age_reference_date" gorm:"type:date"`
+	ApplicationOpen  *time.Time        `json:"application_open" gorm:"type:date;comment:'first day applications are accepted, null: no limitation'"`
+	ApplicationClose *time.Time        `json:"application_close" gorm:"type:date;comment:'last day applications are accepted, null: no limitation'"`
+	EffectiveFrom    *time.Time        `json:"effective_from" gorm:"type:date;comment:'first day benefits are effective, null: no limitation'"`
+	EffectiveTo      *time.Time        `json:"effective_to" gorm:"type:date;comment:'last day benefits are effective, null: no limitation'"`
+	Status           uint              `json:"status" gorm:"default:3;comment:'1: draft, 2: pending approval, 3: published, 4: retired'"`
+	CurrentVersion   uint              `json:"current_version" gorm:"comment:'the published version the scheme tables hold, 0: never published'"`
+	Budget           *utils.Money      `json:"budget" gorm:"type:numeric(14,2);comment:'null: no limitation'"`
+	MaxBeneficiaries *uint             `json:"max_beneficiaries" gorm:"comment:'null: no limitation'"`
+	BudgetCommitted  utils.Money       `json:"budget_committed" gorm:"type:numeric(14,2);default:0;not null;comment:'the planned instalments of the approved applications'"`
+	Beneficiaries    uint              `json:"beneficiaries" gorm:"default:0;not null;comment:'the approved applications'"`
+	CriteriaGroups   []CriteriaGroup   `json:"criteria_groups" gorm:"foreignKey:SchemeID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE"`
+	CriteriaNodes    []CriteriaNode    `json:"criteria_nodes" gorm:"foreignKey:SchemeID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE"`
+	Benefits         []Benefits        `json:"benifits" gorm:"foreignKey:SchemeID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE"`
+	Relations        []SchemeRelations `json:"relations" gorm:"foreignKey:SchemeID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE"`
 	CommonTime
 }
 type CriteriaGroup struct {
@@ -19,6 +42,20 @@ type CriteriaGroup struct {
 	Criterias []Criterias `json:"criterias" gorm:"foreignKey:CriteriaGroupID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE"`
 	CommonTime
 }
+
+// a node of the boolean tree combining the criteria groups of a scheme, stored flat with a parent id,
+// a scheme without nodes requires all of its criteria groups
+type CriteriaNode struct {
+	ID              string         `json:"id" gorm:"primaryKey"`
+	SchemeID        string         `json:"scheme_id" gorm:"index;not null"`
+	Scheme          Schemes        `json:"-" gorm:"foreignKey:SchemeID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE"`
+	ParentID        *string        `json:"parent_id" gorm:"index;comment:'null: root node'"`
+	Position        uint           `json:"position"`
+	Operator        uint           `json:"operator" gorm:"comment:'1: and, 2: or, 3: not, 4: criteria group'"`
+	CriteriaGroupID *string        `json:"criteria_group_id" gorm:"comment:'the criteria group of a criteria group node'"`
+	Children        []CriteriaNode `json:"-" gorm:"-"`
+	CommonTime
+}
 type Criterias struct {
 	ID               string        `json:"id" gorm:"primaryKey"`
 	EmploymentStatus uint          `json:"employment_status" gorm:"comment:'1: unemployed, 2: employed, 3: in school, 99: no limitation'"`
@@ -28,20 +65,41 @@ type Criterias struct {
 	AgeLowerLimit    uint32        `json:"age_lower_limit" gorm:"default:0"`
 	Relation         uint          `json:"relation" gorm:"comment:'1: children, 2: spouse, 3: parents, 99: no limitation'"`
 	IsHouseHold      bool          `json:"is_household"`
+	CriteriaType     uint          `json:"criteria_type" gorm:"default:1;comment:'1: household member, 2: household member count, 3: household size, 4: relation present, 5: relation absent, 6: household income, 7: per capita household income'"`
+	MinCount         uint32        `json:"min_count" gorm:"default:0"`
+	MaxCount         *uint32       `json:"max_count" gorm:"comment:'null: no limitation'"`
+	MaxMonthlyIncome *utils.Money  `json:"max_monthly_income" gorm:"type:numeric(14,2);comment:'null: no limitation'"`
+	MaxAssets        *utils.Money  `json:"max_assets" gorm:"type:numeric(14,2);comment:'null: no limitation'"`
+	Expression       string        `json:"expression" gorm:"type:text;comment:'optional rule expression, evaluated together with the columns above'"`
 	CriteriaGroupID  string        `json:"criteria_group_id" gorm:"index;not null"`
 	CriteriaGroup    CriteriaGroup `json:"-" gorm:"foreignKey:CriteriaGroupID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE"`
 	CommonTime
 }
 type Benefits struct {
-	ID       string  `json:"id" gorm:"primaryKey"`
-	Name     string  `json:"name"`
-	Amount   float32 `json:"amount"`
-	SchemeID string  `json:"scheme_id" gorm:"index;not null"`
-	Scheme   Schemes `json:"-" gorm:"foreignKey:SchemeID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE"`
+	ID               string       `json:"id" gorm:"primaryKey"`
+	Name             string       `json:"name"`
+	Amount           utils.Money  `json:"amount" gorm:"type:numeric(14,2);comment:'the fixed amount, or the amount per unit'"`
+	Currency         string       `json:"currency" gorm:"size:3;default:'SGD';comment:'ISO 4217 currency code'"`
+	AmountType       uint         `json:"amount_type" gorm:"default:1;comment:'1: fixed, 2: per matched household member, 3: per household member, 4: per household size, 5: tiered'"`
+	MemberExpression string       `json:"member_expression" gorm:"comment:'the household members counted by a per household member benefit'"`
+	TierBasis        uint         `json:"tier_basis" gorm:"comment:'1: per capita income, 2: household income, 3: applicant age'"`
+	Tiers            *string      `json:"tiers" gorm:"type:jsonb"`
+	MinAmount        *utils.Money `json:"min_amount" gorm:"type:numeric(14,2)"`
+	MaxAmount        *utils.Money `json:"max_amount" gorm:"type:numeric(14,2)"`
+	Frequency        uint         `json:"frequency" gorm:"default:1;comment:'1: one-off, 2: monthly, 3: quarterly'"`
+	Instalments      uint         `json:"instalments" gorm:"comment:'0: until the schedule end or the stop condition'"`
+	ScheduleStart    *time.Time   `json:"schedule_start" gorm:"type:date;comment:'null: from the approval'"`
+	ScheduleEnd      *time.Time   `json:"schedule_end" gorm:"type:date;comment:'null: no end date'"`
+	StopOnIneligible bool         `json:"stop_on_ineligible" gorm:"comment:'eligibility is checked again before each instalment'"`
+	StopExpression   string       `json:"stop_expression" gorm:"comment:'an applicant expression stopping the instalments once true'"`
+	SchemeID         string       `json:"scheme_id" gorm:"index;not null"`
+	Scheme           Schemes      `json:"-" gorm:"foreignKey:SchemeID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE"`
 	CommonTime
 }
 
 type GetSchemesRequest struct {
+	ActiveOn *utils.Date `form:"active_on"`
+	Status   uint        `form:"status" binding:"omitempty,oneof=1 2 3 4"`
 	PaginationQuery
 }
 
@@ -49,64 +107,161 @@ type GetEligibleSchemesRequest struct {
 	ApplicantID string `form:"applicant"  binding:"required"`
 	PaginationQuery
 }
+
+// keyset pagination, after is the next cursor of the previous page
+type GetEligibleApplicantsRequest struct {
+	After    string `form:"after"`
+	PageSize int    `form:"page_size" binding:"omitempty,gte=1,lte=100"`
+}
 type CreateSchemesListRequest struct {
 	Schemes []CreateSchemesRequest `json:"schemes" binding:"required,dive"`
 }
 type CreateSchemesRequest struct {
-	Name           string                        `json:"name" binding:"required"`
-	CriteriaGroups []CreateCriteriaGroupsRequest `json:"criteria_groups" binding:"required,dive"`
-	Benefits       []CreateBenefitRequest        `json:"benefits" binding:"required,dive"`
+	Name             string                        `json:"name" binding:"required"`
+	AgeReferenceType uint                          `json:"age_reference_type" binding:"omitempty,oneof=1 2 3 4"`
+	AgeReferenceDate *utils.Date                   `json:"age_reference_date"`
+	ApplicationOpen  *utils.Date                   `json:"application_open"`
+	ApplicationClose *utils.Date                   `json:"application_close"`
+	EffectiveFrom    *utils.Date                   `json:"effective_from"`
+	EffectiveTo      *utils.Date                   `json:"effective_to"`
+	Budget           *utils.Money                  `json:"budget" binding:"omitempty,gte=0"`
+	MaxBeneficiaries *uint                         `json:"max_beneficiaries"`
+	CriteriaGroups   []CreateCriteriaGroupsRequest `json:"criteria_groups" binding:"required,dive"`
+	Rule             *CreateCriteriaNodeRequest    `json:"rule"`
+	Benefits         []CreateBenefitRequest        `json:"benefits" binding:"required,dive"`
+	Relations        []CreateSchemeRelationRequest `json:"relations" binding:"dive"`
 }
 type CreateCriteriaGroupsRequest struct {
 	ID        string                  `json:"id"`
 	Criterias []CreateCriteriaRequest `json:"criterias" binding:"required,dive"`
 }
+
+// group_index refers to the position of the criteria group in criteria_groups
+type CreateCriteriaNodeRequest struct {
+	Operator   uint                        `json:"operator" binding:"required,oneof=1 2 3 4"`
+	GroupIndex *int                        `json:"group_index"`
+	Children   []CreateCriteriaNodeRequest `json:"children" binding:"dive"`
+}
 type CreateCriteriaRequest struct {
-	ID               string `json:"id"`
-	EmploymentStatus uint   `json:"employment_status" binding:"required,oneof=1 2 3 99"`
-	MaritalStatus    uint   `json:"marital_status" binding:"required,oneof=1 2 3 4 99"`
-	Sex              uint   `json:"sex" binding:"required,oneof=1 2 99"`
-	AgeUpperLimit    uint32 `json:"age_upper_limit" binding:"gte=0"`
-	AgeLowerLimit    uint32 `json:"age_lower_limit" binding:"gte=0"`
-	Relation         uint   `json:"relation" binding:"required,oneof=1 2 3 99"`
-	IsHouseHold      *bool  `json:"is_household" binding:"required"`
+	ID               string       `json:"id"`
+	EmploymentStatus uint         `json:"employment_status" binding:"required,oneof=1 2 3 99"`
+	MaritalStatus    uint         `json:"marital_status" binding:"required,oneof=1 2 3 4 99"`
+	Sex              uint         `json:"sex" binding:"required,oneof=1 2 99"`
+	AgeUpperLimit    uint32       `json:"age_upper_limit" binding:"gte=0"`
+	AgeLowerLimit    uint32       `json:"age_lower_limit" binding:"gte=0"`
+	Relation         uint         `json:"relation" binding:"required,oneof=1 2 3 99"`
+	IsHouseHold      *bool        `json:"is_household" binding:"required"`
+	CriteriaType     uint         `json:"criteria_type" binding:"omitempty,oneof=1 2 3 4 5 6 7"`
+	MinCount         uint32       `json:"min_count" binding:"gte=0"`
+	MaxCount         *uint32      `json:"max_count" binding:"omitempty,gte=0"`
+	MaxMonthlyIncome *utils.Money `json:"max_monthly_income" binding:"omitempty,gte=0"`
+	MaxAssets        *utils.Money `json:"max_assets" binding:"omitempty,gte=0"`
+	Expression       string       `json:"expression"`
 }
 type CreateBenefitRequest struct {
-	ID     string  `json:"id"`
-	Name   string  `json:"name"  binding:"required"`
-	Amount float32 `json:"amount"  binding:"required,gte=0"`
+	ID               string        `json:"id"`
+	Name             string        `json:"name"  binding:"required"`
+	Amount           utils.Money   `json:"amount"  binding:"gte=0"`
+	Currency         string        `json:"currency" binding:"omitempty,iso4217"`
+	AmountType       uint          `json:"amount_type" binding:"omitempty,oneof=1 2 3 4 5"`
+	MemberExpression string        `json:"member_expression"`
+	TierBasis        uint          `json:"tier_basis" binding:"omitempty,oneof=1 2 3"`
+	Tiers            []BenefitTier `json:"tiers" binding:"dive"`
+	MinAmount        *utils.Money  `json:"min_amount" binding:"omitempty,gte=0"`
+	MaxAmount        *utils.Money  `json:"max_amount" binding:"omitempty,gte=0"`
+	Frequency        uint          `json:"frequency" binding:"omitempty,oneof=1 2 3"`
+	Instalments      uint          `json:"instalments"`
+	ScheduleStart    *utils.Date   `json:"schedule_start"`
+	ScheduleEnd      *utils.Date   `json:"schedule_end"`
+	StopOnIneligible bool          `json:"stop_on_ineligible"`
+	StopExpression   string        `json:"stop_expression"`
 }
 
 type SchemesResponse struct {
-	ID                     string                   `json:"id"`
-	Name                   string                   `json:"name"`
-	CriteriaGroupsResponse []CriteriaGroupsResponse `json:"criteria_groups"`
-	BenefitsResponse       []BenefitsResponse       `json:"benefits"`
+	ID                     string                    `json:"id"`
+	Name                   string                    `json:"name"`
+	AgeReferenceType       uint                      `json:"age_reference_type"`
+	AgeReferenceDate       *utils.Date               `json:"age_reference_date"`
+	ApplicationOpen        *utils.Date               `json:"application_open"`
+	ApplicationClose       *utils.Date               `json:"application_close"`
+	EffectiveFrom          *utils.Date               `json:"effective_from"`
+	EffectiveTo            *utils.Date               `json:"effective_to"`
+	Status                 uint                      `json:"status"`
+	CurrentVersion         uint                      `json:"current_version"`
+	Budget                 *utils.Money              `json:"budget"`
+	BudgetCommitted        utils.Money               `json:"budget_committed"`
+	RemainingBudget        *utils.Money              `json:"remaining_budget"`
+	MaxBeneficiaries       *uint                     `json:"max_beneficiaries"`
+	Beneficiaries          uint                      `json:"beneficiaries"`
+	RemainingBeneficiaries *uint                     `json:"remaining_beneficiaries"`
+	CriteriaGroupsResponse []CriteriaGroupsResponse  `json:"criteria_groups"`
+	Rule                   *CriteriaNodeResponse     `json:"rule"`
+	BenefitsResponse       []BenefitsResponse        `json:"benefits"`
+	Relations              []SchemeRelationsResponse `json:"relations"`
 }
 type CriteriaGroupsResponse struct {
 	ID                string              `json:"id"`
 	CriteriasResponse []CriteriasResponse `json:"criterias"`
 }
+type CriteriaNodeResponse struct {
+	ID              string                 `json:"id"`
+	Operator        uint                   `json:"operator"`
+	CriteriaGroupID *string                `json:"criteria_group_id,omitempty"`
+	Children        []CriteriaNodeResponse `json:"children"`
+}
 type CriteriasResponse struct {
-	ID               string `json:"id"`
-	EmploymentStatus uint   `json:"employment_status"`
-	MaritalStatus    uint   `json:"marital_status"`
-	Sex              uint   `json:"sex"`
-	AgeUpperLimit    uint32 `json:"age_upper_limit"`
-	AgeLowerLimit    uint32 `json:"age_lower_limit"`
-	Relation         uint   `json:"relation"`
-	IsHouseHold      bool   `json:"is_household"`
+	ID               string       `json:"id"`
+	EmploymentStatus uint         `json:"employment_status"`
+	MaritalStatus    uint         `json:"marital_status"`
+	Sex              uint         `json:"sex"`
+	AgeUpperLimit    uint32       `json:"age_upper_limit"`
+	AgeLowerLimit    uint32       `json:"age_lower_limit"`
+	Relation         uint         `json:"relation"`
+	IsHouseHold      bool         `json:"is_household"`
+	CriteriaType     uint         `json:"criteria_type"`
+	MinCount         uint32       `json:"min_count"`
+	MaxCount         *uint32      `json:"max_count"`
+	MaxMonthlyIncome *utils.Money `json:"max_monthly_income"`
+	MaxAssets        *utils.Money `json:"max_assets"`
+	Expression       string       `json:"expression"`
 }
 type BenefitsResponse struct {
-	ID     string  `json:"id"`
-	Name   string  `json:"name"`
-	Amount float32 `json:"amount"`
+	ID               string        `json:"id"`
+	Name             string        `json:"name"`
+	Amount           utils.Money   `json:"amount"`
+	Currency         string        `json:"currency"`
+	AmountType       uint          `json:"amount_type"`
+	MemberExpression string        `json:"member_expression"`
+	TierBasis        uint          `json:"tier_basis"`
+	Tiers            []BenefitTier `json:"tiers"`
+	MinAmount        *utils.Money  `json:"min_amount"`
+	MaxAmount        *utils.Money  `json:"max_amount"`
+	Frequency        uint          `json:"frequency"`
+	Instalments      uint          `json:"instalments"`
+	ScheduleStart    *utils.Date   `json:"schedule_start"`
+	ScheduleEnd      *utils.Date   `json:"schedule_end"`
+	StopOnIneligible bool          `json:"stop_on_ineligible"`
+	StopExpression   string        `json:"stop_expression"`
 }
 
 func (s *Schemes) ConvertToResponse() SchemesResponse {
 	SchemesResponse := SchemesResponse{
-		ID:   s.ID,
-		Name: s.Name,
+		ID:                     s.ID,
+		Name:                   s.Name,
+		AgeReferenceType:       s.GetAgeReferenceType(),
+		AgeReferenceDate:       utils.NewDatePtr(s.AgeReferenceDate),
+		ApplicationOpen:        utils.NewDatePtr(s.ApplicationOpen),
+		ApplicationClose:       utils.NewDatePtr(s.ApplicationClose),
+		EffectiveFrom:          utils.NewDatePtr(s.EffectiveFrom),
+		EffectiveTo:            utils.NewDatePtr(s.EffectiveTo),
+		Status:                 s.Status,
+		CurrentVersion:         s.CurrentVersion,
+		Budget:                 s.Budget,
+		BudgetCommitted:        s.BudgetCommitted,
+		RemainingBudget:        s.RemainingBudget(),
+		MaxBeneficiaries:       s.MaxBeneficiaries,
+		Beneficiaries:          s.Beneficiaries,
+		RemainingBeneficiaries: s.RemainingBeneficiaries(),
 	}
 
 	// Convert CriteriaGroups and their Criterias
@@ -124,18 +279,32 @@ func (s *Schemes) ConvertToResponse() SchemesResponse {
 				AgeLowerLimit:    criteria.AgeLowerLimit,
 				Relation:         criteria.Relation,
 				IsHouseHold:      criteria.IsHouseHold,
+				CriteriaType:     criteria.GetCriteriaType(),
+				MinCount:         criteria.MinCount,
+				MaxCount:         criteria.MaxCount,
+				MaxMonthlyIncome: criteria.MaxMonthlyIncome,
+				MaxAssets:        criteria.MaxAssets,
+				Expression:       criteria.Expression,
 			})
 		}
 		SchemesResponse.CriteriaGroupsResponse = append(SchemesResponse.CriteriaGroupsResponse, groupResponse)
 	}
 
+	// Convert the criteria tree, only schemes with a stored tree have one
+	if len(s.CriteriaNodes) > 0 {
+		tree := s.GetCriteriaTree()
+		rule := tree.ConvertToResponse()
+		SchemesResponse.Rule = &rule
+	}
+
 	// Convert Benefits
 	for _, benefit := range s.Benefits {
-		SchemesResponse.BenefitsResponse = append(SchemesResponse.BenefitsResponse, BenefitsResponse{
-			ID:     benefit.ID,
-			Name:   benefit.Name,
-			Amount: benefit.Amount,
-		})
+		SchemesResponse.BenefitsResponse = append(SchemesResponse.BenefitsResponse, benefit.ConvertToResponse())
+	}
+
+	// Convert Relations
+	for _, relation := range s.Relations {
+		SchemesResponse.Relations = append(SchemesResponse.Relations, relation.ConvertToResponse())
 	}
 
 	return SchemesResponse
@@ -146,8 +315,10 @@ func (s *CreateSchemesRequest) ConvertToModel() Schemes {
 
 	// Convert CriteriaGroups and their Criterias
 	criteriaGroups := make([]CriteriaGroup, 0, len(s.CriteriaGroups))
+	groupIds := make([]string, 0, len(s.CriteriaGroups))
 	for _, group := range s.CriteriaGroups {
 		groupId := utils.GenerateUUID()
+		groupIds = append(groupIds, groupId)
 
 		criterias := make([]Criterias, 0, len(group.Criterias))
 		for _, c := range group.Criterias {
@@ -160,6 +331,12 @@ func (s *CreateSchemesRequest) ConvertToModel() Schemes {
 				AgeLowerLimit:    c.AgeLowerLimit,
 				Relation:         c.Relation,
 				IsHouseHold:      *c.IsHouseHold,
+				CriteriaType:     c.GetCriteriaType(),
+				MinCount:         c.MinCount,
+				MaxCount:         c.MaxCount,
+				MaxMonthlyIncome: c.MaxMonthlyIncome,
+				MaxAssets:        c.MaxAssets,
+				Expression:       c.Expression,
 				CriteriaGroupID:  groupId,
 			})
 		}
@@ -174,33 +351,153 @@ func (s *CreateSchemesRequest) ConvertToModel() Schemes {
 	// Convert Benefits
 	benefits := make([]Benefits, 0, len(s.Benefits))
 	for _, b := range s.Benefits {
-		benefits = append(benefits, Benefits{
-			ID:       utils.GenerateUUID(),
-			Name:     b.Name,
-			Amount:   b.Amount,
-			SchemeID: schemeId,
-		})
+		benefits = append(benefits, b.ConvertToModel(utils.GenerateUUID(), schemeId))
+	}
+
+	// Convert Relations
+	relations := make([]SchemeRelations, 0, len(s.Relations))
+	for _, r := range s.Relations {
+		relations = append(relations, r.ConvertToModel(schemeId))
 	}
 
 	return Schemes{
-		ID:             schemeId,
-		Name:           s.Name,
-		CriteriaGroups: criteriaGroups,
-		Benefits:       benefits,
+		ID:               schemeId,
+		Name:             s.Name,
+		AgeReferenceType: s.GetAgeReferenceType(),
+		AgeReferenceDate: s.GetAgeReferenceDate(),
+		ApplicationOpen:  s.ApplicationOpen.ToTimePtr(),
+		ApplicationClose: s.ApplicationClose.ToTimePtr(),
+		EffectiveFrom:    s.EffectiveFrom.ToTimePtr(),
+		EffectiveTo:      s.EffectiveTo.ToTimePtr(),
+		Budget:           s.Budget,
+		MaxBeneficiaries: s.MaxBeneficiaries,
+		Status:           SchemeStatusDraft,
+		CriteriaGroups:   criteriaGroups,
+		CriteriaNodes:    s.ConvertCriteriaNodes(schemeId, groupIds),
+		Benefits:         benefits,
+		Relations:        relations,
+	}
+}
+
+// only published schemes are considered for eligibility and new applications
+func (s *Schemes) IsPublished() bool {
+	return s.Status == SchemeStatusPublished
+}
+
+// whether applications are accepted on the day of at
+func (s *Schemes) IsOpenForApplication(at time.Time) bool {
+	return utils.IsWithinDates(at, s.ApplicationOpen, s.ApplicationClose)
+}
+
+// whether the benefits of the scheme are effective on the day of at
+func (s *Schemes) IsEffectiveOn(at time.Time) bool {
+	return utils.IsWithinDates(at, s.EffectiveFrom, s.EffectiveTo)
+}
+
+// describes the application period for error messages
+func (s *Schemes) ApplicationPeriod() string {
+	return fmt.Sprintf("%s to %s", utils.FormatOptionalDate(s.ApplicationOpen), utils.FormatOptionalDate(s.ApplicationClose))
+}
+
+func (n *CriteriaNode) ConvertToResponse() CriteriaNodeResponse {
+	nodeResponse := CriteriaNodeResponse{
+		ID:              n.ID,
+		Operator:        n.Operator,
+		CriteriaGroupID: n.CriteriaGroupID,
+		Children:        []CriteriaNodeResponse{},
+	}
+	for _, child := range n.Children {
+		nodeResponse.Children = append(nodeResponse.Children, child.ConvertToResponse())
 	}
+	return nodeResponse
 }
 
-// we have an assumption that only one criteria group can have applicant criteria,
-// and within that group no household criteria should be existing
+// flattens the rule of the request into criteria nodes, groupIDs are the ids of the request's criteria groups in order
+func (s *CreateSchemesRequest) ConvertCriteriaNodes(schemeID string, groupIDs []string) []CriteriaNode {
+	var nodes []CriteriaNode
+	if s.Rule == nil {
+		return nodes
+	}
+	var convert func(nodeRequest CreateCriteriaNodeRequest, parentID *string, position int)
+	convert = func(nodeRequest CreateCriteriaNodeRequest, parentID *string, position int) {
+		node := CriteriaNode{
+			ID:       utils.GenerateUUID(),
+			SchemeID: schemeID,
+			ParentID: parentID,
+			Position: uint(position),
+			Operator: nodeRequest.Operator,
+		}
+		if nodeRequest.Operator == CriteriaNodeGroup && nodeRequest.GroupIndex != nil {
+			groupID := groupIDs[*nodeRequest.GroupIndex]
+			node.CriteriaGroupID = &groupID
+		}
+		nodes = append(nodes, node)
+		for i, child := range nodeRequest.Children {
+			convert(child, &node.ID, i)
+		}
+	}
+	convert(*s.Rule, nil, 0)
+	return nodes
+}
+
+// schemes without reference type evaluate age on the evaluation date, which is how age was always evaluated
+func (s *CreateSchemesRequest) GetAgeReferenceType() uint {
+	if s.AgeReferenceType == 0 {
+		return AgeReferenceEvaluationDate
+	}
+	return s.AgeReferenceType
+}
+
+// the reference date is only kept for schemes with a fixed reference date
+func (s *CreateSchemesRequest) GetAgeReferenceDate() *time.Time {
+	if s.GetAgeReferenceType() != AgeReferenceFixedDate || s.AgeReferenceDate == nil {
+		return nil
+	}
+	referenceDate := s.AgeReferenceDate.ToTime()
+	return &referenceDate
+}
+
+// a criteria group has either only applicant criterias or only household criterias,
+// the groups are combined by the rule of the scheme, or all required when there is no rule
 func (s *CreateSchemesRequest) IsValidScheme() (bool, error) {
 	// a scheme has to have at least one criteriaGroup
 	if len(s.CriteriaGroups) == 0 {
 		return false, errors.New("a scheme must have at least one criteria group")
 	}
+	// a fixed reference date scheme has to have the date
+	if s.GetAgeReferenceType() == AgeReferenceFixedDate && s.AgeReferenceDate == nil {
+		return false, errors.New("a scheme with fixed age reference date must have the age reference date")
+	}
+	// the application period and the effective period can not end before they start
+	if s.ApplicationOpen != nil && s.ApplicationClose != nil && s.ApplicationClose.ToTime().Before(s.ApplicationOpen.ToTime()) {
+		return false, errors.New("a scheme's application close date can not be before its application open date")
+	}
+	if s.EffectiveFrom != nil && s.EffectiveTo != nil && s.EffectiveTo.ToTime().Before(s.EffectiveFrom.ToTime()) {
+		return false, errors.New("a scheme's effective to date can not be before its effective from date")
+	}
 	// a scheme has to have at least one benefit
 	if len(s.Benefits) == 0 {
 		return false, errors.New("a scheme must have at least one benefit")
 	}
+	if err := s.validateRelations(); err != nil {
+		return false, err
+	}
+	for _, benefit := range s.Benefits {
+		if err := benefit.validate(); err != nil {
+			return false, err
+		}
+		if err := benefit.validateSchedule(s.EffectiveTo != nil); err != nil {
+			return false, err
+		}
+		// the budget is committed for every planned instalment, so the instalments have to be known at approval
+		if s.Budget != nil && benefit.GetFrequency() != BenefitFrequencyOneOff && benefit.Instalments == 0 && benefit.ScheduleEnd == nil && s.EffectiveTo == nil {
+			return false, fmt.Errorf("benefit %s: a recurring benefit of a scheme with a budget must have instalments or a schedule end", benefit.Name)
+		}
+		// the benefits are summed into one entitlement, so they are paid in one currency
+		if benefit.GetCurrency() != s.Benefits[0].GetCurrency() {
+			return false, errors.New("a scheme's benefits must all be in the same currency")
+		}
+	}
 	// for all the criteriaGroup, there are at least one criteria
 	for _, group := range s.CriteriaGroups {
 		if len(group.Criterias) == 0 {
@@ -208,30 +505,136 @@ func (s *CreateSchemesRequest) IsValidScheme() (bool, error) {
 		}
 	}
 
-	//checking if we only have 1 criteria group for applicant, and
-	// in that group no criteria is household
-	var applicantCriteriaGroup []CreateCriteriaGroupsRequest
+	// household composition and household income criteria look at the whole household, so they can only be household criteria
 	for _, group := range s.CriteriaGroups {
 		for _, criteria := range group.Criterias {
-			if !*criteria.IsHouseHold {
-				applicantCriteriaGroup = append(applicantCriteriaGroup, group)
+			criteriaType := criteria.GetCriteriaType()
+			if criteriaType == CriteriaTypeMember {
 				continue
 			}
+			if !*criteria.IsHouseHold {
+				return false, errors.New("a household composition or household income criteria must be a household criteria")
+			}
+			// the expression of a member count criteria selects the members counted, the other types have no member to evaluate it on
+			if criteriaType != CriteriaTypeMemberCount && criteria.Expression != "" {
+				return false, errors.New("only a household member or member count criteria can have an expression")
+			}
+			if (criteriaType == CriteriaTypeMemberCount || criteriaType == CriteriaTypeHouseholdSize) && criteria.MaxCount != nil && criteria.MinCount > *criteria.MaxCount {
+				return false, errors.New("a household composition criteria's min count can not be larger than its max count")
+			}
+			if (criteriaType == CriteriaTypeRelationPresent || criteriaType == CriteriaTypeRelationAbsent) && criteria.Relation == 99 {
+				return false, errors.New("a relation presence criteria must specify the relation")
+			}
+			if criteriaType == CriteriaTypeHouseholdIncome && criteria.MaxMonthlyIncome == nil && criteria.MaxAssets == nil {
+				return false, errors.New("a household income criteria must specify the max monthly income or the max assets")
+			}
+			if criteriaType == CriteriaTypePerCapitaIncome && criteria.MaxMonthlyIncome == nil {
+				return false, errors.New("a per capita household income criteria must specify the max monthly income")
+			}
 		}
 	}
-	if len(applicantCriteriaGroup) > 1 {
-		return false, errors.New("a scheme can only has one criteria group that criteria on applicant")
-	}
-	if len(applicantCriteriaGroup) == 1 {
-		for _, criteria := range applicantCriteriaGroup[0].Criterias {
-			if *criteria.IsHouseHold {
+
+	// a criteria group is either on the applicant or on household members, not both
+	for _, group := range s.CriteriaGroups {
+		for _, criteria := range group.Criterias {
+			if *criteria.IsHouseHold != *group.Criterias[0].IsHouseHold {
 				return false, errors.New("a scheme's criteria group criteria on applicant can not have any criteria that is on household")
 			}
 		}
 	}
+
+	// the rule has to use every criteria group exactly once
+	if s.Rule != nil {
+		usedGroups := make(map[int]bool)
+		if err := s.Rule.validate(len(s.CriteriaGroups), usedGroups, 1); err != nil {
+			return false, err
+		}
+		if len(usedGroups) != len(s.CriteriaGroups) {
+			return false, errors.New("a scheme's rule must use every criteria group")
+		}
+	}
 	return true, nil
 }
 
+const maxCriteriaTreeDepth = 10
+
+func (n *CreateCriteriaNodeRequest) validate(groupCount int, usedGroups map[int]bool, depth int) error {
+	if depth > maxCriteriaTreeDepth {
+		return fmt.Errorf("a scheme's rule can not be nested deeper than %d levels", maxCriteriaTreeDepth)
+	}
+	switch n.Operator {
+	case CriteriaNodeAnd, CriteriaNodeOr:
+		if len(n.Children) == 0 {
+			return errors.New("an and/or node of the rule must have at least one child")
+		}
+	case CriteriaNodeNot:
+		if len(n.Children) != 1 {
+			return errors.New("a not node of the rule must have exactly one child")
+		}
+	case CriteriaNodeGroup:
+		if len(n.Children) != 0 {
+			return errors.New("a criteria group node of the rule can not have children")
+		}
+		if n.GroupIndex == nil || *n.GroupIndex < 0 || *n.GroupIndex >= groupCount {
+			return errors.New("a criteria group node of the rule must have a valid group index")
+		}
+		if usedGroups[*n.GroupIndex] {
+			return fmt.Errorf("criteria group %d is used more than once in the rule", *n.GroupIndex)
+		}
+		usedGroups[*n.GroupIndex] = true
+	}
+	if n.Operator != CriteriaNodeGroup && n.GroupIndex != nil {
+		return errors.New("only a criteria group node of the rule can have a group index")
+	}
+	for _, child := range n.Children {
+		if err := child.validate(groupCount, usedGroups, depth+1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// compile every criteria expression, so that a scheme with an invalid rule is never stored
+func (s *CreateSchemesRequest) CompileExpressions() error {
+	for groupIndex, group := range s.CriteriaGroups {
+		for criteriaIndex, criteria := range group.Criterias {
+			if criteria.Expression == "" {
+				continue
+			}
+			if _, err := CompileCriteriaExpression(criteria.Expression, *criteria.IsHouseHold); err != nil {
+				return fmt.Errorf("criteria group %d, criteria %d: invalid expression, %v", groupIndex, criteriaIndex, err)
+			}
+		}
+	}
+	// a member expression is evaluated on each household member
+	for benefitIndex, benefit := range s.Benefits {
+		if benefit.MemberExpression == "" {
+			continue
+		}
+		if _, err := CompileCriteriaExpression(benefit.MemberExpression, true); err != nil {
+			return fmt.Errorf("benefit %d: invalid member expression, %v", benefitIndex, err)
+		}
+	}
+	// a stop expression is evaluated on the applicant before each instalment
+	for benefitIndex, benefit := range s.Benefits {
+		if benefit.StopExpression == "" {
+			continue
+		}
+		if _, err := CompileCriteriaExpression(benefit.StopExpression, false); err != nil {
+			return fmt.Errorf("benefit %d: invalid stop expression, %v", benefitIndex, err)
+		}
+	}
+	return nil
+}
+
+// criteria type defaults to household member matching, which is how criterias were matched before types existed
+func (c *CreateCriteriaRequest) GetCriteriaType() uint {
+	if c.CriteriaType == 0 {
+		return CriteriaTypeMember
+	}
+	return c.CriteriaType
+}
+
 func ConvertCriterias(newCriterias []CreateCriteriaRequest, groupID string) []Criterias {
 	var convertedCriterias []Criterias
 
@@ -250,6 +653,12 @@ func ConvertCriterias(newCriterias []CreateCriteriaRequest, groupID string) []Cr
 			AgeLowerLimit:    c.AgeLowerLimit,
 			Relation:         c.Relation,
 			IsHouseHold:      *c.IsHouseHold,
+			CriteriaType:     c.GetCriteriaType(),
+			MinCount:         c.MinCount,
+			MaxCount:         c.MaxCount,
+			MaxMonthlyIncome: c.MaxMonthlyIncome,
+			MaxAssets:        c.MaxAssets,
+			Expression:       c.Expression,
 			CriteriaGroupID:  groupID, // Assign to the given group
 		})
 	}
@@ -0,0 +1,83 @@
+package models
+
+import (
+	"FASMS/utils"
+	"sync"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	RoleAdmin        uint = 1
+	RolePolicyEditor uint = 2
+	RoleCaseOfficer  uint = 3
+	RoleReadOnly     uint = 4
+)
+
+type Users struct {
+	ID           string `json:"id" gorm:"primaryKey"`
+	Username     string `json:"username" gorm:"uniqueIndex;not null"`
+	PasswordHash string `json:"-" gorm:"not null"`
+	Role         uint   `json:"role" gorm:"not null;comment:'1: admin, 2: policy editor, 3: case officer, 4: read only'"`
+	CommonTime
+}
+
+type LoginRequest struct {
+	Username string `json:"username" binding:"required"`
+	Password string `json:"password" binding:"required"`
+}
+
+type CreateUserRequest struct {
+	Username string `json:"username" binding:"required"`
+	Password string `json:"password" binding:"required,min=8,max=72"`
+	Role     uint   `json:"role" binding:"required,oneof=1 2 3 4"`
+}
+
+type UsersResponse struct {
+	ID       string `json:"id"`
+	Username string `json:"username"`
+	Role     uint   `json:"role"`
+}
+
+type LoginResponse struct {
+	Token     string        `json:"token"`
+	ExpiresAt int64         `json:"expires_at"`
+	User      UsersResponse `json:"user"`
+}
+
+func NewUser(username string, password string, role uint) (Users, error) {
+	passwordHash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return Users{}, err
+	}
+	return Users{
+		ID:           utils.GenerateUUID(),
+		Username:     username,
+		PasswordHash: string(passwordHash),
+		Role:         role,
+	}, nil
+}
+
+// the hash a password is compared against when the username does not exist,
+// so that a login with an unknown username takes as long as one with a wrong password
+var unknownUserPasswordHash = sync.OnceValue(func() []byte {
+	passwordHash, _ := bcrypt.GenerateFromPassword([]byte(utils.GenerateUUID()), bcrypt.DefaultCost)
+	return passwordHash
+})
+
+// a user that was not found never matches, its password is still compared against a hash of the same cost
+func (u *Users) CheckPassword(password string) bool {
+	if u.ID == "" {
+		bcrypt.CompareHashAndPassword(unknownUserPasswordHash(), []byte(password))
+		return false
+	}
+	return bcrypt.CompareHashAndPassword([]byte(u.PasswordHash), []byte(password)) == nil
+}
+
+func (u *Users) ConvertToResponse() UsersResponse {
+	return UsersResponse{
+		ID:       u.ID,
+		Username: u.Username,
+		Role:     u.Role,
+	}
+}
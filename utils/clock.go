@@ -0,0 +1,42 @@
+package utils
+
+import "time"
+
+// Now is the clock used by the business logic, replace it to evaluate at a fixed time
+var Now = time.Now
+
+// AgeAt returns the age in full years on the given date.
+// someone born on 29 Feb turns a year older on 1 Mar in non leap years
+func AgeAt(dob time.Time, at time.Time) uint32 {
+	age := at.Year() - dob.Year()
+	// Adjust if birthday hasn't occurred yet this year
+	if at.Month() < dob.Month() || (at.Month() == dob.Month() && at.Day() < dob.Day()) {
+		age--
+	}
+	if age < 0 {
+		return 0
+	}
+	return uint32(age)
+}
+
+// StartOfYear returns 1 January of the year of t
+func StartOfYear(t time.Time) time.Time {
+	return time.Date(t.Year(), time.January, 1, 0, 0, 0, 0, t.Location())
+}
+
+// StartOfDay truncates t to midnight, so that date only columns compare as dates
+func StartOfDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+// AddMonths adds months to the day of t, a day past the end of the target month is moved back to its last day,
+// so 31 January plus one month is 28 or 29 February
+func AddMonths(t time.Time, months int) time.Time {
+	firstOfMonth := time.Date(t.Year(), t.Month()+time.Month(months), 1, 0, 0, 0, 0, t.Location())
+	lastDay := firstOfMonth.AddDate(0, 1, -1).Day()
+	day := t.Day()
+	if day > lastDay {
+		day = lastDay
+	}
+	return time.Date(firstOfMonth.Year(), firstOfMonth.Month(), day, t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), t.Location())
+}
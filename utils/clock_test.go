@@ -0,0 +1,38 @@
+package utils
+
+import (
+	"testing"
+	"time"
+)
+
+func date(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+func TestAgeAt(t *testing.T) {
+	tests := []struct {
+		name string
+		dob  time.Time
+		at   time.Time
+		want uint32
+	}{
+		{name: "day before the birthday", dob: date(2000, time.June, 15), at: date(2026, time.June, 14), want: 25},
+		{name: "on the birthday", dob: date(2000, time.June, 15), at: date(2026, time.June, 15), want: 26},
+		{name: "day after the birthday", dob: date(2000, time.June, 15), at: date(2026, time.June, 16), want: 26},
+		{name: "month before the birthday month", dob: date(2000, time.June, 15), at: date(2026, time.May, 31), want: 25},
+		{name: "born 29 Feb, 28 Feb of a non leap year", dob: date(2020, time.February, 29), at: date(2021, time.February, 28), want: 0},
+		{name: "born 29 Feb, 1 Mar of a non leap year", dob: date(2020, time.February, 29), at: date(2021, time.March, 1), want: 1},
+		{name: "born 29 Feb, 28 Feb of a leap year", dob: date(2020, time.February, 29), at: date(2024, time.February, 28), want: 3},
+		{name: "born 29 Feb, 29 Feb of a leap year", dob: date(2020, time.February, 29), at: date(2024, time.February, 29), want: 4},
+		{name: "on the birth date", dob: date(2020, time.February, 29), at: date(2020, time.February, 29), want: 0},
+		{name: "reference date before the birth date", dob: date(2020, time.June, 15), at: date(2019, time.December, 31), want: 0},
+		{name: "reference date years before the birth date", dob: date(2020, time.June, 15), at: date(2000, time.June, 15), want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := AgeAt(tt.dob, tt.at); got != tt.want {
+				t.Fatalf("AgeAt(%s, %s) = %d, want %d", tt.dob.Format(DateFormat), tt.at.Format(DateFormat), got, tt.want)
+			}
+		})
+	}
+}
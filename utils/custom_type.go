@@ -29,3 +29,51 @@ func (d Date) MarshalJSON() ([]byte, error) {
 func (d Date) ToTime() time.Time {
 	return time.Time(d)
 }
+
+// UnmarshalParam lets gin bind a Date from a query parameter
+func (d *Date) UnmarshalParam(param string) error {
+	parsedTime, err := time.Parse(DateFormat, param)
+	if err != nil {
+		return errors.New("invalid date format, expected YYYY-MM-DD")
+	}
+	*d = Date(parsedTime)
+	return nil
+}
+
+// ToTimePtr converts an optional Date, nil stays nil
+func (d *Date) ToTimePtr() *time.Time {
+	if d == nil {
+		return nil
+	}
+	t := d.ToTime()
+	return &t
+}
+
+// NewDatePtr converts an optional time, nil stays nil
+func NewDatePtr(t *time.Time) *Date {
+	if t == nil {
+		return nil
+	}
+	d := Date(*t)
+	return &d
+}
+
+// FormatOptionalDate formats an optional date, nil is shown as "open"
+func FormatOptionalDate(t *time.Time) string {
+	if t == nil {
+		return "open"
+	}
+	return t.Format(DateFormat)
+}
+
+// IsWithinDates reports whether the day of at is between from and to inclusive, a nil bound is open
+func IsWithinDates(at time.Time, from *time.Time, to *time.Time) bool {
+	day := at.Format(DateFormat)
+	if from != nil && day < from.Format(DateFormat) {
+		return false
+	}
+	if to != nil && day > to.Format(DateFormat) {
+		return false
+	}
+	return true
+}
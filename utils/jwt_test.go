@@ -0,0 +1,80 @@
+package utils
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testSecret = []byte("0123456789abcdef0123456789abcdef")
+
+// a token of the given header and payload, signed with the test secret
+func signedToken(header string, payload string) string {
+	signingInput := base64.RawURLEncoding.EncodeToString([]byte(header)) + "." + base64.RawURLEncoding.EncodeToString([]byte(payload))
+	return signingInput + "." + signJWT(signingInput, testSecret)
+}
+
+func TestParseJWT(t *testing.T) {
+	issuedAt := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	defer func(now func() time.Time) { Now = now }(Now)
+	Now = func() time.Time { return issuedAt }
+
+	claims := NewClaims("user-id", "alice", 3, time.Hour)
+	token, err := SignJWT(claims, testSecret)
+	if err != nil {
+		t.Fatalf("SignJWT error = %v", err)
+	}
+	parts := strings.Split(token, ".")
+
+	tests := []struct {
+		name    string
+		token   string
+		secret  []byte
+		at      time.Time
+		wantErr error
+	}{
+		{name: "valid", token: token, secret: testSecret, at: issuedAt},
+		{name: "valid until just before expiry", token: token, secret: testSecret, at: issuedAt.Add(time.Hour - time.Second)},
+		{name: "expired", token: token, secret: testSecret, at: issuedAt.Add(time.Hour), wantErr: ErrExpiredToken},
+		{name: "other secret", token: token, secret: []byte("fedcba9876543210fedcba9876543210"), at: issuedAt, wantErr: ErrInvalidToken},
+		{name: "altered signature", token: parts[0] + "." + parts[1] + "." + strings.Repeat("A", len(parts[2])), secret: testSecret, at: issuedAt, wantErr: ErrInvalidToken},
+		{name: "missing signature", token: parts[0] + "." + parts[1] + ".", secret: testSecret, at: issuedAt, wantErr: ErrInvalidToken},
+		{name: "altered payload", token: parts[0] + "." + base64.RawURLEncoding.EncodeToString([]byte(`{"sub":"user-id","username":"alice","role":1,"exp":9999999999}`)) + "." + parts[2], secret: testSecret, at: issuedAt, wantErr: ErrInvalidToken},
+		{name: "alg none header", token: base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none","typ":"JWT"}`)) + "." + parts[1] + ".", secret: testSecret, at: issuedAt, wantErr: ErrInvalidToken},
+		{name: "other header signed with the secret", token: signedToken(`{"alg":"HS256","typ":"JWT","kid":"1"}`, `{"sub":"user-id","exp":9999999999}`), secret: testSecret, at: issuedAt, wantErr: ErrInvalidToken},
+		{name: "two parts", token: parts[0] + "." + parts[1], secret: testSecret, at: issuedAt, wantErr: ErrInvalidToken},
+		{name: "four parts", token: token + "." + parts[2], secret: testSecret, at: issuedAt, wantErr: ErrInvalidToken},
+		{name: "empty", token: "", secret: testSecret, at: issuedAt, wantErr: ErrInvalidToken},
+		{name: "payload not base64", token: jwtHeader + ".not*base64." + signJWT(jwtHeader+".not*base64", testSecret), secret: testSecret, at: issuedAt, wantErr: ErrInvalidToken},
+		{name: "payload not json", token: signedToken(`{"alg":"HS256","typ":"JWT"}`, `not json`), secret: testSecret, at: issuedAt, wantErr: ErrInvalidToken},
+		{name: "payload without expiry", token: signedToken(`{"alg":"HS256","typ":"JWT"}`, `{"sub":"user-id"}`), secret: testSecret, at: issuedAt, wantErr: ErrExpiredToken},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			Now = func() time.Time { return tt.at }
+			got, err := ParseJWT(tt.token, tt.secret)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ParseJWT error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr == nil && got != claims {
+				t.Fatalf("ParseJWT = %+v, want %+v", got, claims)
+			}
+		})
+	}
+}
+
+func TestNewClaims(t *testing.T) {
+	issuedAt := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	defer func(now func() time.Time) { Now = now }(Now)
+	Now = func() time.Time { return issuedAt }
+
+	claims := NewClaims("user-id", "alice", 2, 12*time.Hour)
+	if claims.IssuedAt != issuedAt.Unix() || claims.ExpiresAt != issuedAt.Add(12*time.Hour).Unix() {
+		t.Fatalf("NewClaims = %+v, want issued at %d and expiring at %d", claims, issuedAt.Unix(), issuedAt.Add(12*time.Hour).Unix())
+	}
+	if claims.Subject != "user-id" || claims.Username != "alice" || claims.Role != 2 {
+		t.Fatalf("NewClaims = %+v", claims)
+	}
+}
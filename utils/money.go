@@ -0,0 +1,154 @@
+package utils
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Money is an exact amount of money in cents, stored as numeric(14,2) and written to JSON as a number with 2 decimals
+type Money int64
+
+// the currency of the amounts that do not specify one
+const DefaultCurrency = "SGD"
+
+var ErrInvalidMoney = errors.New("invalid amount, expected a number with at most 2 decimals")
+
+// ParseMoney parses a decimal like "12", "12.5" or "-12.50" without going through a float
+func ParseMoney(s string) (Money, error) {
+	s = strings.TrimSpace(s)
+	negative := strings.HasPrefix(s, "-")
+	if negative || strings.HasPrefix(s, "+") {
+		s = s[1:]
+	}
+	units, cents, _ := strings.Cut(s, ".")
+	if (units == "" && cents == "") || strings.ContainsAny(units+cents, "+-") {
+		return 0, ErrInvalidMoney
+	}
+	if units == "" {
+		units = "0"
+	}
+	// trailing zeros do not change the amount, numeric columns may return more scale than 2
+	cents = strings.TrimRight(cents, "0")
+	if len(cents) > 2 {
+		return 0, ErrInvalidMoney
+	}
+	cents += strings.Repeat("0", 2-len(cents))
+	whole, err := strconv.ParseInt(units, 10, 64)
+	if err != nil {
+		return 0, ErrInvalidMoney
+	}
+	fraction, err := strconv.ParseInt(cents, 10, 64)
+	if err != nil {
+		return 0, ErrInvalidMoney
+	}
+	if whole > (math.MaxInt64-fraction)/100 {
+		return 0, ErrInvalidMoney
+	}
+	amount := Money(whole*100 + fraction)
+	if negative {
+		amount = -amount
+	}
+	return amount, nil
+}
+
+// NewMoneyFromFloat rounds a float to the nearest cent, for amounts that only exist as floats
+func NewMoneyFromFloat(f float64) Money {
+	return Money(math.Round(f * 100))
+}
+
+func (m Money) Float64() float64 {
+	return float64(m) / 100
+}
+
+// Mul multiplies the amount by a whole number of units
+func (m Money) Mul(units int64) Money {
+	return m * Money(units)
+}
+
+// Div divides the amount by a whole number of units, rounded half away from zero to the cent
+func (m Money) Div(units int64) Money {
+	quotient, remainder := int64(m)/units, int64(m)%units
+	if remainder < 0 {
+		remainder = -remainder
+	}
+	if 2*remainder >= units {
+		if m < 0 {
+			quotient--
+		} else {
+			quotient++
+		}
+	}
+	return Money(quotient)
+}
+
+func (m Money) String() string {
+	sign := ""
+	cents := int64(m)
+	if cents < 0 {
+		sign = "-"
+		cents = -cents
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, cents/100, cents%100)
+}
+
+func (m Money) MarshalJSON() ([]byte, error) {
+	return []byte(m.String()), nil
+}
+
+// accepts a JSON number or a string holding one, null leaves the amount unchanged like it does for the other types
+func (m *Money) UnmarshalJSON(b []byte) error {
+	s := string(b)
+	if s == "null" {
+		return nil
+	}
+	if strings.HasPrefix(s, "\"") {
+		unquoted, err := strconv.Unquote(s)
+		if err != nil {
+			return ErrInvalidMoney
+		}
+		s = unquoted
+	}
+	amount, err := ParseMoney(s)
+	if err != nil {
+		return err
+	}
+	*m = amount
+	return nil
+}
+
+// Value writes the amount as a decimal string, so numeric columns receive it exactly
+func (m Money) Value() (driver.Value, error) {
+	return m.String(), nil
+}
+
+func (m *Money) Scan(src interface{}) error {
+	switch value := src.(type) {
+	case nil:
+		*m = 0
+		return nil
+	case []byte:
+		return m.scanString(string(value))
+	case string:
+		return m.scanString(value)
+	case int64:
+		*m = Money(value * 100)
+		return nil
+	case float64:
+		*m = NewMoneyFromFloat(value)
+		return nil
+	}
+	return fmt.Errorf("can not scan %T into Money", src)
+}
+
+func (m *Money) scanString(s string) error {
+	amount, err := ParseMoney(s)
+	if err != nil {
+		return err
+	}
+	*m = amount
+	return nil
+}
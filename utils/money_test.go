@@ -0,0 +1,149 @@
+package utils
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestParseMoney(t *testing.T) {
+	tests := []struct {
+		source  string
+		want    Money
+		wantErr bool
+	}{
+		{source: "12", want: 1200},
+		{source: "12.5", want: 1250},
+		{source: "12.50", want: 1250},
+		{source: "0.05", want: 5},
+		{source: ".5", want: 50},
+		{source: "12.", want: 1200},
+		{source: " 12.34 ", want: 1234},
+		{source: "12.3400", want: 1234},
+		{source: "-12.34", want: -1234},
+		{source: "+12.34", want: 1234},
+		{source: "-0", want: 0},
+		{source: "92233720368547758.07", want: 9223372036854775807},
+		{source: "12.345", wantErr: true},
+		{source: "0.001", wantErr: true},
+		{source: "92233720368547758.08", wantErr: true},
+		{source: "100000000000000000000", wantErr: true},
+		{source: "--12", wantErr: true},
+		{source: "-+12", wantErr: true},
+		{source: "+-12", wantErr: true},
+		{source: "12-", wantErr: true},
+		{source: "1.2.3", wantErr: true},
+		{source: "1e3", wantErr: true},
+		{source: "abc", wantErr: true},
+		{source: ".", wantErr: true},
+		{source: "-", wantErr: true},
+		{source: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.source, func(t *testing.T) {
+			got, err := ParseMoney(tt.source)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidMoney) {
+					t.Fatalf("ParseMoney(%q) = %v, %v, want ErrInvalidMoney", tt.source, got, err)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Fatalf("ParseMoney(%q) = %v, %v, want %v", tt.source, got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestMoneyUnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		json    string
+		want    Money
+		wantErr bool
+	}{
+		{json: `12.34`, want: 1234},
+		{json: `"12.34"`, want: 1234},
+		{json: `"-5"`, want: -500},
+		{json: `null`, want: 700},
+		{json: `"12.345"`, wantErr: true},
+		{json: `"12`, wantErr: true},
+		{json: `12"`, wantErr: true},
+		{json: `""`, wantErr: true},
+		{json: `true`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.json, func(t *testing.T) {
+			// null keeps the amount the value had before
+			got := Money(700)
+			err := got.UnmarshalJSON([]byte(tt.json))
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("UnmarshalJSON(%s) = %v, want error", tt.json, got)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Fatalf("UnmarshalJSON(%s) = %v, %v, want %v", tt.json, got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestMoneyJSONRoundTrip(t *testing.T) {
+	var decoded struct {
+		Amount    Money  `json:"amount"`
+		MinAmount *Money `json:"min_amount"`
+		MaxAmount Money  `json:"max_amount"`
+	}
+	if err := json.Unmarshal([]byte(`{"amount":"19.9","min_amount":null,"max_amount":null}`), &decoded); err != nil {
+		t.Fatalf("Unmarshal error = %v", err)
+	}
+	if decoded.Amount != 1990 || decoded.MinAmount != nil || decoded.MaxAmount != 0 {
+		t.Fatalf("Unmarshal = %+v", decoded)
+	}
+	encoded, err := json.Marshal(decoded)
+	if err != nil {
+		t.Fatalf("Marshal error = %v", err)
+	}
+	if string(encoded) != `{"amount":19.90,"min_amount":null,"max_amount":0.00}` {
+		t.Fatalf("Marshal = %s", encoded)
+	}
+}
+
+func TestMoneyString(t *testing.T) {
+	tests := []struct {
+		money Money
+		want  string
+	}{
+		{money: 0, want: "0.00"},
+		{money: 5, want: "0.05"},
+		{money: -5, want: "-0.05"},
+		{money: 123456, want: "1234.56"},
+		{money: -123400, want: "-1234.00"},
+	}
+	for _, tt := range tests {
+		if got := tt.money.String(); got != tt.want {
+			t.Fatalf("Money(%d).String() = %q, want %q", int64(tt.money), got, tt.want)
+		}
+	}
+}
+
+func TestMoneyDiv(t *testing.T) {
+	tests := []struct {
+		money Money
+		units int64
+		want  Money
+	}{
+		{money: 300, units: 3, want: 100},
+		{money: 100, units: 3, want: 33},
+		{money: 200, units: 3, want: 67},
+		{money: 5, units: 2, want: 3},
+		{money: -5, units: 2, want: -3},
+		{money: -100, units: 3, want: -33},
+	}
+	for _, tt := range tests {
+		if got := tt.money.Div(tt.units); got != tt.want {
+			t.Fatalf("Money(%d).Div(%d) = %d, want %d", int64(tt.money), tt.units, int64(got), int64(tt.want))
+		}
+	}
+}